@@ -0,0 +1,161 @@
+package gwt
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/twinj/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	clientCredentialsGrantType = "client_credentials"
+	clientSubjectPrefix        = "client:"
+)
+
+// Client is a confidential client allowed to get access tokens with client credentials grant
+type Client struct {
+	Id string
+
+	// SecretHash is a hash of the client secret made by HashClientSecret
+	SecretHash string
+
+	// Scopes are allowed scopes of the client, all of them are granted if request has no scope
+	Scopes []string
+
+	// AccessLifetime is a duration that access token of the client is valid. Optional, Settings.AccessLifetime by default.
+	AccessLifetime time.Duration
+}
+
+// ClientRegistryInterface returns registered clients, ErrClientNotFound is returned for unknown client id
+type ClientRegistryInterface interface {
+	GetClient(clientId string) (*Client, error)
+}
+
+// ClientRegistry is in-memory ClientRegistryInterface implementation
+type ClientRegistry struct {
+	clients map[string]*Client
+}
+
+// ClientPrincipal is stored in the context by auth middleware instead of the user when the token is issued to a client
+type ClientPrincipal struct {
+	ClientId string
+	Scopes   []string
+}
+
+// NewClientRegistry creates in-memory client registry
+func NewClientRegistry(clients ...Client) *ClientRegistry {
+	registry := &ClientRegistry{clients: map[string]*Client{}}
+	for i := range clients {
+		registry.clients[clients[i].Id] = &clients[i]
+	}
+	return registry
+}
+
+func (registry *ClientRegistry) GetClient(clientId string) (*Client, error) {
+	client, ok := registry.clients[clientId]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	return client, nil
+}
+
+// HashClientSecret returns hash of the client secret to be saved in Client.SecretHash.
+// Client secrets are expected to be long random strings, so sha256 is enough.
+func HashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetClient returns client principal set by auth middleware, false is returned for user tokens
+func GetClient(c *gin.Context) (*ClientPrincipal, bool) {
+	value, exists := c.Get(ClientKey)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*ClientPrincipal)
+	return principal, ok
+}
+
+// authenticateClient checks client credentials from basic auth or request form
+func (handler *Handler) authenticateClient(c *gin.Context) (*Client, error) {
+	clientId, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientId, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if clientId == "" {
+		return nil, ErrInvalidClient
+	}
+	client, err := handler.settings.Clients.GetClient(clientId)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+	if subtle.ConstantTimeCompare([]byte(HashClientSecret(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// clientCredentialsGrant issues access token with client subject, refresh token is not returned
+func (handler *Handler) clientCredentialsGrant(c *gin.Context) {
+	hooks := &handler.settings.Hooks
+	if handler.settings.Clients == nil {
+		handler.oauth2Error(c, http.StatusBadRequest, oauth2UnsupportedGrantType,
+			"unsupported grant type "+clientCredentialsGrantType)
+		return
+	}
+	client, authErr := handler.authenticateClient(c)
+	if authErr != nil {
+		hooks.call(hooks.OnLoginFailure, c, "", "", authErr)
+		handler.oauth2Error(c, http.StatusUnauthorized, oauth2InvalidClient, authErr.Error())
+		return
+	}
+	subject := clientSubjectPrefix + client.Id
+	scope := strings.Join(client.Scopes, " ")
+	if requested := c.PostForm(scopeClaim); requested != "" {
+		if !isSubScope(requested, scope) {
+			hooks.call(hooks.OnLoginFailure, c, subject, "", ErrInvalidScope)
+			handler.oauth2Error(c, http.StatusBadRequest, oauth2InvalidScope, ErrInvalidScope.Error())
+			return
+		}
+		scope = requested
+	}
+	lifetime := client.AccessLifetime
+	if lifetime == 0 {
+		lifetime = handler.settings.AccessLifetime
+	}
+	params := &tokenParams{userId: subject, clientId: client.Id, accessLifetime: lifetime, refreshLifetime: lifetime}
+	if scope != "" {
+		params.claims = map[string]interface{}{scopeClaim: scope}
+	}
+	accessData, err := saveAccessToken(handler.settings, params)
+	if err != nil {
+		hooks.call(hooks.OnLoginFailure, c, subject, "", err)
+		handler.oauth2Error(c, http.StatusInternalServerError, oauth2ServerError, err.Error())
+		return
+	}
+
+	hooks.call(hooks.OnLoginSuccess, c, subject, "", nil)
+	handler.oauth2TokenResponse(c, params, accessData, nil)
+}
+
+// saveAccessToken creates and saves an access token without refresh token and session, the client gets
+// a new token with its credentials
+func saveAccessToken(settings *Settings, params *tokenParams) (*accessTokenData, error) {
+	accessStorage, ok := unwrapStorage[AccessTokenStorageInterface](settings.Storage)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	accessData, err := (&tokenService{})._createAccessToken(settings, params, uuid.NewV4().String(), "")
+	if err != nil {
+		return nil, err
+	}
+	if saveErr := accessStorage.SaveAccessToken(accessData.userId, accessData.uuid, accessData.expire,
+		accessData.token); saveErr != nil {
+		return nil, saveErr
+	}
+	return accessData, nil
+}
@@ -0,0 +1,181 @@
+package gwt
+
+import (
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getClientsSettings() *Settings {
+	settings := getSettingsFixture()
+	settings.Clients = NewClientRegistry(Client{
+		Id:             "worker",
+		SecretHash:     HashClientSecret("worker_secret"),
+		Scopes:         []string{"read", "write"},
+		AccessLifetime: time.Hour,
+	})
+	return settings
+}
+
+func testClientCredentialsInit(settings *Settings, clientId string, secret string, scope string) (*httptest.ResponseRecorder, *accessTokenStorageMock) {
+	strgMock := new(accessTokenStorageMock)
+	strgMock.On("SaveAccessToken", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/token", handler.GetTokenEndpointHandler())
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	request, _ := http.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(clientId, secret)
+	router.ServeHTTP(rr, request)
+
+	return rr, strgMock
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	rr, strgMock := testClientCredentialsInit(getClientsSettings(), "worker", "worker_secret", "")
+
+	var res OAuth2TokenResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "read write", res.Scope)
+	assert.Empty(t, res.RefreshToken)
+	assert.InDelta(t, time.Hour.Seconds(), res.ExpiresIn, 1)
+	strgMock.AssertCalled(t, "SaveAccessToken")
+	strgMock.AssertNotCalled(t, "SaveTokens")
+	strgMock.AssertNotCalled(t, "SaveSession")
+
+	claims, _ := (&tokenService{}).getClaims(mustParseAccessToken(res.AccessToken), []string{userIdClaim, clientIdClaim})
+	assert.Equal(t, "client:worker", claims[userIdClaim])
+	assert.Equal(t, "worker", claims[clientIdClaim])
+}
+
+func TestClientCredentialsGrantNarrowScope(t *testing.T) {
+	rr, _ := testClientCredentialsInit(getClientsSettings(), "worker", "worker_secret", "read")
+
+	var res OAuth2TokenResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "read", res.Scope)
+}
+
+func TestClientCredentialsGrantErrors(t *testing.T) {
+	cases := []struct {
+		settings *Settings
+		clientId string
+		secret   string
+		scope    string
+		httpCode int
+		errCode  string
+	}{
+		{getClientsSettings(), "worker", "wrong", "", http.StatusUnauthorized, "invalid_client"},
+		{getClientsSettings(), "unknown", "worker_secret", "", http.StatusUnauthorized, "invalid_client"},
+		{getClientsSettings(), "worker", "worker_secret", "admin", http.StatusBadRequest, "invalid_scope"},
+		{getSettingsFixture(), "worker", "worker_secret", "", http.StatusBadRequest, "unsupported_grant_type"},
+	}
+	for _, testCase := range cases {
+		rr, strgMock := testClientCredentialsInit(testCase.settings, testCase.clientId, testCase.secret, testCase.scope)
+		var res OAuth2ErrorResponse
+		_ = json.NewDecoder(rr.Body).Decode(&res)
+
+		assert.Equal(t, testCase.httpCode, rr.Code)
+		assert.Equal(t, testCase.errCode, res.Error)
+		strgMock.AssertNotCalled(t, "SaveAccessToken")
+	}
+}
+
+func TestClientCredentialsGrantUnsupportedStorage(t *testing.T) {
+	settings := getClientsSettings()
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/token", handler.GetTokenEndpointHandler())
+	form := url.Values{"grant_type": {"client_credentials"}}
+	request, _ := http.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth("worker", "worker_secret")
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrUnsupportedStorage.Error())
+}
+
+func TestInitClientsUnsupportedStorage(t *testing.T) {
+	_, err := Init(*getClientsSettings())
+	assert.ErrorIs(t, err, ErrUnsupportedStorage)
+
+	settings := getClientsSettings()
+	settings.Storage = new(accessTokenStorageMock)
+	_, err = Init(*settings)
+	assert.Nil(t, err)
+}
+
+func TestAuthMiddlewareClientToken(t *testing.T) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "client:worker",
+		clientId: "worker", claims: map[string]interface{}{scopeClaim: "read write"}}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	getUserCalled := false
+	settings.GetUserFunc = func(userId string) (interface{}, error) {
+		getUserCalled = true
+		return nil, nil
+	}
+	mw := &Middleware{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	var principal *ClientPrincipal
+	var isClient, userExists bool
+	router.Use(mw.GetAuthMiddleware()).GET("/test-auth", func(c *gin.Context) {
+		principal, isClient = GetClient(c)
+		_, userExists = c.Get(UserKey)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, getUserCalled)
+	assert.False(t, userExists)
+	assert.True(t, isClient)
+	assert.Equal(t, &ClientPrincipal{ClientId: "worker", Scopes: []string{"read", "write"}}, principal)
+}
+
+func TestClientIdClaimIsReserved(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1",
+		claims: map[string]interface{}{clientIdClaim: "worker"}}, "access", "refresh")
+
+	claims, _ := (&tokenService{}).getClaims(mustParseAccessToken(accessData.token), []string{clientIdClaim})
+	assert.Equal(t, "", claims[clientIdClaim])
+}
+
+func mustParseAccessToken(token string) *jwt.Token {
+	parsedToken, err := (&tokenService{}).parseToken(token, getSettingsFixture().AccessSecretKey, "HS256")
+	if err != nil {
+		panic(err)
+	}
+	return parsedToken
+}
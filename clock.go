@@ -0,0 +1,24 @@
+package gwt
+
+import "time"
+
+// Clock returns current time, it is read on token creation, expiry checks, login throttling
+// and by storages implementing ClockStorageInterface. Set Settings.Clock to control time in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default clock returning time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns current time of the settings clock, system time if the clock is not set
+func (settings *Settings) now() time.Time {
+	if settings.Clock == nil {
+		return time.Now()
+	}
+	return settings.Clock.Now()
+}
@@ -0,0 +1,72 @@
+package gwt
+
+import (
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSettingsNowDefaultsToSystemClock(t *testing.T) {
+	settings := &Settings{}
+
+	assert.WithinDuration(t, time.Now(), settings.now(), time.Second)
+}
+
+func TestCreateTokensUsesClock(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Clock = &fixedClock{now: time.Unix(1000, 0)}
+	service := &tokenService{}
+
+	accessData, refreshData, err := service.getTokens(settings, &tokenParams{userId: "1"})
+	parsed, _ := service.parseToken(accessData.token, settings.AccessSecretKey, settings.SigningMethod)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1060), accessData.expire)
+	assert.Equal(t, int64(1120), refreshData.expire)
+	assert.Equal(t, float64(1000), parsed.Claims.(jwt.MapClaims)[issuedAtClaim])
+	assert.Equal(t, float64(1000), parsed.Claims.(jwt.MapClaims)[sessionStartClaim])
+}
+
+func TestAuthMiddlewareExpiredByClock(t *testing.T) {
+	settings := getSettingsFixture()
+	clock := &fixedClock{now: time.Now()}
+	settings.Clock = clock
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	clock.now = clock.now.Add(settings.AccessLifetime)
+
+	rr := testHTTPAuthMiddlewareInit(settings, "Bearer "+accessData.token, func(w http.ResponseWriter, r *http.Request) {})
+
+	var res DefaultErrResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, ErrTokenExpired.Error(), res.ErrorMessage)
+}
+
+func TestInitSetsClockToStorage(t *testing.T) {
+	settings := getSettingsFixture()
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	strgMock := new(clockStorageMock)
+	settings.Storage = strgMock
+	settings.Clock = clock
+
+	_, err := Init(*settings)
+
+	assert.Nil(t, err)
+	assert.Equal(t, clock, strgMock.clock)
+}
+
+func TestInitDefaultClock(t *testing.T) {
+	settings := getSettingsFixture()
+	strgMock := new(clockStorageMock)
+	settings.Storage = strgMock
+
+	auth, err := Init(*settings)
+
+	assert.Nil(t, err)
+	assert.Equal(t, systemClock{}, auth.Service.settings.Clock)
+	assert.Equal(t, systemClock{}, strgMock.clock)
+}
@@ -0,0 +1,10 @@
+//go:build mysql
+// +build mysql
+
+package main
+
+import "gorm.io/driver/mysql"
+
+func init() {
+	gormDialectors["mysql"] = mysql.Open
+}
@@ -0,0 +1,10 @@
+//go:build postgres
+// +build postgres
+
+package main
+
+import "gorm.io/driver/postgres"
+
+func init() {
+	gormDialectors["postgres"] = postgres.Open
+}
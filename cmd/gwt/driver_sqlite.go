@@ -0,0 +1,10 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import "gorm.io/driver/sqlite"
+
+func init() {
+	gormDialectors["sqlite"] = sqlite.Open
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// hmacKeySizes are sizes of random HMAC secrets matching the hash size
+var hmacKeySizes = map[string]int{
+	"HS256": 32,
+	"HS384": 48,
+	"HS512": 64,
+}
+
+var ecCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// keygenCommand generates HMAC secret printed as base64url text, which is used as the key as is,
+// or a key pair as PKCS#8 private and PKIX public PEM blocks
+func keygenCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("keygen", stderr)
+	alg := fs.String("alg", "HS256", "algorithm: HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, ES512 or EdDSA")
+	bits := fs.Int("bits", 2048, "RSA key size")
+	out := fs.String("out", "", "file to write secret or private key to, public key is written to <file>.pub. "+
+		"Keys are printed if not set")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if size, ok := hmacKeySizes[*alg]; ok {
+		secret, err := newHMACSecret(size)
+		if err != nil {
+			return err
+		}
+		return writeKey(stdout, *out, secret+"\n", 0600)
+	}
+	privateKey, err := newPrivateKey(*alg, *bits)
+	if err != nil {
+		return usageError(fs, err.Error())
+	}
+	privatePEM, publicPEM, err := encodeKeyPair(privateKey)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = io.WriteString(stdout, privatePEM+publicPEM)
+		return err
+	}
+	if err = writeKey(stdout, *out, privatePEM, 0600); err != nil {
+		return err
+	}
+	return writeKey(stdout, *out+".pub", publicPEM, 0644)
+}
+
+func newHMACSecret(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func newPrivateKey(alg string, bits int) (crypto.Signer, error) {
+	switch {
+	case strings.HasPrefix(alg, "RS"):
+		if bits < 2048 {
+			return nil, fmt.Errorf("RSA key size must be at least 2048 bits, got %d", bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case ecCurves[alg] != nil:
+		return ecdsa.GenerateKey(ecCurves[alg], rand.Reader)
+	case alg == "EdDSA":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	}
+	return nil, fmt.Errorf("unknown algorithm %q", alg)
+}
+
+func encodeKeyPair(privateKey crypto.Signer) (string, string, error) {
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return "", "", err
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	return string(privatePEM), string(publicPEM), nil
+}
+
+// writeKey prints the key, or writes it to the file with the permissions
+func writeKey(stdout io.Writer, file string, key string, perm os.FileMode) error {
+	if file == "" {
+		_, err := io.WriteString(stdout, key)
+		return err
+	}
+	if err := os.WriteFile(file, []byte(key), perm); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(stdout, "written %s\n", file)
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeygenHMAC(t *testing.T) {
+	code, stdout, _ := runCommand("keygen", "-alg", "HS512")
+
+	assert.Equal(t, 0, code)
+	assert.Len(t, strings.TrimSpace(stdout), 86)
+}
+
+func TestKeygenKeyPairs(t *testing.T) {
+	cases := []struct {
+		alg     string
+		keyType interface{}
+	}{
+		{"RS256", &rsa.PublicKey{}},
+		{"ES384", &ecdsa.PublicKey{}},
+		{"EdDSA", ed25519.PublicKey{}},
+	}
+	for _, testCase := range cases {
+		code, stdout, _ := runCommand("keygen", "-alg", testCase.alg)
+		publicKey, err := parsePublicKey([]byte(stdout))
+
+		assert.Equal(t, 0, code, testCase.alg)
+		assert.Nil(t, err, testCase.alg)
+		assert.IsType(t, testCase.keyType, publicKey, testCase.alg)
+	}
+}
+
+func TestKeygenOut(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "key")
+	code, _, _ := runCommand("keygen", "-alg", "ES256", "-out", out)
+	privatePEM, _ := os.ReadFile(out)
+	publicPEM, _ := os.ReadFile(out + ".pub")
+	info, _ := os.Stat(out)
+
+	assert.Equal(t, 0, code)
+	assert.Contains(t, string(privatePEM), "PRIVATE KEY")
+	assert.Contains(t, string(publicPEM), "PUBLIC KEY")
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestKeygenErrors(t *testing.T) {
+	code, _, stderr := runCommand("keygen", "-alg", "RS256", "-bits", "1024")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "at least 2048 bits")
+
+	code, _, stderr = runCommand("keygen", "-alg", "none")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown algorithm "none"`)
+}
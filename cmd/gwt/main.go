@@ -0,0 +1,98 @@
+// Command gwt is an operator tool for gwt deployments: it generates signing keys, decodes and verifies tokens,
+// mints tokens for debugging and lists or revokes sessions in redis or gorm storage.
+//
+//	gwt keygen -alg HS256|HS384|HS512|RS256|RS384|RS512|ES256|ES384|ES512|EdDSA [-bits 2048] [-out file]
+//	gwt decode [-alg HS256 -key secret | -key-file file] token
+//	gwt verify -alg HS256 -key secret | -key-file file token
+//	gwt mint -user id -key secret | -key-file file [-claim name=value] [storage flags]
+//	gwt sessions list -user id storage flags
+//	gwt sessions revoke -user id -session id | -all storage flags
+//
+// Storage flags are -storage redis with -redis-addr, -redis-password and -redis-db,
+// or -storage gorm with -gorm-driver, -gorm-dsn and -gorm-prefix.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errUsage is returned by commands on invalid arguments, usage is printed by the flag set
+var errUsage = errors.New("invalid arguments")
+
+type command struct {
+	usage string
+	run   func(args []string, stdout io.Writer, stderr io.Writer) error
+}
+
+var commands = map[string]command{
+	"keygen":   {"generate HMAC secret or RSA, EC, Ed25519 key pair", keygenCommand},
+	"decode":   {"print header, claims and expiry of a token, signature is verified if key is given", decodeCommand},
+	"verify":   {"verify signature and expiry of a token", verifyCommand},
+	"mint":     {"issue access and refresh tokens for a user id", mintCommand},
+	"sessions": {"list or revoke sessions of a user", sessionsCommand},
+}
+
+var commandOrder = []string{"keygen", "decode", "verify", "mint", "sessions"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(stderr, "gwt: unknown command %q\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		_, _ = fmt.Fprintf(stderr, "gwt %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "usage: gwt <command> [flags]")
+	_, _ = fmt.Fprintln(w)
+	for _, name := range commandOrder {
+		_, _ = fmt.Fprintf(w, "  %-9s %s\n", name, commands[name].usage)
+	}
+}
+
+// newFlagSet returns flag set printing errors and usage to stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("gwt "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parseFlags parses flags, errors are already printed by the flag set
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// usageError prints the message with usage of the flag set
+func usageError(fs *flag.FlagSet, message string) error {
+	_, _ = fmt.Fprintf(fs.Output(), "%s: %s\n", fs.Name(), message)
+	fs.Usage()
+	return errUsage
+}
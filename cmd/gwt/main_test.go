@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// runCommand runs the command and returns exit code, stdout and stderr
+func runCommand(args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunUsage(t *testing.T) {
+	code, _, stderr := runCommand()
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "usage: gwt <command>")
+
+	code, _, stderr = runCommand("unknown")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "unknown"`)
+}
+
+func TestRunInvalidFlag(t *testing.T) {
+	code, _, stderr := runCommand("keygen", "-unknown")
+
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "flag provided but not defined")
+}
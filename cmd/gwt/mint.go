@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strings"
+)
+
+// claimFlags collect repeated -claim name=value flags, value is parsed as json if it is valid json
+type claimFlags map[string]interface{}
+
+func (cf claimFlags) String() string {
+	return fmt.Sprint(map[string]interface{}(cf))
+}
+
+func (cf claimFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("claim %q is not name=value", value)
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(parts[1]), &parsed); err != nil {
+		parsed = parts[1]
+	}
+	cf[parts[0]] = parsed
+	return nil
+}
+
+// newGwt initializes gwt for the commands, login callbacks are never called by them.
+// Reuse of the access secret key is allowed, it is the choice of the service tokens are minted for.
+func newGwt(settings gwt.Settings) (*gwt.Gwt, error) {
+	settings.SecretReuse = gwt.SecretReuseAllow
+	settings.Authenticator = func(c *gin.Context) (string, error) {
+		return "", gwt.ErrNotAuthUser
+	}
+	settings.GetUserFunc = func(userId string) (interface{}, error) {
+		return userId, nil
+	}
+	return gwt.Init(settings)
+}
+
+// mintCommand issues tokens for the user. Tokens are saved to the storage if it is given, otherwise they are
+// only signed and auth middleware rejects them. Opaque tokens are minted only with the storage.
+func mintCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("mint", stderr)
+	kf := &keyFlags{}
+	kf.register(fs)
+	sf := &storageFlags{}
+	sf.register(fs)
+	userId := fs.String("user", "", "user id")
+	refreshKey := fs.String("refresh-key", "", "HMAC secret of refresh token, -key is used by default")
+	refreshKeyFile := fs.String("refresh-key-file", "", "file with HMAC secret of refresh token")
+	accessLifetime := fs.Duration("access-lifetime", 0, "access token lifetime, ten minutes by default")
+	refreshLifetime := fs.Duration("refresh-lifetime", 0, "refresh token lifetime, one day by default")
+	format := fs.String("format", string(gwt.TokenFormatJWT), "token format: jwt or opaque, opaque requires -storage")
+	claims := claimFlags{}
+	fs.Var(claims, "claim", "custom claim name=value, can be repeated")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *userId == "" {
+		return usageError(fs, "-user is required")
+	}
+	if !kf.isSet() {
+		return usageError(fs, "-key or -key-file is required")
+	}
+	if hmacKeySizes[kf.alg] == 0 {
+		return usageError(fs, "gwt signs tokens with HS256, HS384 or HS512 only")
+	}
+	if gwt.TokenFormat(*format) == gwt.TokenFormatOpaque && sf.kind == "" {
+		return usageError(fs, "-format opaque requires -storage, opaque tokens are resolved in it")
+	}
+
+	settings := gwt.Settings{SigningMethod: kf.alg, TokenFormat: gwt.TokenFormat(*format),
+		AccessLifetime: *accessLifetime, RefreshLifetime: *refreshLifetime}
+	var err error
+	if settings.AccessSecretKey, err = kf.secret(); err != nil {
+		return err
+	}
+	if *refreshKey != "" || *refreshKeyFile != "" {
+		if settings.RefreshSecretKey, err = readKey("refresh-key", *refreshKey, *refreshKeyFile); err != nil {
+			return err
+		}
+	}
+	if kf.isEncryptionKeySet() {
+		if settings.TokenEncryptionKey, err = kf.tokenEncryptionKey(); err != nil {
+			return err
+		}
+	}
+	if sf.kind == "" {
+		_, _ = fmt.Fprintln(stderr, "gwt mint: -storage is not set, tokens are not saved and will be rejected by auth middleware")
+		settings.Storage = discardStorage{}
+	} else if settings.Storage, err = openStorage(sf); err != nil {
+		return err
+	}
+	auth, err := newGwt(settings)
+	if err != nil {
+		return err
+	}
+	tokens, err := auth.Service.IssueTokens(&gwt.AuthResult{UserId: *userId, Claims: claims})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(gwt.DefaultLoginResponse{
+		AccessToken:   tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		AccessExpire:  tokens.AccessExpire,
+		RefreshExpire: tokens.RefreshExpire,
+	})
+}
+
+// discardStorage does not save tokens, it is used by mint without storage
+type discardStorage struct{}
+
+func (discardStorage) DeleteTokens(userId string, uuid ...string) error {
+	return nil
+}
+func (discardStorage) SaveTokens(userId string, accessUuid string, refreshUuid string, accessExpire int64,
+	refreshExpire int64, accessToken string, refreshToken string) error {
+	return nil
+}
+func (discardStorage) HasRefreshToken(uuid string, token string, userId string) error {
+	return gwt.ErrTokenExpired
+}
+func (discardStorage) HasAccessToken(uuid string, token string, userId string) error {
+	return gwt.ErrTokenExpired
+}
+func (discardStorage) DeleteAllTokens(userId string) error {
+	return nil
+}
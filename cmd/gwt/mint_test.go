@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/ennaque/go-gin-jwt/gwttest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// useStorage makes commands open the storage instead of the one configured by flags
+func useStorage(t *testing.T, strg gwt.StorageInterface) {
+	original := openStorage
+	openStorage = func(sf *storageFlags) (gwt.StorageInterface, error) {
+		return strg, nil
+	}
+	t.Cleanup(func() {
+		openStorage = original
+	})
+}
+
+// mintKey is long enough for HS256
+const mintKey = "mint-test-secret-key-of-32-bytes"
+
+func TestMintWithoutStorage(t *testing.T) {
+	code, stdout, stderr := runCommand("mint", "-user", "1", "-key", mintKey, "-claim", "role=admin",
+		"-claim", "level=3")
+	res := gwt.DefaultLoginResponse{}
+	_ = json.Unmarshal([]byte(stdout), &res)
+	decoded, err := decodeToken(res.AccessToken, &keyFlags{alg: "HS256", key: mintKey})
+
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stderr, "tokens are not saved")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", decoded.claims["user_id"])
+	assert.Equal(t, "admin", decoded.claims["role"])
+	assert.Equal(t, float64(3), decoded.claims["level"])
+}
+
+func TestMintSavesTokens(t *testing.T) {
+	strg := gwttest.NewMemoryStorage()
+	useStorage(t, strg)
+	code, stdout, _ := runCommand("mint", "-user", "1", "-key", mintKey, "-storage", "redis")
+	res := gwt.DefaultLoginResponse{}
+	_ = json.Unmarshal([]byte(stdout), &res)
+	decoded, _ := decodeToken(res.AccessToken, &keyFlags{alg: "HS256", key: mintKey})
+
+	assert.Equal(t, 0, code)
+	assert.Nil(t, strg.HasAccessToken(decoded.claims["access_uuid"].(string), res.AccessToken, "1"))
+}
+
+func TestMintEncryptedTokens(t *testing.T) {
+	encryptionKey := "token-encryption-key-of-32-bytes"
+	code, stdout, _ := runCommand("mint", "-user", "1", "-key", mintKey, "-encryption-key", encryptionKey)
+	res := gwt.DefaultLoginResponse{}
+	_ = json.Unmarshal([]byte(stdout), &res)
+	assert.Equal(t, 0, code)
+
+	code, _, stderr := runCommand("decode", res.AccessToken)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "token is encrypted (JWE), -encryption-key or -encryption-key-file is required")
+
+	code, _, stderr = runCommand("decode", "-encryption-key", "token-encryption-key-of-32-byte!", res.AccessToken)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "decrypt: token is not valid")
+
+	code, stdout, _ = runCommand("verify", "-key", mintKey, "-encryption-key", encryptionKey, res.AccessToken)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, `"user_id": "1"`)
+	assert.Contains(t, stdout, "encryption: JWE, decrypted")
+	assert.Contains(t, stdout, "signature: valid")
+}
+
+func TestMintOpaqueTokens(t *testing.T) {
+	useStorage(t, gwttest.NewMemoryStorage())
+	code, stdout, _ := runCommand("mint", "-user", "1", "-key", mintKey, "-format", "opaque", "-storage", "redis")
+	res := gwt.DefaultLoginResponse{}
+	_ = json.Unmarshal([]byte(stdout), &res)
+
+	assert.Equal(t, 0, code)
+	assert.NotContains(t, res.AccessToken, ".")
+
+	code, _, stderr := runCommand("decode", res.AccessToken)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "token is opaque")
+}
+
+func TestMintUsage(t *testing.T) {
+	code, _, stderr := runCommand("mint", "-key", "secret")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "-user is required")
+
+	code, _, stderr = runCommand("mint", "-user", "1", "-alg", "RS256", "-key", "secret")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "HS256, HS384 or HS512 only")
+
+	code, _, stderr = runCommand("mint", "-user", "1", "-key", "secret", "-format", "opaque")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "-format opaque requires -storage")
+
+	code, _, stderr = runCommand("mint", "-user", "1", "-key", "secret", "-claim", "role")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `claim "role" is not name=value`)
+}
+
+func TestMintRejectsShortKey(t *testing.T) {
+	code, _, stderr := runCommand("mint", "-user", "1", "-key", "secret")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "access secret key is too short: 6 bytes, HS256 requires at least 32")
+}
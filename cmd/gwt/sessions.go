@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/ennaque/go-gin-jwt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// sessionsCommand lists or revokes sessions of the user in the storage
+func sessionsCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "revoke") {
+		_, _ = fmt.Fprintln(stderr, "usage: gwt sessions list|revoke -user id [flags]")
+		return errUsage
+	}
+	fs := newFlagSet("sessions "+args[0], stderr)
+	sf := &storageFlags{}
+	sf.register(fs)
+	userId := fs.String("user", "", "user id")
+	sessionId := fs.String("session", "", "id of the session to revoke")
+	all := fs.Bool("all", false, "revoke all sessions of the user")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if *userId == "" {
+		return usageError(fs, "-user is required")
+	}
+	if args[0] == "revoke" && (*sessionId == "") == !*all {
+		return usageError(fs, "either -session or -all is required")
+	}
+	service, err := newSessionService(sf)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "list" {
+		sessions, listErr := service.ListSessions(*userId)
+		if listErr != nil {
+			return listErr
+		}
+		return printSessions(stdout, sessions)
+	}
+	if *all {
+		if err = service.ForceLogoutUser(*userId); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "revoked all sessions of user %s\n", *userId)
+		return err
+	}
+	if err = service.RevokeSession(*userId, *sessionId); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "revoked session %s of user %s\n", *sessionId, *userId)
+	return err
+}
+
+// newSessionService initializes gwt over the storage, sessions commands do not sign tokens,
+// so a random key only satisfies Init
+func newSessionService(sf *storageFlags) (*gwt.Service, error) {
+	strg, err := openStorage(sf)
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return nil, err
+	}
+	auth, err := newGwt(gwt.Settings{AccessSecretKey: secret, Storage: strg})
+	if err != nil {
+		return nil, err
+	}
+	return auth.Service, nil
+}
+
+func printSessions(w io.Writer, sessions []*gwt.Session) error {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt < sessions[j].CreatedAt
+	})
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SESSION\tCREATED\tEXPIRES\tMETADATA")
+	for _, session := range sessions {
+		expires := "never"
+		if session.ExpiresAt != 0 {
+			expires = formatUnix(session.ExpiresAt)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", session.Id, formatUnix(session.CreatedAt), expires,
+			formatMetadata(session.Metadata))
+	}
+	return tw.Flush()
+}
+
+func formatUnix(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+func formatMetadata(metadata map[string]string) string {
+	var pairs []string
+	for key, value := range metadata {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/ennaque/go-gin-jwt/gwttest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func saveSessions(strg *gwttest.MemoryStorage) {
+	expire := time.Now().Add(time.Hour).Unix()
+	_ = strg.SaveTokens("1", "a1", "r1", expire, expire, "a1_token", "r1_token")
+	_ = strg.SaveTokens("1", "a2", "r2", expire, expire, "a2_token", "r2_token")
+	_ = strg.SaveSession(&gwt.Session{Id: "s1", UserId: "1", AccessUuid: "a1", RefreshUuid: "r1", CreatedAt: 100,
+		Metadata: map[string]string{"ip": "10.0.0.1"}}, expire)
+	_ = strg.SaveSession(&gwt.Session{Id: "s2", UserId: "1", AccessUuid: "a2", RefreshUuid: "r2", CreatedAt: 200,
+		ExpiresAt: 300}, expire)
+}
+
+func TestSessionsList(t *testing.T) {
+	strg := gwttest.NewMemoryStorage()
+	saveSessions(strg)
+	useStorage(t, strg)
+	code, stdout, _ := runCommand("sessions", "list", "-user", "1", "-storage", "redis")
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "SESSION  CREATED               EXPIRES               METADATA\n"+
+		"s1       1970-01-01T00:01:40Z  never                 ip=10.0.0.1\n"+
+		"s2       1970-01-01T00:03:20Z  1970-01-01T00:05:00Z  \n", stdout)
+}
+
+func TestSessionsRevoke(t *testing.T) {
+	strg := gwttest.NewMemoryStorage()
+	saveSessions(strg)
+	useStorage(t, strg)
+	code, stdout, _ := runCommand("sessions", "revoke", "-user", "1", "-session", "s1", "-storage", "redis")
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "revoked session s1 of user 1\n", stdout)
+	assert.Equal(t, gwt.ErrTokenExpired, strg.HasAccessToken("a1", "a1_token", "1"))
+	assert.Nil(t, strg.HasAccessToken("a2", "a2_token", "1"))
+
+	code, stdout, _ = runCommand("sessions", "revoke", "-user", "1", "-all", "-storage", "redis")
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "revoked all sessions of user 1\n", stdout)
+	assert.Equal(t, gwt.ErrTokenExpired, strg.HasAccessToken("a2", "a2_token", "1"))
+}
+
+func TestSessionsRevokeNotFound(t *testing.T) {
+	useStorage(t, gwttest.NewMemoryStorage())
+	code, _, stderr := runCommand("sessions", "revoke", "-user", "1", "-session", "s1", "-storage", "redis")
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, gwt.ErrSessionNotFound.Error())
+}
+
+func TestSessionsUsage(t *testing.T) {
+	code, _, stderr := runCommand("sessions")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "usage: gwt sessions list|revoke")
+
+	code, _, stderr = runCommand("sessions", "revoke", "-user", "1", "-session", "s1", "-all")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "either -session or -all is required")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/ennaque/go-gin-jwt/storage"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+)
+
+// gormDialectors open gorm connections by driver name. Drivers are required by the module, but compiled in
+// only with their build tags, e.g. go build -tags postgres ./cmd/gwt.
+var gormDialectors = map[string]func(dsn string) gorm.Dialector{}
+
+// storageFlags configure storage the service keeps tokens and sessions in
+type storageFlags struct {
+	kind          string
+	redisAddr     string
+	redisPassword string
+	redisDB       int
+	gormDriver    string
+	gormDSN       string
+	gormPrefix    string
+}
+
+func (sf *storageFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&sf.kind, "storage", "", "storage type: redis or gorm")
+	fs.StringVar(&sf.redisAddr, "redis-addr", "localhost:6379", "redis address")
+	fs.StringVar(&sf.redisPassword, "redis-password", "", "redis password")
+	fs.IntVar(&sf.redisDB, "redis-db", 0, "redis database")
+	fs.StringVar(&sf.gormDriver, "gorm-driver", "", "gorm driver compiled in by build tag: postgres, mysql or sqlite")
+	fs.StringVar(&sf.gormDSN, "gorm-dsn", "", "gorm data source name")
+	fs.StringVar(&sf.gormPrefix, "gorm-prefix", "", "table prefix passed to storage.InitGormStorage")
+}
+
+// openStorage opens the storage configured by flags, replaced in tests
+var openStorage = func(sf *storageFlags) (gwt.StorageInterface, error) {
+	switch sf.kind {
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: sf.redisAddr, Password: sf.redisPassword, DB: sf.redisDB})
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return storage.InitRedisStorage(client), nil
+	case "gorm":
+		dialector, ok := gormDialectors[sf.gormDriver]
+		if !ok {
+			return nil, fmt.Errorf("gorm driver %q is not compiled in, available drivers: %s", sf.gormDriver,
+				availableGormDrivers())
+		}
+		if sf.gormDSN == "" {
+			return nil, errors.New("-gorm-dsn is required")
+		}
+		con, err := gorm.Open(dialector(sf.gormDSN), &gorm.Config{})
+		if err != nil {
+			return nil, fmt.Errorf("gorm: %w", err)
+		}
+		return storage.InitGormStorage(con, sf.gormPrefix)
+	case "":
+		return nil, errors.New("-storage is required")
+	}
+	return nil, fmt.Errorf("unknown storage %q, redis or gorm expected", sf.kind)
+}
+
+func availableGormDrivers() string {
+	if len(gormDialectors) == 0 {
+		return "none, build with -tags postgres, mysql or sqlite"
+	}
+	var names []string
+	for name := range gormDialectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestOpenStorageErrors(t *testing.T) {
+	cases := []struct {
+		flags   storageFlags
+		message string
+	}{
+		{storageFlags{}, "-storage is required"},
+		{storageFlags{kind: "memcached"}, `unknown storage "memcached"`},
+		{storageFlags{kind: "gorm", gormDriver: "oracle"}, `gorm driver "oracle" is not compiled in`},
+	}
+	for _, testCase := range cases {
+		_, err := openStorage(&testCase.flags)
+
+		assert.Contains(t, err.Error(), testCase.message)
+	}
+}
+
+func TestAvailableGormDrivers(t *testing.T) {
+	original := gormDialectors
+	defer func() {
+		gormDialectors = original
+	}()
+
+	gormDialectors = map[string]func(dsn string) gorm.Dialector{}
+	assert.Equal(t, "none, build with -tags postgres, mysql or sqlite", availableGormDrivers())
+
+	gormDialectors = map[string]func(dsn string) gorm.Dialector{"sqlite": nil, "mysql": nil}
+	assert.Equal(t, "mysql, sqlite", availableGormDrivers())
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/ennaque/go-gin-jwt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	jwt.RegisterSigningMethod(signingMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return signingMethodEdDSA
+	})
+}
+
+// signingMethodEd25519 adds EdDSA to jwt-go, so tokens signed with Ed25519 keys can be verified
+type signingMethodEd25519 struct{}
+
+var signingMethodEdDSA = &signingMethodEd25519{}
+
+func (m *signingMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEd25519) Verify(signingString string, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+func (m *signingMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// keyFlags are the key a token is signed with and the key it is encrypted with, given as value or file
+type keyFlags struct {
+	alg               string
+	key               string
+	keyFile           string
+	encryptionKey     string
+	encryptionKeyFile string
+}
+
+func (kf *keyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&kf.alg, "alg", "HS256", "signing algorithm, token signed with another one is rejected")
+	fs.StringVar(&kf.key, "key", "", "HMAC secret")
+	fs.StringVar(&kf.keyFile, "key-file", "", "file with HMAC secret, or PEM public or private key")
+	fs.StringVar(&kf.encryptionKey, "encryption-key", "", "JWE key of 16, 24 or 32 bytes, TokenEncryptionKey of the service")
+	fs.StringVar(&kf.encryptionKeyFile, "encryption-key-file", "", "file with JWE key")
+}
+
+func (kf *keyFlags) isSet() bool {
+	return kf.key != "" || kf.keyFile != ""
+}
+
+func (kf *keyFlags) isEncryptionKeySet() bool {
+	return kf.encryptionKey != "" || kf.encryptionKeyFile != ""
+}
+
+// secret returns HMAC secret, surrounding whitespace of the file is trimmed
+func (kf *keyFlags) secret() ([]byte, error) {
+	return readKey("key", kf.key, kf.keyFile)
+}
+
+// tokenEncryptionKey returns JWE key, surrounding whitespace of the file is trimmed
+func (kf *keyFlags) tokenEncryptionKey() ([]byte, error) {
+	return readKey("encryption-key", kf.encryptionKey, kf.encryptionKeyFile)
+}
+
+// verificationKey returns the key jwt-go verifies tokens of the algorithm with
+func (kf *keyFlags) verificationKey() (interface{}, error) {
+	key, err := kf.secret()
+	if err != nil {
+		return nil, err
+	}
+	if hmacKeySizes[kf.alg] != 0 {
+		if strings.HasPrefix(string(key), "-----BEGIN") {
+			return nil, fmt.Errorf("%s key must be HMAC secret, got PEM key", kf.alg)
+		}
+		return key, nil
+	}
+	if jwt.GetSigningMethod(kf.alg) == nil {
+		return nil, fmt.Errorf("unknown algorithm %q", kf.alg)
+	}
+	return parsePublicKey(key)
+}
+
+func readKey(name string, value string, file string) ([]byte, error) {
+	if value != "" && file != "" {
+		return nil, fmt.Errorf("-%s and -%s-file can not be used together", name, name)
+	}
+	if file == "" {
+		return []byte(value), nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimSpace(string(data))), nil
+}
+
+// parsePublicKey parses PKIX public key, or derives it from PKCS#8 private key
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key is not PEM encoded")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey.(crypto.Signer).Public(), nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q, PUBLIC KEY or PRIVATE KEY expected", block.Type)
+}
+
+// decodedToken is a parsed token, signature is verified only if the key is given
+type decodedToken struct {
+	header    map[string]interface{}
+	claims    jwt.MapClaims
+	verified  bool
+	encrypted bool
+	expiresAt time.Time
+}
+
+func (dt *decodedToken) expired(now time.Time) bool {
+	return !dt.expiresAt.IsZero() && !dt.expiresAt.After(now)
+}
+
+// decodeToken decrypts JWE with the encryption key, then parses the signed token. Opaque tokens are rejected.
+func decodeToken(tkn string, kf *keyFlags) (*decodedToken, error) {
+	var token *jwt.Token
+	var err error
+	if !strings.Contains(tkn, ".") {
+		return nil, errors.New("token is opaque, it has no claims to decode, the service resolves it in the storage")
+	}
+	encrypted := strings.Count(tkn, ".") == 4
+	if encrypted {
+		if !kf.isEncryptionKeySet() {
+			return nil, errors.New("token is encrypted (JWE), -encryption-key or -encryption-key-file is required")
+		}
+		key, keyErr := kf.tokenEncryptionKey()
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		if tkn, err = gwt.DecryptToken(key, tkn); err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+	}
+	if kf.isSet() {
+		key, keyErr := kf.verificationKey()
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		parser := &jwt.Parser{SkipClaimsValidation: true}
+		token, err = parser.Parse(tkn, func(token *jwt.Token) (interface{}, error) {
+			if token.Method.Alg() != kf.alg {
+				return nil, fmt.Errorf("token is signed with %s, not %s", token.Method.Alg(), kf.alg)
+			}
+			return key, nil
+		})
+	} else {
+		token, _, err = (&jwt.Parser{}).ParseUnverified(tkn, jwt.MapClaims{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoded := &decodedToken{header: token.Header, claims: token.Claims.(jwt.MapClaims), verified: kf.isSet(),
+		encrypted: encrypted}
+	if exp, ok := decoded.claims["exp"].(float64); ok {
+		decoded.expiresAt = time.Unix(int64(exp), 0)
+	}
+	return decoded, nil
+}
+
+func printToken(w io.Writer, decoded *decodedToken, now time.Time) error {
+	header, err := json.MarshalIndent(decoded.header, "", "  ")
+	if err != nil {
+		return err
+	}
+	claims, err := json.MarshalIndent(decoded.claims, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "header:\n%s\nclaims:\n%s\n", header, claims)
+	if iat, ok := decoded.claims["iat"].(float64); ok {
+		_, _ = fmt.Fprintf(w, "issued at: %s\n", time.Unix(int64(iat), 0).UTC().Format(time.RFC3339))
+	}
+	switch {
+	case decoded.expiresAt.IsZero():
+		_, _ = fmt.Fprintln(w, "expires at: never")
+	case decoded.expired(now):
+		_, _ = fmt.Fprintf(w, "expires at: %s (expired %s ago)\n", decoded.expiresAt.UTC().Format(time.RFC3339),
+			now.Sub(decoded.expiresAt).Truncate(time.Second))
+	default:
+		_, _ = fmt.Fprintf(w, "expires at: %s (valid for %s)\n", decoded.expiresAt.UTC().Format(time.RFC3339),
+			decoded.expiresAt.Sub(now).Truncate(time.Second))
+	}
+	if decoded.encrypted {
+		_, _ = fmt.Fprintln(w, "encryption: JWE, decrypted")
+	}
+	signature := "not verified"
+	if decoded.verified {
+		signature = "valid"
+	}
+	_, err = fmt.Fprintf(w, "signature: %s\n", signature)
+	return err
+}
+
+// decodeCommand prints the token, signature is verified if the key is given
+func decodeCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("decode", stderr)
+	kf := &keyFlags{}
+	kf.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "token is required")
+	}
+	decoded, err := decodeToken(fs.Arg(0), kf)
+	if err != nil {
+		return err
+	}
+	return printToken(stdout, decoded, time.Now())
+}
+
+// verifyCommand prints the token and fails if its signature is invalid or it has expired
+func verifyCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("verify", stderr)
+	kf := &keyFlags{}
+	kf.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "token is required")
+	}
+	if !kf.isSet() {
+		return usageError(fs, "-key or -key-file is required")
+	}
+	decoded, err := decodeToken(fs.Arg(0), kf)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err = printToken(stdout, decoded, now); err != nil {
+		return err
+	}
+	if decoded.expired(now) {
+		return errors.New("token has expired")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, exp time.Time) string {
+	token, err := jwt.NewWithClaims(method, jwt.MapClaims{"user_id": "1", "exp": exp.Unix()}).SignedString(key)
+	assert.Nil(t, err)
+	return token
+}
+
+func TestDecodeUnverified(t *testing.T) {
+	token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), time.Now().Add(time.Minute))
+	code, stdout, _ := runCommand("decode", token)
+
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, `"user_id": "1"`)
+	assert.Contains(t, stdout, "valid for")
+	assert.Contains(t, stdout, "signature: not verified")
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), time.Now().Add(time.Minute))
+
+	code, stdout, _ := runCommand("verify", "-key", "secret", token)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "signature: valid")
+
+	code, _, stderr := runCommand("verify", "-key", "wrong", token)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "signature is invalid")
+
+	code, _, stderr = runCommand("verify", "-alg", "HS512", "-key", "secret", token)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "token is signed with HS256, not HS512")
+}
+
+func TestVerifyExpired(t *testing.T) {
+	token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), time.Now().Add(-time.Minute))
+	code, stdout, stderr := runCommand("verify", "-key", "secret", token)
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "expired 1m0s ago")
+	assert.Contains(t, stderr, "token has expired")
+}
+
+func TestVerifyEdDSAWithKeyFile(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	publicDER, _ := x509.MarshalPKIXPublicKey(publicKey)
+	keyFile := filepath.Join(t.TempDir(), "key.pub")
+	_ = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644)
+	token := signToken(t, signingMethodEdDSA, privateKey, time.Now().Add(time.Minute))
+
+	code, stdout, _ := runCommand("verify", "-alg", "EdDSA", "-key-file", keyFile, token)
+
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "signature: valid")
+}
+
+func TestVerifyRejectsPEMAsHMACSecret(t *testing.T) {
+	_, publicPEM, _ := encodeKeyPair(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	token := signToken(t, jwt.SigningMethodHS256, []byte(publicPEM), time.Now().Add(time.Minute))
+
+	code, _, stderr := runCommand("verify", "-key", publicPEM, token)
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "HS256 key must be HMAC secret")
+}
+
+func TestVerifyUsage(t *testing.T) {
+	code, _, stderr := runCommand("verify", "token")
+
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "-key or -key-file is required")
+}
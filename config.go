@@ -0,0 +1,288 @@
+package gwt
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StorageFactory creates storage from the storage section of the configuration,
+// the section contains only keys registered with the factory
+type StorageFactory func(config *viper.Viper) (StorageInterface, error)
+
+type storageRegistration struct {
+	factory StorageFactory
+	keys    []string
+}
+
+var (
+	storagesMu sync.RWMutex
+	storages   = map[string]storageRegistration{}
+)
+
+// RegisterStorage makes storage available by name in storage.type of the configuration, keys are allowed
+// in storage.<name> section. Storage package registers redis and gorm, register gorm storage with your
+// existing connection under another name:
+//
+//	gwt.RegisterStorage("app-db", func(config *viper.Viper) (gwt.StorageInterface, error) {
+//		return storage.InitGormStorage(db, config.GetString("table_prefix"))
+//	}, "table_prefix")
+//
+// It panics if the name is registered twice.
+func RegisterStorage(name string, factory StorageFactory, keys ...string) {
+	storagesMu.Lock()
+	defer storagesMu.Unlock()
+	if factory == nil {
+		panic("gwt: RegisterStorage factory is nil")
+	}
+	if _, exists := storages[name]; exists {
+		panic("gwt: RegisterStorage called twice for storage " + name)
+	}
+	storages[name] = storageRegistration{factory: factory, keys: keys}
+}
+
+func getStorageRegistration(name string) (storageRegistration, bool) {
+	storagesMu.RLock()
+	defer storagesMu.RUnlock()
+	registration, ok := storages[name]
+	return registration, ok
+}
+
+// configKeys are settings keys of the configuration, storage keys are added by storage registrations
+var configKeys = []string{
+	"signing_method",
+	"token_format",
+	"access_secret",
+	"access_secret_file",
+	"refresh_secret",
+	"refresh_secret_file",
+	"secret_reuse",
+	"token_encryption_key",
+	"token_encryption_key_file",
+	"access_lifetime",
+	"refresh_lifetime",
+	"session_max_lifetime",
+	"mfa_token_lifetime",
+	"impersonation_lifetime",
+	"disable_sliding_refresh",
+	"lazy_user_loading",
+	"auth_head_name",
+	"additional_auth_header",
+	"storage.type",
+}
+
+// SettingsFromConfig reads settings from the configuration, e.g. yaml file loaded by viper:
+//
+//	signing_method: HS512
+//	access_secret_file: /run/secrets/jwt_access
+//	access_lifetime: 15m
+//	storage:
+//	  type: redis
+//	  redis:
+//	    addr: redis:6379
+//
+// Unknown keys and invalid values are rejected with ErrInvalidConfig. Callbacks, e.g. Authenticator and GetUserFunc,
+// can not be configured, set them to the returned settings before Init.
+func SettingsFromConfig(v *viper.Viper) (Settings, error) {
+	settings := Settings{}
+	storageType := v.GetString("storage.type")
+	keys, err := allowedConfigKeys(storageType)
+	if err != nil {
+		return settings, err
+	}
+	if unknownErr := checkUnknownConfigKeys(v.AllKeys(), keys); unknownErr != nil {
+		return settings, unknownErr
+	}
+
+	settings.SigningMethod = v.GetString("signing_method")
+	settings.TokenFormat = TokenFormat(v.GetString("token_format"))
+	settings.AuthHeadName = v.GetString("auth_head_name")
+	settings.AdditionalAuthHeader = v.GetString("additional_auth_header")
+	if settings.AccessSecretKey, err = readConfigSecret(v, "access_secret"); err != nil {
+		return settings, err
+	}
+	if settings.RefreshSecretKey, err = readConfigSecret(v, "refresh_secret"); err != nil {
+		return settings, err
+	}
+	if settings.TokenEncryptionKey, err = readConfigSecret(v, "token_encryption_key"); err != nil {
+		return settings, err
+	}
+	if settings.SecretReuse, err = getConfigSecretReuse(v); err != nil {
+		return settings, err
+	}
+	if settings.AccessLifetime, err = getConfigDuration(v, "access_lifetime"); err != nil {
+		return settings, err
+	}
+	if settings.RefreshLifetime, err = getConfigDuration(v, "refresh_lifetime"); err != nil {
+		return settings, err
+	}
+	if settings.SessionMaxLifetime, err = getConfigDuration(v, "session_max_lifetime"); err != nil {
+		return settings, err
+	}
+	if settings.MFATokenLifetime, err = getConfigDuration(v, "mfa_token_lifetime"); err != nil {
+		return settings, err
+	}
+	if settings.ImpersonationLifetime, err = getConfigDuration(v, "impersonation_lifetime"); err != nil {
+		return settings, err
+	}
+	if settings.DisableSlidingRefresh, err = getConfigBool(v, "disable_sliding_refresh"); err != nil {
+		return settings, err
+	}
+	if settings.LazyUserLoading, err = getConfigBool(v, "lazy_user_loading"); err != nil {
+		return settings, err
+	}
+	if storageType != "" {
+		if settings.Storage, err = newConfigStorage(v, storageType); err != nil {
+			return settings, err
+		}
+	}
+	return settings, nil
+}
+
+// SettingsFromEnv reads settings from environment variables named by upper case keys with the prefix,
+// dots are replaced by underscores, e.g. GWT_ACCESS_LIFETIME and GWT_STORAGE_REDIS_ADDR for prefix GWT.
+// Unknown variables with the prefix are rejected.
+func SettingsFromEnv(prefix string) (Settings, error) {
+	envName := func(key string) string {
+		return prefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	}
+	keys, err := allowedConfigKeys(os.Getenv(envName("storage.type")))
+	if err != nil {
+		return Settings{}, err
+	}
+	envKeys := map[string]string{}
+	for _, key := range keys {
+		envKeys[envName(key)] = key
+	}
+	v := viper.New()
+	var unknown []string
+	for _, env := range os.Environ() {
+		nameValue := strings.SplitN(env, "=", 2)
+		if !strings.HasPrefix(nameValue[0], prefix+"_") {
+			continue
+		}
+		key, ok := envKeys[nameValue[0]]
+		if !ok {
+			unknown = append(unknown, nameValue[0])
+			continue
+		}
+		v.Set(key, nameValue[1])
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return Settings{}, fmt.Errorf("%w: unknown variables %s", ErrInvalidConfig, strings.Join(unknown, ", "))
+	}
+	return SettingsFromConfig(v)
+}
+
+// allowedConfigKeys returns settings keys and keys of the storage section
+func allowedConfigKeys(storageType string) ([]string, error) {
+	keys := append([]string{}, configKeys...)
+	if storageType == "" {
+		return keys, nil
+	}
+	registration, ok := getStorageRegistration(storageType)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown storage %q, import the package registering it", ErrInvalidConfig, storageType)
+	}
+	for _, key := range registration.keys {
+		keys = append(keys, "storage."+storageType+"."+strings.ToLower(key))
+	}
+	return keys, nil
+}
+
+func checkUnknownConfigKeys(keys []string, allowed []string) error {
+	allowedKeys := map[string]bool{}
+	for _, key := range allowed {
+		allowedKeys[key] = true
+	}
+	var unknown []string
+	for _, key := range keys {
+		if !allowedKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: unknown keys %s", ErrInvalidConfig, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// readConfigSecret returns the secret given by value or by file, trailing line break of the file is trimmed
+func readConfigSecret(v *viper.Viper, key string) ([]byte, error) {
+	value, file := v.GetString(key), v.GetString(key+"_file")
+	if value != "" && file != "" {
+		return nil, fmt.Errorf("%w: %s and %s_file can not be used together", ErrInvalidConfig, key, key)
+	}
+	if file == "" {
+		if value == "" {
+			return nil, nil
+		}
+		return []byte(value), nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s_file: %v", ErrInvalidConfig, key, err)
+	}
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+func getConfigDuration(v *viper.Viper, key string) (time.Duration, error) {
+	if !v.IsSet(key) {
+		return 0, nil
+	}
+	duration, err := cast.ToDurationE(v.Get(key))
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%w: %s must be a duration, e.g. 10m", ErrInvalidConfig, key)
+	}
+	return duration, nil
+}
+
+func getConfigBool(v *viper.Viper, key string) (bool, error) {
+	value, err := cast.ToBoolE(v.Get(key))
+	if err != nil {
+		return false, fmt.Errorf("%w: %s must be true or false", ErrInvalidConfig, key)
+	}
+	return value, nil
+}
+
+// secretReusePolicies are values of secret_reuse key
+var secretReusePolicies = map[string]SecretReusePolicy{
+	"warn":  SecretReuseWarn,
+	"allow": SecretReuseAllow,
+	"deny":  SecretReuseDeny,
+}
+
+func getConfigSecretReuse(v *viper.Viper) (SecretReusePolicy, error) {
+	if !v.IsSet("secret_reuse") {
+		return SecretReuseWarn, nil
+	}
+	policy, ok := secretReusePolicies[strings.ToLower(v.GetString("secret_reuse"))]
+	if !ok {
+		return SecretReuseWarn, fmt.Errorf("%w: secret_reuse must be warn, allow or deny", ErrInvalidConfig)
+	}
+	return policy, nil
+}
+
+// newConfigStorage creates the storage by its factory from keys of its section
+func newConfigStorage(v *viper.Viper, storageType string) (StorageInterface, error) {
+	registration, _ := getStorageRegistration(storageType)
+	section := viper.New()
+	for _, key := range registration.keys {
+		if fullKey := "storage." + storageType + "." + key; v.IsSet(fullKey) {
+			section.Set(key, v.Get(fullKey))
+		}
+	}
+	storage, err := registration.factory(section)
+	if err != nil {
+		return nil, fmt.Errorf("%w: storage %s: %v", ErrInvalidConfig, storageType, err)
+	}
+	return storage, nil
+}
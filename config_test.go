@@ -0,0 +1,137 @@
+package gwt
+
+import (
+	"bytes"
+	"errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// configStorageSection is the section config-test storage factory has been called with
+var configStorageSection *viper.Viper
+
+func init() {
+	RegisterStorage("config-test", func(config *viper.Viper) (StorageInterface, error) {
+		configStorageSection = config
+		if config.GetString("fail") != "" {
+			return nil, errors.New(config.GetString("fail"))
+		}
+		return new(storageMock), nil
+	}, "name", "fail")
+}
+
+func readYamlConfig(t *testing.T, config string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.Nil(t, v.ReadConfig(bytes.NewBufferString(config)))
+	return v
+}
+
+func TestSettingsFromConfig(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "refresh")
+	_ = os.WriteFile(secretFile, []byte("refresh_secret\n"), 0600)
+	v := readYamlConfig(t, `
+signing_method: HS512
+token_format: opaque
+access_secret: access_secret
+refresh_secret_file: `+secretFile+`
+secret_reuse: deny
+token_encryption_key: token-encryption-key-of-32-bytes
+access_lifetime: 15m
+refresh_lifetime: 720h
+session_max_lifetime: 2160h
+mfa_token_lifetime: 3m
+impersonation_lifetime: 30m
+disable_sliding_refresh: true
+lazy_user_loading: true
+auth_head_name: Token
+additional_auth_header: X-Auth
+storage:
+  type: config-test
+  config-test:
+    name: main
+`)
+	settings, err := SettingsFromConfig(v)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "HS512", settings.SigningMethod)
+	assert.Equal(t, TokenFormatOpaque, settings.TokenFormat)
+	assert.Equal(t, []byte("access_secret"), settings.AccessSecretKey)
+	assert.Equal(t, []byte("refresh_secret"), settings.RefreshSecretKey)
+	assert.Equal(t, SecretReuseDeny, settings.SecretReuse)
+	assert.Equal(t, []byte("token-encryption-key-of-32-bytes"), settings.TokenEncryptionKey)
+	assert.Equal(t, 15*time.Minute, settings.AccessLifetime)
+	assert.Equal(t, 720*time.Hour, settings.RefreshLifetime)
+	assert.Equal(t, 2160*time.Hour, settings.SessionMaxLifetime)
+	assert.Equal(t, 3*time.Minute, settings.MFATokenLifetime)
+	assert.Equal(t, 30*time.Minute, settings.ImpersonationLifetime)
+	assert.True(t, settings.DisableSlidingRefresh)
+	assert.True(t, settings.LazyUserLoading)
+	assert.Equal(t, "Token", settings.AuthHeadName)
+	assert.Equal(t, "X-Auth", settings.AdditionalAuthHeader)
+	assert.IsType(t, &storageMock{}, settings.Storage)
+	assert.Equal(t, "main", configStorageSection.GetString("name"))
+}
+
+func TestSettingsFromConfigDefaults(t *testing.T) {
+	settings, err := SettingsFromConfig(readYamlConfig(t, "access_secret: secret"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, Settings{AccessSecretKey: []byte("secret")}, settings)
+}
+
+func TestSettingsFromConfigErrors(t *testing.T) {
+	cases := []struct {
+		config  string
+		message string
+	}{
+		{"access_lifetim: 10m\nstorage_type: redis", "invalid config: unknown keys access_lifetim, storage_type"},
+		{"access_lifetime: ten", "invalid config: access_lifetime must be a duration, e.g. 10m"},
+		{"refresh_lifetime: -1h", "invalid config: refresh_lifetime must be a duration, e.g. 10m"},
+		{"secret_reuse: never", "invalid config: secret_reuse must be warn, allow or deny"},
+		{"lazy_user_loading: maybe", "invalid config: lazy_user_loading must be true or false"},
+		{"access_secret: a\naccess_secret_file: a", "invalid config: access_secret and access_secret_file can not be used together"},
+		{"access_secret_file: /nonexistent/secret", "invalid config: access_secret_file: open /nonexistent/secret"},
+		{"storage:\n  type: etcd", `invalid config: unknown storage "etcd", import the package registering it`},
+		{"storage:\n  type: config-test\n  config-test:\n    host: h", "invalid config: unknown keys storage.config-test.host"},
+		{"storage:\n  config-test:\n    name: main", "invalid config: unknown keys storage.config-test.name"},
+		{"storage:\n  type: config-test\n  config-test:\n    fail: no connection", "invalid config: storage config-test: no connection"},
+	}
+	for _, testCase := range cases {
+		_, err := SettingsFromConfig(readYamlConfig(t, testCase.config))
+
+		assert.ErrorIs(t, err, ErrInvalidConfig, testCase.config)
+		assert.Contains(t, err.Error(), testCase.message, testCase.config)
+	}
+}
+
+func TestSettingsFromEnv(t *testing.T) {
+	t.Setenv("GWTTEST_ACCESS_SECRET", "secret")
+	t.Setenv("GWTTEST_ACCESS_LIFETIME", "5m")
+	t.Setenv("GWTTEST_STORAGE_TYPE", "config-test")
+	t.Setenv("GWTTEST_STORAGE_CONFIG-TEST_NAME", "env")
+	settings, err := SettingsFromEnv("GWTTEST")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("secret"), settings.AccessSecretKey)
+	assert.Equal(t, 5*time.Minute, settings.AccessLifetime)
+	assert.Equal(t, "env", configStorageSection.GetString("name"))
+
+	t.Setenv("GWTTEST_ACCES_LIFETIME", "5m")
+	_, err = SettingsFromEnv("GWTTEST")
+
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), "unknown variables GWTTEST_ACCES_LIFETIME")
+}
+
+func TestRegisterStorageTwicePanics(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterStorage("config-test", func(config *viper.Viper) (StorageInterface, error) {
+			return nil, nil
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package gwt
+
+import "context"
+
+type contextKey struct{}
+
+// principalContextKey is the key of the principal in the request context
+var principalContextKey = contextKey{}
+
+func withPrincipal(ctx context.Context, p *principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+func principalFromContext(ctx context.Context) (*principal, bool) {
+	p, ok := ctx.Value(principalContextKey).(*principal)
+	return p, ok
+}
+
+// UserFromContext returns the user authenticated by auth middleware. With LazyUserLoading the user is loaded
+// with GetUserFunc on first call and kept in the context for the rest of the request.
+// ErrNotAuthUser is returned if the request is not authenticated or the token is issued to a client.
+func UserFromContext(ctx context.Context) (interface{}, error) {
+	p, ok := principalFromContext(ctx)
+	if !ok {
+		return nil, ErrNotAuthUser
+	}
+	return p.loadUser()
+}
+
+// UserIdFromContext returns id of the authenticated user, false is returned for client tokens
+func UserIdFromContext(ctx context.Context) (string, bool) {
+	p, ok := principalFromContext(ctx)
+	if !ok || p.client != nil {
+		return "", false
+	}
+	return p.userId, true
+}
+
+// ClaimsFromContext returns custom claims of the access token, nil if the request is not authenticated
+func ClaimsFromContext(ctx context.Context) map[string]interface{} {
+	p, ok := principalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return p.customClaims
+}
+
+// ClientFromContext returns client principal of the access token, false is returned for user tokens
+func ClientFromContext(ctx context.Context) (*ClientPrincipal, bool) {
+	p, ok := principalFromContext(ctx)
+	if !ok || p.client == nil {
+		return nil, false
+	}
+	return p.client, true
+}
+
+// ActorFromContext returns the admin impersonating the authenticated user, false is returned for regular tokens
+func ActorFromContext(ctx context.Context) (*Actor, bool) {
+	p, ok := principalFromContext(ctx)
+	if !ok || p.actor == nil {
+		return nil, false
+	}
+	return p.actor, true
+}
@@ -9,6 +9,9 @@ var (
 	// ErrFailedToCreateRefreshToken indicates refresh Token failed to create, reason unknown
 	ErrFailedToCreateRefreshToken = errors.New("failed to create refresh Token")
 
+	// ErrFailedToCreateMFAToken indicates mfa Token failed to create, reason unknown
+	ErrFailedToCreateMFAToken = errors.New("failed to create mfa Token")
+
 	// ErrEmptyAccessSecretKey indicates access secret key is empty
 	ErrEmptyAccessSecretKey = errors.New("empty access token secret key")
 
@@ -42,6 +45,12 @@ var (
 	// ErrRefreshTokenIsNotProvided indicates refresh token is not provided
 	ErrRefreshTokenIsNotProvided = errors.New("refresh token is not provided")
 
+	// ErrTokenIsNotProvided indicates token is not provided
+	ErrTokenIsNotProvided = errors.New("token is not provided")
+
+	// ErrEmptyClientAuthenticator indicates client authentication function is empty
+	ErrEmptyClientAuthenticator = errors.New("empty client authentication function")
+
 	// ErrNoAuthHeader indicates no auth header is provided
 	ErrNoAuthHeader = errors.New("no auth header provided")
 
@@ -53,4 +62,88 @@ var (
 
 	// ErrNotAuthUser indicates user is not authenticated
 	ErrNotAuthUser = errors.New("user is not authenticated")
+
+	// ErrSessionNotFound indicates session is not found in the storage
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrSessionExpired indicates session has reached its maximal lifetime
+	ErrSessionExpired = errors.New("session has expired")
+
+	// ErrUnsupportedStorage indicates storage does not implement the interface required by the feature
+	ErrUnsupportedStorage = errors.New("storage does not support this feature")
+
+	// ErrEmptyIdentifierFunc indicates login identifier function is empty
+	ErrEmptyIdentifierFunc = errors.New("empty login identifier function")
+
+	// ErrSecondFactorRequired should be returned by Authenticator with user id when second factor is required
+	ErrSecondFactorRequired = errors.New("second factor is required")
+
+	// ErrMFATokenIsNotProvided indicates mfa token or code is not provided
+	ErrMFATokenIsNotProvided = errors.New("mfa token or code is not provided")
+
+	// ErrInvalidMFACode indicates second factor code is not valid
+	ErrInvalidMFACode = errors.New("invalid second factor code")
+
+	// ErrMFATokenNotFound indicates mfa token is unknown, expired or has been used
+	ErrMFATokenNotFound = errors.New("mfa token not found")
+
+	// ErrMFANotEnrolled indicates user has no second factor enrolled
+	ErrMFANotEnrolled = errors.New("second factor is not enrolled")
+
+	// ErrTooManyLoginAttempts indicates login is locked out after too many failed attempts
+	ErrTooManyLoginAttempts = errors.New("too many login attempts")
+
+	// ErrClientNotFound indicates client is not registered
+	ErrClientNotFound = errors.New("client not found")
+
+	// ErrInvalidClient indicates client credentials are not valid
+	ErrInvalidClient = errors.New("invalid client credentials")
+
+	// ErrImpersonationNotRefreshable indicates impersonation tokens cannot be refreshed
+	ErrImpersonationNotRefreshable = errors.New("impersonation token cannot be refreshed")
+
+	// ErrImpersonationNotFound indicates impersonation session is not found
+	ErrImpersonationNotFound = errors.New("impersonation not found")
+
+	// ErrInvalidImpersonation indicates impersonation parameters are not valid
+	ErrInvalidImpersonation = errors.New("admin id, target user id, reason and lifetime are required")
+
+	// ErrImpersonationLifetimeExceeded indicates requested impersonation lifetime exceeds Settings.ImpersonationLifetime
+	ErrImpersonationLifetimeExceeded = errors.New("impersonation lifetime exceeds the allowed maximum")
+
+	// ErrEmptyForceLogoutAuthorizer indicates force logout authorization function is empty
+	ErrEmptyForceLogoutAuthorizer = errors.New("empty force logout authorization function")
+
+	// ErrInvalidScope indicates requested scope exceeds the granted one
+	ErrInvalidScope = errors.New("requested scope exceeds granted scope")
+
+	// ErrTicketNotFound indicates websocket ticket is unknown, expired or has been used
+	ErrTicketNotFound = errors.New("ticket not found")
+
+	// ErrInvalidConfig indicates configuration has unknown keys or invalid values
+	ErrInvalidConfig = errors.New("invalid config")
+
+	// ErrShortSecretKey indicates secret key is shorter than the hash of the signing method
+	ErrShortSecretKey = errors.New("secret key is too short")
+
+	// ErrRefreshSecretReused indicates refresh tokens are signed with the access secret key
+	ErrRefreshSecretReused = errors.New("refresh secret key equals access secret key")
+
+	// ErrInvalidLifetime indicates access lifetime is not shorter than refresh lifetime
+	ErrInvalidLifetime = errors.New("access lifetime must be shorter than refresh lifetime")
+
+	// ErrInvalidImpersonationLifetime indicates impersonation lifetime is negative
+	ErrInvalidImpersonationLifetime = errors.New("impersonation lifetime must be positive")
+
+	// ErrInvalidEncryptionKey indicates token encryption key is not 16, 24 or 32 bytes long
+	ErrInvalidEncryptionKey = errors.New("token encryption key must be 16, 24 or 32 bytes long")
+
+	// ErrTokenNotFound indicates opaque token is unknown, has been revoked or has expired
+	ErrTokenNotFound = errors.New("token not found")
+
+	// ErrUnknownTokenFormat indicates unknown token format provided
+	ErrUnknownTokenFormat = errors.New("unknown token format provided")
+
+	// ErrInvalidHeaderName indicates auth header name or scheme is not a valid http token
+	ErrInvalidHeaderName = errors.New("invalid header name")
 )
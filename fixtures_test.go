@@ -33,14 +33,190 @@ func (m *storageMock) DeleteAllTokens(userId string) error {
 	return args.Error(0)
 }
 
+type attemptStorageMock struct {
+	storageMock
+}
+
+func (m *attemptStorageMock) AddLoginAttempt(key string, expire int64) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *attemptStorageMock) GetLoginAttempts(key string) (int64, int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+func (m *attemptStorageMock) DeleteLoginAttempts(key string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type mfaStorageMock struct {
+	attemptStorageMock
+}
+
+func (m *mfaStorageMock) SaveMFASecret(userId string, secret string, recoveryCodes []string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+func (m *mfaStorageMock) GetMFASecret(userId string) (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+func (m *mfaStorageMock) UseMFARecoveryCode(userId string, recoveryCode string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+func (m *mfaStorageMock) DeleteMFASecret(userId string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+func (m *mfaStorageMock) UseMFAStep(userId string, step int64) error {
+	args := m.Called()
+	return args.Error(0)
+}
+func (m *mfaStorageMock) SaveMFAToken(tokenId string, expire int64) error {
+	args := m.Called()
+	return args.Error(0)
+}
+func (m *mfaStorageMock) UseMFAToken(tokenId string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type sessionStorageMock struct {
+	storageMock
+}
+
+type purgeStorageMock struct {
+	storageMock
+}
+
+func (m *purgeStorageMock) PurgeExpired() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *sessionStorageMock) SaveSession(session *Session, expire int64) error {
+	args := m.Called()
+	return args.Error(0)
+}
+func (m *sessionStorageMock) GetSession(userId string, sessionId string) (*Session, error) {
+	args := m.Called()
+	session, _ := args.Get(0).(*Session)
+	return session, args.Error(1)
+}
+func (m *sessionStorageMock) DeleteSession(userId string, sessionId string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *sessionStorageMock) GetSessions(userId string) ([]*Session, error) {
+	args := m.Called()
+	sessions, _ := args.Get(0).([]*Session)
+	return sessions, args.Error(1)
+}
+
+type accessTokenStorageMock struct {
+	sessionStorageMock
+}
+
+func (m *accessTokenStorageMock) SaveAccessToken(userId string, accessUuid string, accessExpire int64,
+	accessToken string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type impersonationStorageMock struct {
+	storageMock
+}
+
+func (m *impersonationStorageMock) SaveImpersonation(impersonation *Impersonation, expire int64) error {
+	args := m.Called()
+	return args.Error(0)
+}
+func (m *impersonationStorageMock) GetImpersonations() ([]*Impersonation, error) {
+	args := m.Called()
+	impersonations, _ := args.Get(0).([]*Impersonation)
+	return impersonations, args.Error(1)
+}
+func (m *impersonationStorageMock) DeleteImpersonation(id string) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type sessionRemovalStorageMock struct {
+	storageMock
+}
+
+func (m *sessionRemovalStorageMock) DeleteAllSessions(userId string) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *sessionRemovalStorageMock) DeleteAllTokensExcept(userId string, accessUuid string, refreshUuid string) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type ticketStorageMock struct {
+	storageMock
+}
+
+func (m *ticketStorageMock) SaveTicket(ticketHash string, accessToken string, expire int64) error {
+	args := m.Called()
+	return args.Error(0)
+}
+func (m *ticketStorageMock) UseTicket(ticketHash string) (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+// opaqueStorageMock keeps saved opaque tokens, so they are resolved by GetOpaqueToken until they expire
+type opaqueStorageMock struct {
+	storageMock
+	opaqueTokens map[string]*OpaqueToken
+	lookups      int
+}
+
+func newOpaqueStorageMock() *opaqueStorageMock {
+	return &opaqueStorageMock{opaqueTokens: map[string]*OpaqueToken{}}
+}
+
+func (m *opaqueStorageMock) SaveOpaqueToken(tokenHash string, token *OpaqueToken) error {
+	args := m.Called()
+	if args.Error(0) == nil {
+		m.opaqueTokens[tokenHash] = token
+	}
+	return args.Error(0)
+}
+func (m *opaqueStorageMock) GetOpaqueToken(tokenHash string) (*OpaqueToken, error) {
+	m.lookups++
+	token, ok := m.opaqueTokens[tokenHash]
+	if !ok || token.Expire <= time.Now().Unix() {
+		return nil, ErrTokenNotFound
+	}
+	return token, nil
+}
+func (m *opaqueStorageMock) DeleteOpaqueTokens(uuids ...string) error {
+	args := m.Called()
+	for tokenHash, token := range m.opaqueTokens {
+		for _, uuid := range uuids {
+			if token.Uuid == uuid {
+				delete(m.opaqueTokens, tokenHash)
+			}
+		}
+	}
+	return args.Error(0)
+}
+
 func getSettingsFixture() *Settings {
 	return &Settings{
-		SigningMethod:    "HS256",
-		AccessSecretKey:  []byte("super_secret"),
-		RefreshSecretKey: []byte("super_secret"),
-		AccessLifetime:   time.Minute * 1,
-		RefreshLifetime:  time.Minute * 2,
-		AuthHeadName:     "Bearer",
+		SigningMethod:         "HS256",
+		AccessSecretKey:       []byte("super_secret_access_key_of_32_bytes"),
+		RefreshSecretKey:      []byte("super_secret_refresh_key_of_32_bytes"),
+		AccessLifetime:        time.Minute * 1,
+		RefreshLifetime:       time.Minute * 2,
+		AuthHeadName:          "Bearer",
+		ImpersonationLifetime: time.Hour,
 		Authenticator: func(c *gin.Context) (string, error) {
 			return "1", nil
 		},
@@ -70,3 +246,21 @@ func getSettingsFixture() *Settings {
 		AdditionalAuthHeader: "x-auth-token",
 	}
 }
+
+// fixedClock returns the time it is set to
+type fixedClock struct {
+	now time.Time
+}
+
+func (clock *fixedClock) Now() time.Time {
+	return clock.now
+}
+
+type clockStorageMock struct {
+	storageMock
+	clock Clock
+}
+
+func (m *clockStorageMock) SetClock(clock Clock) {
+	m.clock = clock
+}
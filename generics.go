@@ -0,0 +1,44 @@
+package gwt
+
+import "github.com/gin-gonic/gin"
+
+// TypedSettings are Settings with GetUserFunc returning the application user type
+type TypedSettings[U any] struct {
+	Settings
+
+	// GetUserFunc is function that returns application user model. Required.
+	GetUserFunc func(userId string) (U, error)
+}
+
+// New initializes gwt with typed GetUserFunc, use User and MustUser to get the user of type U from the context
+func New[U any](settings TypedSettings[U]) (*Gwt, error) {
+	if settings.GetUserFunc == nil {
+		return nil, ErrEmptyGetUserFunc
+	}
+	getUserFunc := settings.GetUserFunc
+	settings.Settings.GetUserFunc = func(userId string) (interface{}, error) {
+		return getUserFunc(userId)
+	}
+	return Init(settings.Settings)
+}
+
+// User returns the user authenticated by auth middleware, the user is loaded if LazyUserLoading is set.
+// False is returned if there is no user or it is not of type U.
+func User[U any](c *gin.Context) (U, bool) {
+	var empty U
+	user, err := LoadUser(c)
+	if err != nil {
+		return empty, false
+	}
+	typed, ok := user.(U)
+	return typed, ok
+}
+
+// MustUser returns the user authenticated by auth middleware, it panics if there is no user of type U
+func MustUser[U any](c *gin.Context) U {
+	user, ok := User[U](c)
+	if !ok {
+		panic(ErrNotAuthUser)
+	}
+	return user
+}
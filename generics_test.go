@@ -0,0 +1,64 @@
+package gwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testUser struct {
+	Id string
+}
+
+func TestNewTypedGetUserFunc(t *testing.T) {
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	settings := *getSettingsFixture()
+	settings.Storage = strgMock
+	settings.GetUserFunc = nil
+	auth, err := New(TypedSettings[*testUser]{
+		Settings: settings,
+		GetUserFunc: func(userId string) (*testUser, error) {
+			return &testUser{Id: userId}, nil
+		},
+	})
+	assert.Nil(t, err)
+
+	accessData, _ := (&tokenService{})._createAccessToken(&settings, &tokenParams{userId: "1"}, "access", "refresh")
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	var user *testUser
+	var ok bool
+	var wrongTypeOk bool
+	router.Use(auth.Middleware.GetAuthMiddleware()).GET("/test-auth", func(c *gin.Context) {
+		user, ok = User[*testUser](c)
+		_, wrongTypeOk = User[string](c)
+		c.JSON(http.StatusOK, gin.H{"id": MustUser[*testUser](c).Id})
+	})
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, ok)
+	assert.False(t, wrongTypeOk)
+	assert.Equal(t, &testUser{Id: "1"}, user)
+}
+
+func TestNewEmptyGetUserFuncError(t *testing.T) {
+	_, err := New(TypedSettings[*testUser]{Settings: *getSettingsFixture()})
+
+	assert.Equal(t, ErrEmptyGetUserFunc, err)
+}
+
+func TestMustUserPanics(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	assert.Panics(t, func() {
+		MustUser[*testUser](c)
+	})
+}
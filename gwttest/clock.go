@@ -0,0 +1,37 @@
+package gwttest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is gwt.Clock controlled by the test, time moves only by Advance and Set
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock creates the clock stopped at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+// Advance moves the clock forward by the duration, e.g. past AccessLifetime to expire issued tokens
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(d)
+}
+
+// Set moves the clock to the time
+func (clock *FakeClock) Set(now time.Time) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = now
+}
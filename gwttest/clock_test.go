@@ -0,0 +1,25 @@
+package gwttest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	assert.Equal(t, time.Unix(1000, 0), clock.Now())
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, time.Unix(1060, 0), clock.Now())
+
+	clock.Set(time.Unix(10, 0))
+	assert.Equal(t, time.Unix(10, 0), clock.Now())
+}
+
+func TestNewSetsFakeClock(t *testing.T) {
+	settings := NewSettings()
+	env := New(t, settings)
+
+	assert.Same(t, settings.Clock, env.Clock)
+}
@@ -0,0 +1,147 @@
+// Package gwttest provides gwt settings with in-memory storage, token minting and assertion helpers for tests
+// of applications using gwt.
+package gwttest
+
+import (
+	"encoding/json"
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret signs access tokens of NewSettings
+var Secret = []byte("gwttest-secret-key-of-at-least-32-bytes")
+
+// RefreshSecret signs refresh tokens of NewSettings
+var RefreshSecret = []byte("gwttest-refresh-secret-key-of-32-bytes")
+
+// NewSettings returns settings for tests: HS256 tokens signed with Secret and RefreshSecret, in-memory storage, FakeClock stopped
+// at current time, Authenticator rejecting every login and GetUserFunc returning user id as the user.
+// Adjust the settings before passing them to New.
+func NewSettings() gwt.Settings {
+	return gwt.Settings{
+		SigningMethod:    "HS256",
+		AccessSecretKey:  Secret,
+		RefreshSecretKey: RefreshSecret,
+		Authenticator: func(c *gin.Context) (string, error) {
+			return "", gwt.ErrNotAuthUser
+		},
+		GetUserFunc: func(userId string) (interface{}, error) {
+			return userId, nil
+		},
+		Storage: NewMemoryStorage(),
+		Clock:   NewFakeClock(time.Now()),
+	}
+}
+
+// Env is gwt initialized for tests, helpers fail the test on errors
+type Env struct {
+	*gwt.Gwt
+
+	// Storage is the storage of the settings, *MemoryStorage for NewSettings
+	Storage gwt.StorageInterface
+
+	// Clock is the clock of the settings if it is *FakeClock, advance it to expire tokens, sessions and lockouts
+	Clock *FakeClock
+
+	t            testing.TB
+	authHeadName string
+}
+
+// New initializes gwt with the settings, use NewSettings to get settings with in-memory storage
+func New(t testing.TB, settings gwt.Settings) *Env {
+	t.Helper()
+	auth, err := gwt.Init(settings)
+	if err != nil {
+		t.Fatalf("gwttest: init: %v", err)
+	}
+	authHeadName := settings.AuthHeadName
+	if authHeadName == "" {
+		authHeadName = "Bearer"
+	}
+	clock, _ := settings.Clock.(*FakeClock)
+	return &Env{Gwt: auth, Storage: settings.Storage, Clock: clock, t: t, authHeadName: authHeadName}
+}
+
+// MintAccessToken issues and saves a valid access token of the user with custom claims
+func (env *Env) MintAccessToken(userId string, claims map[string]interface{}) string {
+	env.t.Helper()
+	return env.MintTokens(&gwt.AuthResult{UserId: userId, Claims: claims}).AccessToken
+}
+
+// MintTokens issues and saves the token pair of the authentication result
+func (env *Env) MintTokens(result *gwt.AuthResult) *gwt.Tokens {
+	env.t.Helper()
+	tokens, err := env.Service.IssueTokens(result)
+	if err != nil {
+		env.t.Fatalf("gwttest: issue tokens: %v", err)
+	}
+	return tokens
+}
+
+// ExpiredAccessToken returns access token of the user which has already expired
+func (env *Env) ExpiredAccessToken(userId string) string {
+	env.t.Helper()
+	return env.MintTokens(&gwt.AuthResult{UserId: userId, AccessLifetime: -time.Minute}).AccessToken
+}
+
+// TamperedAccessToken returns access token of the user with broken signature
+func (env *Env) TamperedAccessToken(userId string) string {
+	env.t.Helper()
+	token := env.MintAccessToken(userId, nil)
+	signatureStart := strings.LastIndex(token, ".") + 1
+	replacement := "A"
+	if token[signatureStart:signatureStart+1] == replacement {
+		replacement = "B"
+	}
+	return token[:signatureStart] + replacement + token[signatureStart+1:]
+}
+
+// WithAuth sets authorization header with a new access token of the user to the request and returns it
+func (env *Env) WithAuth(req *http.Request, userId string) *http.Request {
+	env.t.Helper()
+	req.Header.Set("Authorization", env.authHeadName+" "+env.MintAccessToken(userId, nil))
+	return req
+}
+
+// AssertErrResponse checks that the response has the status code and DefaultErrResponse body with the error
+func AssertErrResponse(t testing.TB, rr *httptest.ResponseRecorder, code int, err error) bool {
+	t.Helper()
+	res, ok := decodeErrResponse(t, rr)
+	if !ok {
+		return false
+	}
+	if rr.Code != code || res.ErrorCode != code || res.ErrorMessage != err.Error() {
+		t.Errorf("gwttest: expected %d %q, got %d %+v", code, err.Error(), rr.Code, res)
+		return false
+	}
+	return true
+}
+
+// AssertErrCode checks that the response has the status code and DefaultErrResponse body with it
+func AssertErrCode(t testing.TB, rr *httptest.ResponseRecorder, code int) bool {
+	t.Helper()
+	res, ok := decodeErrResponse(t, rr)
+	if !ok {
+		return false
+	}
+	if rr.Code != code || res.ErrorCode != code {
+		t.Errorf("gwttest: expected %d, got %d %+v", code, rr.Code, res)
+		return false
+	}
+	return true
+}
+
+func decodeErrResponse(t testing.TB, rr *httptest.ResponseRecorder) (gwt.DefaultErrResponse, bool) {
+	t.Helper()
+	res := gwt.DefaultErrResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Errorf("gwttest: response body %q is not DefaultErrResponse: %v", rr.Body.String(), err)
+		return res, false
+	}
+	return res, true
+}
@@ -0,0 +1,181 @@
+package gwttest
+
+import (
+	"encoding/json"
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(env *Env, request *http.Request) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(env.Middleware.GetAuthMiddleware()).GET("/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user": c.MustGet(gwt.UserKey), "claims": c.MustGet(gwt.ClaimsKey)})
+	})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	return rr
+}
+
+func TestWithAuth(t *testing.T) {
+	env := New(t, NewSettings())
+	rr := serve(env, env.WithAuth(httptest.NewRequest(http.MethodGet, "/me", nil), "1"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"user": "1", "claims": {}}`, rr.Body.String())
+}
+
+func TestMintAccessTokenClaims(t *testing.T) {
+	env := New(t, NewSettings())
+	request := httptest.NewRequest(http.MethodGet, "/me", nil)
+	request.Header.Set("Authorization", "Bearer "+env.MintAccessToken("1", map[string]interface{}{"role": "admin"}))
+	rr := serve(env, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"user": "1", "claims": {"role": "admin"}}`, rr.Body.String())
+}
+
+func TestExpiredAccessTokenRejected(t *testing.T) {
+	env := New(t, NewSettings())
+	request := httptest.NewRequest(http.MethodGet, "/me", nil)
+	request.Header.Set("Authorization", "Bearer "+env.ExpiredAccessToken("1"))
+	rr := serve(env, request)
+
+	AssertErrResponse(t, rr, http.StatusUnauthorized, gwt.ErrTokenExpired)
+}
+
+func TestAccessTokenExpiresWithClock(t *testing.T) {
+	env := New(t, NewSettings())
+	request := env.WithAuth(httptest.NewRequest(http.MethodGet, "/me", nil), "1")
+	assert.Equal(t, http.StatusOK, serve(env, request).Code)
+
+	env.Clock.Advance(10 * time.Minute)
+	AssertErrResponse(t, serve(env, request), http.StatusUnauthorized, gwt.ErrTokenExpired)
+}
+
+func TestTamperedAccessTokenRejected(t *testing.T) {
+	env := New(t, NewSettings())
+	request := httptest.NewRequest(http.MethodGet, "/me", nil)
+	request.Header.Set("Authorization", "Bearer "+env.TamperedAccessToken("1"))
+	rr := serve(env, request)
+
+	AssertErrResponse(t, rr, http.StatusBadRequest, gwt.ErrTokenInvalid)
+}
+
+func TestOpaqueTokens(t *testing.T) {
+	settings := NewSettings()
+	settings.TokenFormat = gwt.TokenFormatOpaque
+	env := New(t, settings)
+	tokens := env.MintTokens(&gwt.AuthResult{UserId: "1", Claims: map[string]interface{}{"tenant": "acme"}})
+	request := httptest.NewRequest(http.MethodGet, "/me", nil)
+	request.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	rr := serve(env, request)
+
+	assert.NotContains(t, tokens.AccessToken, ".")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"user": "1", "claims": {"tenant": "acme"}}`, rr.Body.String())
+
+	router := gin.New()
+	router.POST("/refresh", env.Handler.GetRefreshHandler())
+	refreshRequest := httptest.NewRequest(http.MethodPost, "/refresh",
+		strings.NewReader(`{"refresh_token": "`+tokens.RefreshToken+`"}`))
+	refreshRequest.Header.Set("Content-Type", "application/json")
+	refreshRR := httptest.NewRecorder()
+	router.ServeHTTP(refreshRR, refreshRequest)
+	refreshed := gwt.DefaultLoginResponse{}
+	_ = json.Unmarshal(refreshRR.Body.Bytes(), &refreshed)
+
+	assert.Equal(t, http.StatusOK, refreshRR.Code)
+	AssertErrCode(t, serve(env, request), http.StatusUnauthorized)
+	request.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+	assert.JSONEq(t, `{"user": "1", "claims": {"tenant": "acme"}}`, serve(env, request).Body.String())
+
+	env.Clock.Advance(10 * time.Minute)
+	AssertErrResponse(t, serve(env, request), http.StatusUnauthorized, gwt.ErrTokenExpired)
+}
+
+func TestRevokeRefreshTokenRevokesAccessToken(t *testing.T) {
+	env := New(t, NewSettings())
+	tokens := env.MintTokens(&gwt.AuthResult{UserId: "1"})
+	request := httptest.NewRequest(http.MethodGet, "/me", nil)
+	request.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	assert.Equal(t, http.StatusOK, serve(env, request).Code)
+
+	router := gin.New()
+	router.POST("/revoke", env.Handler.GetRevocationHandler())
+	revokeRequest := httptest.NewRequest(http.MethodPost, "/revoke",
+		strings.NewReader("token="+tokens.RefreshToken+"&token_type_hint=refresh_token"))
+	revokeRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	revokeRR := httptest.NewRecorder()
+	router.ServeHTTP(revokeRR, revokeRequest)
+
+	assert.Equal(t, http.StatusOK, revokeRR.Code)
+	AssertErrResponse(t, serve(env, request), http.StatusUnauthorized, gwt.ErrTokenExpired)
+}
+
+func TestRevokeClientCredentialsToken(t *testing.T) {
+	for _, format := range []gwt.TokenFormat{gwt.TokenFormatJWT, gwt.TokenFormatOpaque} {
+		settings := NewSettings()
+		settings.TokenFormat = format
+		settings.Clients = gwt.NewClientRegistry(gwt.Client{Id: "worker", SecretHash: gwt.HashClientSecret("secret")})
+		env := New(t, settings)
+		router := gin.New()
+		router.POST("/token", env.Handler.GetTokenEndpointHandler())
+		router.POST("/revoke", env.Handler.GetRevocationHandler())
+		router.Use(env.Middleware.GetAuthMiddleware()).GET("/client", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{})
+		})
+		post := func(path string, body string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request.SetBasicAuth("worker", "secret")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, request)
+			return rr
+		}
+		tokenRR := post("/token", "grant_type=client_credentials")
+		tokens := gwt.OAuth2TokenResponse{}
+		_ = json.Unmarshal(tokenRR.Body.Bytes(), &tokens)
+		request := httptest.NewRequest(http.MethodGet, "/client", nil)
+		request.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		assert.Equal(t, http.StatusOK, rr.Code, format)
+
+		assert.Equal(t, http.StatusOK, post("/revoke", "token="+tokens.AccessToken).Code, format)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		AssertErrCode(t, rr, http.StatusUnauthorized)
+	}
+}
+
+func TestImpersonationIsNotUserSession(t *testing.T) {
+	env := New(t, NewSettings())
+	env.MintTokens(&gwt.AuthResult{UserId: "1"})
+	tokens, err := env.Service.Impersonate("admin", "1", "ticket 42", 5*time.Minute)
+	assert.Nil(t, err)
+
+	sessions, _ := env.Service.ListSessions("1")
+	assert.Len(t, sessions, 1)
+	assert.NotEqual(t, tokens.Impersonation.Id, sessions[0].Id)
+	impersonations, _ := env.Service.ListImpersonations()
+	assert.Len(t, impersonations, 1)
+}
+
+func TestAssertErrResponse(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.WriteHeader(http.StatusUnauthorized)
+	_, _ = rr.WriteString(`{"error_code": 401, "error_message": "token has expired"}`)
+	mockT := &testing.T{}
+
+	assert.True(t, AssertErrResponse(mockT, rr, http.StatusUnauthorized, gwt.ErrTokenExpired))
+	assert.True(t, AssertErrCode(mockT, rr, http.StatusUnauthorized))
+	assert.False(t, AssertErrResponse(mockT, rr, http.StatusUnauthorized, gwt.ErrTokenInvalid))
+	assert.False(t, AssertErrCode(mockT, rr, http.StatusBadRequest))
+}
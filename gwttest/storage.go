@@ -0,0 +1,398 @@
+package gwttest
+
+import (
+	"encoding/json"
+	"github.com/ennaque/go-gin-jwt"
+	"sync"
+	"time"
+)
+
+// MemoryStorage is in-memory gwt storage for tests, it implements all optional storage interfaces.
+// Expired entries are treated as missing.
+type MemoryStorage struct {
+	mu             sync.Mutex
+	tokens         map[string]*memoryToken
+	sessions       map[string]*memorySession
+	attempts       map[string]*memoryAttempts
+	mfaSecrets     map[string]string
+	mfaCodes       map[string]map[string]bool
+	mfaSteps       map[string]int64
+	mfaTokens      map[string]int64
+	impersonations map[string]*memoryImpersonation
+	tickets        map[string]*memoryTicket
+	opaqueTokens   map[string][]byte
+	clock          gwt.Clock
+}
+
+type memoryToken struct {
+	userId    string
+	token     string
+	tokenType string
+	expire    int64
+}
+
+type memorySession struct {
+	session gwt.Session
+	expire  int64
+}
+
+type memoryAttempts struct {
+	count  int64
+	last   int64
+	expire int64
+}
+
+type memoryImpersonation struct {
+	impersonation gwt.Impersonation
+	expire        int64
+}
+
+type memoryTicket struct {
+	accessToken string
+	expire      int64
+}
+
+// NewMemoryStorage creates empty in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		tokens:         map[string]*memoryToken{},
+		sessions:       map[string]*memorySession{},
+		attempts:       map[string]*memoryAttempts{},
+		mfaSecrets:     map[string]string{},
+		mfaCodes:       map[string]map[string]bool{},
+		mfaSteps:       map[string]int64{},
+		mfaTokens:      map[string]int64{},
+		impersonations: map[string]*memoryImpersonation{},
+		tickets:        map[string]*memoryTicket{},
+		opaqueTokens:   map[string][]byte{},
+	}
+}
+
+func (ms *MemoryStorage) DeleteTokens(userId string, uuid ...string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, id := range uuid {
+		if data, ok := ms.tokens[id]; ok && data.userId == userId {
+			delete(ms.tokens, id)
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStorage) SaveTokens(userId string, accessUuid string, refreshUuid string, accessExpire int64,
+	refreshExpire int64, accessToken string, refreshToken string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.tokens[accessUuid] = &memoryToken{userId: userId, token: accessToken, tokenType: "access", expire: accessExpire}
+	ms.tokens[refreshUuid] = &memoryToken{userId: userId, token: refreshToken, tokenType: "refresh", expire: refreshExpire}
+	return nil
+}
+
+func (ms *MemoryStorage) SaveAccessToken(userId string, accessUuid string, accessExpire int64, accessToken string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.tokens[accessUuid] = &memoryToken{userId: userId, token: accessToken, tokenType: "access", expire: accessExpire}
+	return nil
+}
+
+func (ms *MemoryStorage) HasRefreshToken(uuid string, token string, userId string) error {
+	return ms.hasToken(uuid, token, userId, "refresh")
+}
+
+func (ms *MemoryStorage) HasAccessToken(uuid string, token string, userId string) error {
+	return ms.hasToken(uuid, token, userId, "access")
+}
+
+func (ms *MemoryStorage) DeleteAllTokens(userId string) error {
+	_, err := ms.DeleteAllSessions(userId)
+	return err
+}
+
+// DeleteAllSessions deletes tokens and sessions of the user, every refresh token is counted as a session
+func (ms *MemoryStorage) DeleteAllSessions(userId string) (int64, error) {
+	return ms.deleteUserTokens(userId, "", ""), nil
+}
+
+func (ms *MemoryStorage) DeleteAllTokensExcept(userId string, accessUuid string, refreshUuid string) (int64, error) {
+	return ms.deleteUserTokens(userId, accessUuid, refreshUuid), nil
+}
+
+func (ms *MemoryStorage) SaveSession(session *gwt.Session, expire int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.sessions[session.UserId+"_"+session.Id] = &memorySession{session: *session, expire: expire}
+	return nil
+}
+
+func (ms *MemoryStorage) GetSession(userId string, sessionId string) (*gwt.Session, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	data, ok := ms.sessions[userId+"_"+sessionId]
+	if !ok || data.expire <= ms.now().Unix() {
+		return nil, gwt.ErrSessionNotFound
+	}
+	session := data.session
+	return &session, nil
+}
+
+func (ms *MemoryStorage) GetSessions(userId string) ([]*gwt.Session, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	sessions := []*gwt.Session{}
+	for _, data := range ms.sessions {
+		if data.session.UserId == userId && data.expire > ms.now().Unix() {
+			session := data.session
+			sessions = append(sessions, &session)
+		}
+	}
+	return sessions, nil
+}
+
+func (ms *MemoryStorage) DeleteSession(userId string, sessionId string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.sessions, userId+"_"+sessionId)
+	return nil
+}
+
+func (ms *MemoryStorage) AddLoginAttempt(key string, expire int64) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := ms.now().Unix()
+	data, ok := ms.attempts[key]
+	if !ok || data.expire <= now {
+		data = &memoryAttempts{}
+		ms.attempts[key] = data
+	}
+	data.count++
+	data.last = now
+	data.expire = expire
+	return data.count, nil
+}
+
+func (ms *MemoryStorage) GetLoginAttempts(key string) (int64, int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	data, ok := ms.attempts[key]
+	if !ok || data.expire <= ms.now().Unix() {
+		return 0, 0, nil
+	}
+	return data.count, data.last, nil
+}
+
+func (ms *MemoryStorage) DeleteLoginAttempts(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.attempts, key)
+	return nil
+}
+
+func (ms *MemoryStorage) SaveMFASecret(userId string, secret string, recoveryCodes []string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.mfaSecrets[userId] = secret
+	ms.mfaCodes[userId] = map[string]bool{}
+	for _, code := range recoveryCodes {
+		ms.mfaCodes[userId][code] = true
+	}
+	return nil
+}
+
+func (ms *MemoryStorage) GetMFASecret(userId string) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	secret, ok := ms.mfaSecrets[userId]
+	if !ok {
+		return "", gwt.ErrMFANotEnrolled
+	}
+	return secret, nil
+}
+
+func (ms *MemoryStorage) UseMFARecoveryCode(userId string, recoveryCode string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if !ms.mfaCodes[userId][recoveryCode] {
+		return gwt.ErrInvalidMFACode
+	}
+	delete(ms.mfaCodes[userId], recoveryCode)
+	return nil
+}
+
+func (ms *MemoryStorage) DeleteMFASecret(userId string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.mfaSecrets, userId)
+	delete(ms.mfaCodes, userId)
+	delete(ms.mfaSteps, userId)
+	return nil
+}
+
+func (ms *MemoryStorage) UseMFAStep(userId string, step int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if lastStep, ok := ms.mfaSteps[userId]; ok && lastStep >= step {
+		return gwt.ErrInvalidMFACode
+	}
+	ms.mfaSteps[userId] = step
+	return nil
+}
+
+func (ms *MemoryStorage) SaveMFAToken(tokenId string, expire int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.mfaTokens[tokenId] = expire
+	return nil
+}
+
+func (ms *MemoryStorage) UseMFAToken(tokenId string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	expire, ok := ms.mfaTokens[tokenId]
+	delete(ms.mfaTokens, tokenId)
+	if !ok || expire <= ms.now().Unix() {
+		return gwt.ErrMFATokenNotFound
+	}
+	return nil
+}
+
+func (ms *MemoryStorage) SaveImpersonation(impersonation *gwt.Impersonation, expire int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.impersonations[impersonation.Id] = &memoryImpersonation{impersonation: *impersonation, expire: expire}
+	return nil
+}
+
+func (ms *MemoryStorage) GetImpersonations() ([]*gwt.Impersonation, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var impersonations []*gwt.Impersonation
+	for _, data := range ms.impersonations {
+		if data.expire > ms.now().Unix() {
+			impersonation := data.impersonation
+			impersonations = append(impersonations, &impersonation)
+		}
+	}
+	return impersonations, nil
+}
+
+func (ms *MemoryStorage) DeleteImpersonation(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.impersonations, id)
+	return nil
+}
+
+func (ms *MemoryStorage) SaveTicket(ticketHash string, accessToken string, expire int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.tickets[ticketHash] = &memoryTicket{accessToken: accessToken, expire: expire}
+	return nil
+}
+
+func (ms *MemoryStorage) UseTicket(ticketHash string) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	data, ok := ms.tickets[ticketHash]
+	delete(ms.tickets, ticketHash)
+	if !ok || data.expire <= ms.now().Unix() {
+		return "", gwt.ErrTicketNotFound
+	}
+	return data.accessToken, nil
+}
+
+// SaveOpaqueToken keeps the token as json, so claims are read back with the types real storages return
+func (ms *MemoryStorage) SaveOpaqueToken(tokenHash string, token *gwt.OpaqueToken) error {
+	value, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.opaqueTokens[tokenHash] = value
+	return nil
+}
+
+func (ms *MemoryStorage) GetOpaqueToken(tokenHash string) (*gwt.OpaqueToken, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	value, ok := ms.opaqueTokens[tokenHash]
+	if !ok {
+		return nil, gwt.ErrTokenNotFound
+	}
+	token := &gwt.OpaqueToken{}
+	if err := json.Unmarshal(value, token); err != nil {
+		return nil, err
+	}
+	if token.Expire <= ms.now().Unix() {
+		return nil, gwt.ErrTokenNotFound
+	}
+	return token, nil
+}
+
+func (ms *MemoryStorage) DeleteOpaqueTokens(uuids ...string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for tokenHash, value := range ms.opaqueTokens {
+		token := &gwt.OpaqueToken{}
+		if err := json.Unmarshal(value, token); err != nil {
+			return err
+		}
+		for _, uuid := range uuids {
+			if uuid != "" && token.Uuid == uuid {
+				delete(ms.opaqueTokens, tokenHash)
+			}
+		}
+	}
+	return nil
+}
+
+// SetClock sets the clock entries expire by, it is called by gwt.Init with Settings.Clock
+func (ms *MemoryStorage) SetClock(clock gwt.Clock) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.clock = clock
+}
+
+// now returns time of the clock, callers hold the lock
+func (ms *MemoryStorage) now() time.Time {
+	if ms.clock == nil {
+		return time.Now()
+	}
+	return ms.clock.Now()
+}
+
+func (ms *MemoryStorage) hasToken(uuid string, token string, userId string, tokenType string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	data, ok := ms.tokens[uuid]
+	if !ok || data.userId != userId || data.tokenType != tokenType || data.expire <= ms.now().Unix() {
+		return gwt.ErrTokenExpired
+	}
+	if data.token != token {
+		return gwt.ErrTokenInvalid
+	}
+	return nil
+}
+
+// deleteUserTokens deletes tokens and sessions of the user except the given token pair and its session,
+// returns the number of removed refresh tokens
+func (ms *MemoryStorage) deleteUserTokens(userId string, accessUuid string, refreshUuid string) int64 {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var removed int64
+	for uuid, data := range ms.tokens {
+		if data.userId != userId || uuid == accessUuid || uuid == refreshUuid {
+			continue
+		}
+		if data.tokenType == "refresh" {
+			removed++
+		}
+		delete(ms.tokens, uuid)
+	}
+	for key, data := range ms.sessions {
+		if data.session.UserId == userId && (refreshUuid == "" || data.session.RefreshUuid != refreshUuid) {
+			delete(ms.sessions, key)
+		}
+	}
+	return removed
+}
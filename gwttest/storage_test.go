@@ -0,0 +1,165 @@
+package gwttest
+
+import (
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorageTokens(t *testing.T) {
+	storage := NewMemoryStorage()
+	expire := time.Now().Add(time.Minute).Unix()
+	_ = storage.SaveTokens("1", "access", "refresh", expire, expire, "access_token", "refresh_token")
+
+	assert.Nil(t, storage.HasAccessToken("access", "access_token", "1"))
+	assert.Nil(t, storage.HasRefreshToken("refresh", "refresh_token", "1"))
+	assert.Equal(t, gwt.ErrTokenInvalid, storage.HasAccessToken("access", "other_token", "1"))
+	assert.Equal(t, gwt.ErrTokenExpired, storage.HasAccessToken("refresh", "refresh_token", "1"))
+	assert.Equal(t, gwt.ErrTokenExpired, storage.HasAccessToken("access", "access_token", "2"))
+
+	_ = storage.DeleteTokens("1", "access", "refresh")
+	assert.Equal(t, gwt.ErrTokenExpired, storage.HasAccessToken("access", "access_token", "1"))
+}
+
+func TestMemoryStorageExpiredToken(t *testing.T) {
+	storage := NewMemoryStorage()
+	_ = storage.SaveTokens("1", "access", "refresh", time.Now().Unix(), time.Now().Unix(), "access_token", "refresh_token")
+
+	assert.Equal(t, gwt.ErrTokenExpired, storage.HasAccessToken("access", "access_token", "1"))
+}
+
+func TestMemoryStorageExpiresByClock(t *testing.T) {
+	storage := NewMemoryStorage()
+	clock := NewFakeClock(time.Unix(1000, 0))
+	storage.SetClock(clock)
+	_ = storage.SaveTokens("1", "access", "refresh", 1060, 1060, "access_token", "refresh_token")
+	assert.Nil(t, storage.HasAccessToken("access", "access_token", "1"))
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, gwt.ErrTokenExpired, storage.HasAccessToken("access", "access_token", "1"))
+}
+
+func TestMemoryStorageDeleteAllTokensExcept(t *testing.T) {
+	storage := NewMemoryStorage()
+	expire := time.Now().Add(time.Minute).Unix()
+	_ = storage.SaveTokens("1", "a1", "r1", expire, expire, "a1_token", "r1_token")
+	_ = storage.SaveTokens("1", "a2", "r2", expire, expire, "a2_token", "r2_token")
+	_ = storage.SaveSession(&gwt.Session{Id: "s1", UserId: "1", RefreshUuid: "r1"}, expire)
+	_ = storage.SaveSession(&gwt.Session{Id: "s2", UserId: "1", RefreshUuid: "r2"}, expire)
+	removed, err := storage.DeleteAllTokensExcept("1", "a1", "r1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), removed)
+	assert.Nil(t, storage.HasAccessToken("a1", "a1_token", "1"))
+	assert.Equal(t, gwt.ErrTokenExpired, storage.HasAccessToken("a2", "a2_token", "1"))
+	_, sessionErr := storage.GetSession("1", "s2")
+	assert.Equal(t, gwt.ErrSessionNotFound, sessionErr)
+
+	removed, _ = storage.DeleteAllSessions("1")
+	assert.Equal(t, int64(1), removed)
+	_, sessionErr = storage.GetSession("1", "s1")
+	assert.Equal(t, gwt.ErrSessionNotFound, sessionErr)
+}
+
+func TestMemoryStorageTicketUsedOnce(t *testing.T) {
+	storage := NewMemoryStorage()
+	_ = storage.SaveTicket("hash", "access_token", time.Now().Add(time.Minute).Unix())
+
+	accessToken, err := storage.UseTicket("hash")
+	assert.Nil(t, err)
+	assert.Equal(t, "access_token", accessToken)
+
+	_, err = storage.UseTicket("hash")
+	assert.Equal(t, gwt.ErrTicketNotFound, err)
+}
+
+func TestMemoryStorageLoginAttempts(t *testing.T) {
+	storage := NewMemoryStorage()
+	expire := time.Now().Add(time.Minute).Unix()
+	_, _ = storage.AddLoginAttempt("ip:1", expire)
+	count, _ := storage.AddLoginAttempt("ip:1", expire)
+
+	assert.Equal(t, int64(2), count)
+	_ = storage.DeleteLoginAttempts("ip:1")
+	count, last, _ := storage.GetLoginAttempts("ip:1")
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, int64(0), last)
+}
+
+func TestMemoryStorageMFA(t *testing.T) {
+	storage := NewMemoryStorage()
+	_ = storage.SaveMFASecret("1", "secret", []string{"code"})
+
+	secret, _ := storage.GetMFASecret("1")
+	assert.Equal(t, "secret", secret)
+	assert.Nil(t, storage.UseMFARecoveryCode("1", "code"))
+	assert.Equal(t, gwt.ErrInvalidMFACode, storage.UseMFARecoveryCode("1", "code"))
+	assert.Nil(t, storage.UseMFAStep("1", 100))
+	assert.Equal(t, gwt.ErrInvalidMFACode, storage.UseMFAStep("1", 100))
+	assert.Equal(t, gwt.ErrInvalidMFACode, storage.UseMFAStep("1", 99))
+	assert.Nil(t, storage.UseMFAStep("1", 101))
+	_ = storage.DeleteMFASecret("1")
+	_, err := storage.GetMFASecret("1")
+	assert.Equal(t, gwt.ErrMFANotEnrolled, err)
+}
+
+func TestMemoryStorageMFATokens(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	storage := NewMemoryStorage()
+	storage.SetClock(clock)
+	_ = storage.SaveMFAToken("used", 1100)
+	_ = storage.SaveMFAToken("expired", 1100)
+
+	assert.Nil(t, storage.UseMFAToken("used"))
+	assert.Equal(t, gwt.ErrMFATokenNotFound, storage.UseMFAToken("used"))
+	clock.Advance(time.Minute * 2)
+	assert.Equal(t, gwt.ErrMFATokenNotFound, storage.UseMFAToken("expired"))
+}
+
+func TestMemoryStorageOpaqueTokens(t *testing.T) {
+	storage := NewMemoryStorage()
+	clock := NewFakeClock(time.Unix(100, 0))
+	storage.SetClock(clock)
+	_ = storage.SaveOpaqueToken("hash", &gwt.OpaqueToken{UserId: "1", Expire: 200,
+		Claims: map[string]interface{}{"exp": int64(200)}})
+	token, err := storage.GetOpaqueToken("hash")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1", token.UserId)
+	assert.Equal(t, float64(200), token.Claims["exp"])
+
+	clock.Advance(100 * time.Second)
+	_, err = storage.GetOpaqueToken("hash")
+	assert.Equal(t, gwt.ErrTokenNotFound, err)
+
+	_, err = storage.GetOpaqueToken("unknown")
+	assert.Equal(t, gwt.ErrTokenNotFound, err)
+}
+
+func TestMemoryStorageDeleteOpaqueTokens(t *testing.T) {
+	storage := NewMemoryStorage()
+	_ = storage.SaveOpaqueToken("access hash", &gwt.OpaqueToken{UserId: "1", Uuid: "access", Expire: time.Now().Unix() + 60})
+	_ = storage.SaveOpaqueToken("other hash", &gwt.OpaqueToken{UserId: "1", Uuid: "other", Expire: time.Now().Unix() + 60})
+
+	assert.Nil(t, storage.DeleteOpaqueTokens("access", "refresh"))
+	_, err := storage.GetOpaqueToken("access hash")
+	assert.Equal(t, gwt.ErrTokenNotFound, err)
+	_, err = storage.GetOpaqueToken("other hash")
+	assert.Nil(t, err)
+}
+
+func TestMemoryStorageImplementsInterfaces(t *testing.T) {
+	var storage gwt.StorageInterface = NewMemoryStorage()
+
+	assert.Implements(t, (*gwt.SessionStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.SessionRemovalStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.LoginAttemptStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.MFAStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.ImpersonationStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.TicketStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.ClockStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.SessionListStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.OpaqueTokenStorageInterface)(nil), storage)
+	assert.Implements(t, (*gwt.AccessTokenStorageInterface)(nil), storage)
+}
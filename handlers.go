@@ -1,139 +1,459 @@
 package gwt
 
 import (
+	"errors"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"time"
 )
 
 type RefreshRequestData struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+type LogoutAllRequestData struct {
+	KeepCurrent bool `json:"keep_current" form:"keep_current"`
+}
+
 type Handler struct {
 	settings *Settings
 }
 
+// tokenError is an error of login or refresh flow along with the status code to respond with
+type tokenError struct {
+	code      int
+	err       error
+	userId    string
+	sessionId string
+}
+
 func (handler *Handler) GetLoginHandler() func(c *gin.Context) {
-	return handler.loginHandler
+	return handler.instrument("login", handler.loginHandler)
 }
 func (handler *Handler) GetRefreshHandler() func(c *gin.Context) {
-	return handler.refreshHandler
+	return handler.instrument("refresh", handler.refreshHandler)
 }
 func (handler *Handler) GetLogoutHandler() func(c *gin.Context) {
-	return handler.logoutHandler
+	return handler.instrument("logout", handler.logoutHandler)
 }
+
+// GetForceLogoutHandler returns handler logging out the user posted by the authenticated requester,
+// Settings.ForceLogoutAuthorizer is required
 func (handler *Handler) GetForceLogoutHandler() func(c *gin.Context) {
-	return handler.forceLogoutHandler
+	return handler.instrument("force_logout", handler.forceLogoutHandler)
+}
+
+// GetTokenEndpointHandler returns RFC 6749 token endpoint handler supporting password, refresh_token
+// and client_credentials grants
+func (handler *Handler) GetTokenEndpointHandler() func(c *gin.Context) {
+	return handler.instrument("token", handler.tokenEndpointHandler)
+}
+
+// GetLogoutAllHandler returns handler deleting all sessions of the authenticated user,
+// the current session is kept if keep_current is true
+func (handler *Handler) GetLogoutAllHandler() func(c *gin.Context) {
+	return handler.instrument("logout_all", handler.logoutAllHandler)
+}
+
+// GetMFAVerifyHandler returns handler that checks second factor code of the user
+// authenticated with mfa token and issues tokens
+func (handler *Handler) GetMFAVerifyHandler() func(c *gin.Context) {
+	return handler.instrument("mfa_verify", handler.mfaVerifyHandler)
+}
+
+// GetIntrospectionHandler returns RFC 7662 token introspection handler, Settings.ClientAuthenticator is required
+func (handler *Handler) GetIntrospectionHandler() func(c *gin.Context) {
+	return handler.instrument("introspection", handler.introspectionHandler)
+}
+
+// GetRevocationHandler returns RFC 7009 token revocation handler, it revokes access or refresh token
+// along with the paired one. Settings.RevocationClientAuthenticator is checked if it is set.
+func (handler *Handler) GetRevocationHandler() func(c *gin.Context) {
+	return handler.instrument("revocation", handler.revocationHandler)
+}
+
+// GetTicketHandler returns handler exchanging the access token for a single-use websocket connection ticket,
+// storage must implement TicketStorageInterface
+func (handler *Handler) GetTicketHandler() func(c *gin.Context) {
+	return handler.instrument("ticket", handler.ticketHandler)
+}
+
+// GetMetricsHandler returns handler exposing login, refresh, logout, middleware rejections
+// and storage latency metrics in prometheus text format
+func (handler *Handler) GetMetricsHandler() func(c *gin.Context) {
+	return handler.metricsHandler
 }
 
 func (handler *Handler) loginHandler(c *gin.Context) {
-	service := &tokenService{}
-	userId, err := handler.settings.Authenticator(c)
-	if err != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusUnauthorized, err.Error())
+	hooks := &handler.settings.Hooks
+	result, mfaRequired, loginErr := handler.login(handler.ginLoginRequest(c))
+	if loginErr != nil {
+		handler.fail(c, hooks.OnLoginFailure, loginErr.code, loginErr.err, loginErr.userId, "")
 		return
 	}
-	accessData, refreshData, er := service.getTokens(handler.settings, userId)
-	if er != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, err.Error())
+	if mfaRequired {
+		mfaToken, mfaExpire, mfaErr := (&tokenService{})._createMFAToken(handler.settings, newTokenParams(result))
+		if mfaErr != nil {
+			handler.fail(c, hooks.OnLoginFailure, http.StatusInternalServerError, mfaErr, result.UserId, "")
+			return
+		}
+		handler.settings.MFARequiredResponseFunc(c, http.StatusAccepted, mfaToken, mfaExpire)
 		return
 	}
-	if saveErr := handler.settings.Storage.SaveTokens(accessData.userId, accessData.uuid, refreshData.uuid, accessData.expire,
-		refreshData.expire, accessData.token, refreshData.token); saveErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, saveErr.Error())
+
+	handler.issueTokens(c, newTokenParams(result))
+}
+
+// loginRequest adapts login request of gin or net/http handler to the login flow,
+// callbacks are nil if they are not set for the framework
+type loginRequest struct {
+	clientIP     string
+	response     http.Header
+	identifier   func() string
+	captcha      func() error
+	authenticate func() (*AuthResult, error)
+}
+
+// ginLoginRequest adapts gin login request, LoginAuthenticator takes precedence over Authenticator
+func (handler *Handler) ginLoginRequest(c *gin.Context) *loginRequest {
+	settings := handler.settings
+	req := &loginRequest{clientIP: c.ClientIP(), response: c.Writer.Header()}
+	if settings.LoginAuthenticator != nil {
+		req.authenticate = func() (*AuthResult, error) {
+			return settings.LoginAuthenticator(c)
+		}
+	} else if settings.Authenticator != nil {
+		req.authenticate = func() (*AuthResult, error) {
+			userId, err := settings.Authenticator(c)
+			return &AuthResult{UserId: userId}, err
+		}
+	}
+	if identifierFunc := settings.LoginThrottle.IdentifierFunc; identifierFunc != nil {
+		req.identifier = func() string {
+			return identifierFunc(c)
+		}
+	}
+	if captchaFunc := settings.LoginThrottle.CaptchaFunc; captchaFunc != nil {
+		req.captcha = func() error {
+			return captchaFunc(c)
+		}
+	}
+	return req
+}
+
+// login checks login throttle and authenticates the user, mfaRequired is true when
+// the user is identified but second factor is required
+func (handler *Handler) login(req *loginRequest) (result *AuthResult, mfaRequired bool, loginErr *tokenError) {
+	if req.authenticate == nil {
+		return nil, false, &tokenError{code: http.StatusInternalServerError, err: ErrEmptyAuthenticator}
+	}
+	var throttleKeys []throttleKey
+	if handler.settings.LoginThrottle.enabled() {
+		throttleKeys = handler.settings.LoginThrottle.keys(req)
+		if throttleErr := handler.checkLoginThrottle(req.response, throttleKeys, req.captcha); throttleErr != nil {
+			return nil, false, throttleErr
+		}
+	}
+	result, err := req.authenticate()
+	if err != nil && !(errors.Is(err, ErrSecondFactorRequired) && result != nil && result.UserId != "") {
+		if len(throttleKeys) > 0 {
+			handler.registerFailedLogin(throttleKeys)
+		}
+		return nil, false, &tokenError{code: http.StatusUnauthorized, err: err}
+	}
+	if result == nil || result.UserId == "" {
+		// authenticator accepted the login without identifying the user
+		return nil, false, &tokenError{code: http.StatusInternalServerError, err: ErrUserIdIsNotProvided}
+	}
+	if len(throttleKeys) > 0 {
+		handler.resetFailedLogins(throttleKeys)
+	}
+	return result, err != nil, nil
+}
+
+// issueTokens creates and saves a new token pair of the authenticated user and responds with it
+func (handler *Handler) issueTokens(c *gin.Context, params *tokenParams) {
+	hooks := &handler.settings.Hooks
+	userId := params.userId
+	accessData, refreshData, err := saveTokens(handler.settings, params)
+	if err != nil {
+		sessionId := ""
+		if accessData != nil {
+			sessionId = accessData.sessionId
+		}
+		handler.fail(c, hooks.OnLoginFailure, http.StatusInternalServerError, err, userId, sessionId)
 		return
 	}
 
+	hooks.call(hooks.OnLoginSuccess, c, userId, accessData.sessionId, nil)
 	handler.settings.LoginResponseFunc(c, http.StatusOK, accessData.token,
 		accessData.expire, refreshData.token, refreshData.expire)
 }
+
+// saveTokens creates tokens and saves them with the session
+func saveTokens(settings *Settings, params *tokenParams) (*accessTokenData, *refreshTokenData, error) {
+	accessData, refreshData, err := saveTokenPair(settings, params)
+	if err != nil {
+		return accessData, refreshData, err
+	}
+	if sessionStorage, ok := unwrapStorage[SessionStorageInterface](settings.Storage); ok {
+		if saveErr := sessionStorage.SaveSession(&Session{
+			Id:          params.sessionId,
+			UserId:      params.userId,
+			AccessUuid:  accessData.uuid,
+			RefreshUuid: refreshData.uuid,
+			CreatedAt:   params.sessionStart,
+			ExpiresAt:   params.sessionExpire,
+			Metadata:    params.metadata,
+		}, refreshData.expire); saveErr != nil {
+			return accessData, refreshData, saveErr
+		}
+	}
+	return accessData, refreshData, nil
+}
+
+// saveTokenPair creates and saves the token pair without a session of the user
+func saveTokenPair(settings *Settings, params *tokenParams) (*accessTokenData, *refreshTokenData, error) {
+	accessData, refreshData, err := (&tokenService{}).getTokens(settings, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if saveErr := settings.Storage.SaveTokens(accessData.userId, accessData.uuid, refreshData.uuid, accessData.expire,
+		refreshData.expire, accessData.token, refreshData.token); saveErr != nil {
+		return accessData, refreshData, saveErr
+	}
+	return accessData, refreshData, nil
+}
+
 func (handler *Handler) refreshHandler(c *gin.Context) {
-	service := &tokenService{}
+	hooks := &handler.settings.Hooks
 	refreshRequestData := RefreshRequestData{}
 	if err := c.ShouldBind(&refreshRequestData); err != nil || refreshRequestData.RefreshToken == "" {
-		handler.settings.ErrResponseFunc(c, http.StatusBadRequest, ErrRefreshTokenIsNotProvided.Error())
+		handler.fail(c, hooks.OnRefreshFailure, http.StatusBadRequest, ErrRefreshTokenIsNotProvided, "", "")
 		return
 	}
-	parsedToken, parseErr := service.parseToken(refreshRequestData.RefreshToken, handler.settings.RefreshSecretKey, handler.settings.SigningMethod)
-	if parseErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusBadRequest, parseErr.Error())
+	claims, params, checkErr := handler.checkRefreshToken(refreshRequestData.RefreshToken)
+	if checkErr != nil {
+		handler.fail(c, hooks.OnRefreshFailure, checkErr.code, checkErr.err, checkErr.userId, checkErr.sessionId)
 		return
 	}
-	claims, getClaimsErr := service.getClaims(parsedToken, []string{refreshUuidClaim, accessUuidClaim, userIdClaim, expiredClaim})
-	if getClaimsErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusBadRequest, getClaimsErr.Error())
+	accessData, refreshData, rotateErr := handler.rotateTokens(claims, params)
+	if rotateErr != nil {
+		handler.fail(c, hooks.OnRefreshFailure, rotateErr.code, rotateErr.err, rotateErr.userId, rotateErr.sessionId)
 		return
 	}
-	if tokenExpErr := handler.settings.Storage.HasRefreshToken(claims[refreshUuidClaim], refreshRequestData.RefreshToken, claims[userIdClaim]); tokenExpErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusUnauthorized, tokenExpErr.Error())
-		return
+
+	hooks.call(hooks.OnRefresh, c, params.userId, accessData.sessionId, nil)
+	handler.settings.LoginResponseFunc(c, http.StatusOK, accessData.token,
+		accessData.expire, refreshData.token, refreshData.expire)
+}
+
+// checkRefreshToken verifies refresh token and restores parameters of the token pair to be issued instead
+func (handler *Handler) checkRefreshToken(refreshToken string) (map[string]string, *tokenParams, *tokenError) {
+	service := &tokenService{}
+	parsedToken, parseErr := service.decodeToken(handler.settings, refreshToken, handler.settings.RefreshSecretKey)
+	if parseErr != nil {
+		return nil, nil, &tokenError{code: parseErrorCode(parseErr), err: parseErr}
 	}
-	if expErr := service.isExpired(claims[expiredClaim]); expErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusUnauthorized, expErr.Error())
-		return
+	claims, getClaimsErr := service.getClaims(parsedToken, []string{refreshUuidClaim, accessUuidClaim, userIdClaim,
+		expiredClaim, sessionIdClaim, sessionStartClaim})
+	if getClaimsErr != nil {
+		return nil, nil, &tokenError{code: http.StatusBadRequest, err: getClaimsErr}
+	}
+	userId, sessionId := claims[userIdClaim], service.getSessionId(claims)
+	if service.getActorId(parsedToken) != "" {
+		return nil, nil, &tokenError{code: http.StatusUnauthorized, err: ErrImpersonationNotRefreshable, userId: userId,
+			sessionId: sessionId}
 	}
+	if tokenExpErr := handler.settings.Storage.HasRefreshToken(claims[refreshUuidClaim], refreshToken, claims[userIdClaim]); tokenExpErr != nil {
+		return nil, nil, &tokenError{code: http.StatusUnauthorized, err: tokenExpErr, userId: userId, sessionId: sessionId}
+	}
+	if expErr := service.isExpired(handler.settings, claims[expiredClaim]); expErr != nil {
+		return nil, nil, &tokenError{code: http.StatusUnauthorized, err: expErr, userId: userId, sessionId: sessionId}
+	}
+	params, paramsErr := handler.getRefreshParams(parsedToken, claims)
+	if paramsErr != nil {
+		return nil, nil, &tokenError{code: http.StatusUnauthorized, err: paramsErr, userId: userId, sessionId: sessionId}
+	}
+	return claims, params, nil
+}
+
+// rotateTokens deletes the refreshed token pair and saves a new one
+func (handler *Handler) rotateTokens(claims map[string]string, params *tokenParams) (*accessTokenData, *refreshTokenData, *tokenError) {
+	userId, sessionId := claims[userIdClaim], (&tokenService{}).getSessionId(claims)
 	if deleteRefreshErr := handler.settings.Storage.DeleteTokens(claims[userIdClaim], claims[refreshUuidClaim],
 		claims[accessUuidClaim]); deleteRefreshErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, deleteRefreshErr.Error())
-		return
+		return nil, nil, &tokenError{code: http.StatusInternalServerError, err: deleteRefreshErr, userId: userId,
+			sessionId: sessionId}
 	}
-	accessData, refreshData, tokenErr := service.getTokens(handler.settings, claims[userIdClaim])
-	if tokenErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, tokenErr.Error())
-		return
+	if deleteOpaqueErr := deleteOpaqueTokens(handler.settings, claims[accessUuidClaim],
+		claims[refreshUuidClaim]); deleteOpaqueErr != nil {
+		return nil, nil, &tokenError{code: http.StatusInternalServerError, err: deleteOpaqueErr, userId: userId,
+			sessionId: sessionId}
 	}
-	if saveErr := handler.settings.Storage.SaveTokens(accessData.userId, accessData.uuid, refreshData.uuid, accessData.expire,
-		refreshData.expire, accessData.token, refreshData.token); saveErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, saveErr.Error())
-		return
+	accessData, refreshData, saveErr := saveTokens(handler.settings, params)
+	if saveErr != nil {
+		return nil, nil, &tokenError{code: http.StatusInternalServerError, err: saveErr, userId: userId, sessionId: sessionId}
 	}
+	return accessData, refreshData, nil
+}
 
-	handler.settings.LoginResponseFunc(c, http.StatusOK, accessData.token,
-		accessData.expire, refreshData.token, refreshData.expire)
+// getRefreshParams restores the session of the refresh token and checks its lifetime,
+// session start saved in the storage takes precedence over the claim
+func (handler *Handler) getRefreshParams(token *jwt.Token, claims map[string]string) (*tokenParams, error) {
+	service := &tokenService{}
+	params, restoreErr := service.restoreParams(token)
+	if restoreErr != nil {
+		return nil, restoreErr
+	}
+	params.sessionId = claims[sessionIdClaim]
+	if params.sessionId == "" {
+		return params, nil
+	}
+	sessionStart, startErr := service.parseUnix(claims[sessionStartClaim])
+	if startErr != nil {
+		return nil, startErr
+	}
+	params.sessionStart = sessionStart
+	if sessionStorage, ok := unwrapStorage[SessionStorageInterface](handler.settings.Storage); ok {
+		session, sessionErr := sessionStorage.GetSession(params.userId, params.sessionId)
+		if sessionErr != nil && !errors.Is(sessionErr, ErrSessionNotFound) {
+			return nil, sessionErr
+		}
+		if session != nil {
+			params.sessionStart = session.CreatedAt
+			params.metadata = session.Metadata
+		}
+	}
+	if handler.settings.SessionMaxLifetime > 0 &&
+		time.Unix(params.sessionStart, 0).Add(handler.settings.SessionMaxLifetime).Unix() <= handler.settings.now().Unix() {
+		return nil, ErrSessionExpired
+	}
+	if handler.settings.DisableSlidingRefresh {
+		refreshExpire, expireErr := service.parseUnix(claims[expiredClaim])
+		if expireErr != nil {
+			return nil, expireErr
+		}
+		params.refreshExpire = refreshExpire
+	}
+	return params, nil
 }
+
 func (handler *Handler) logoutHandler(c *gin.Context) {
-	service := &tokenService{}
-	accessToken, getErr := getHeaderToken(c.Request.Header.Get(authHeader), handler.settings.AuthHeadName)
-	if getErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusUnauthorized, getErr.Error())
+	hooks := &handler.settings.Hooks
+	authenticated, authErr := authenticateRequest(handler.settings, c.Request, false)
+	if authErr != nil {
+		handler.fail(c, hooks.OnAuthFailure, authErr.code, authErr.err, authErr.userId, authErr.sessionId)
 		return
 	}
-	parsedToken, parseErr := service.parseToken(accessToken, handler.settings.AccessSecretKey, handler.settings.SigningMethod)
-	if parseErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusBadRequest, parseErr.Error())
+	if deleteErr := handler.deleteTokens(authenticated.claims); deleteErr != nil {
+		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, deleteErr.Error())
 		return
 	}
-	claims, getClaimsErr := service.getClaims(parsedToken, []string{refreshUuidClaim, accessUuidClaim, userIdClaim, expiredClaim})
-	if getClaimsErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusBadRequest, getClaimsErr.Error())
+
+	hooks.call(hooks.OnLogout, c, authenticated.userId, authenticated.sessionId(), nil)
+	handler.settings.LogoutResponseFunc(c, http.StatusOK)
+}
+
+func (handler *Handler) logoutAllHandler(c *gin.Context) {
+	hooks := &handler.settings.Hooks
+	authenticated, authErr := authenticateRequest(handler.settings, c.Request, false)
+	if authErr != nil {
+		handler.fail(c, hooks.OnAuthFailure, authErr.code, authErr.err, authErr.userId, authErr.sessionId)
 		return
 	}
-	if tokenExpErr := handler.settings.Storage.HasAccessToken(claims[accessUuidClaim], accessToken, claims[userIdClaim]); tokenExpErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusUnauthorized, tokenExpErr.Error())
-		return
+	requestData := LogoutAllRequestData{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBind(&requestData); err != nil {
+			handler.settings.ErrResponseFunc(c, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
-	if expErr := service.isExpired(claims[expiredClaim]); expErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusUnauthorized, expErr.Error())
-		return
+	claims := authenticated.claims
+	userId, sessionId := claims[userIdClaim], (&tokenService{}).getSessionId(claims)
+	var removed int64
+	var deleteErr error
+	if requestData.KeepCurrent {
+		removalStorage, ok := unwrapStorage[SessionRemovalStorageInterface](handler.settings.Storage)
+		if !ok {
+			handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, ErrUnsupportedStorage.Error())
+			return
+		}
+		removed, deleteErr = removalStorage.DeleteAllTokensExcept(userId, claims[accessUuidClaim], claims[refreshUuidClaim])
+	} else {
+		removed, deleteErr = deleteAllSessions(handler.settings.Storage, userId)
 	}
-	if deleteRefreshErr := handler.settings.Storage.DeleteTokens(claims[userIdClaim], claims[accessUuidClaim],
-		claims[refreshUuidClaim]); deleteRefreshErr != nil {
-		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, deleteRefreshErr.Error())
+	if deleteErr != nil {
+		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, deleteErr.Error())
 		return
 	}
 
-	handler.settings.LogoutResponseFunc(c, http.StatusOK)
+	hooks.call(hooks.OnLogout, c, userId, sessionId, nil)
+	c.JSON(http.StatusOK, LogoutAllResult{RemovedSessions: removed})
+}
+
+// deleteTokens deletes the token pair and its session, access token without refresh token is deleted alone
+func (handler *Handler) deleteTokens(claims map[string]string) error {
+	uuids := []string{claims[accessUuidClaim]}
+	if claims[refreshUuidClaim] != "" {
+		uuids = append(uuids, claims[refreshUuidClaim])
+	}
+	if err := handler.settings.Storage.DeleteTokens(claims[userIdClaim], uuids...); err != nil {
+		return err
+	}
+	if err := deleteOpaqueTokens(handler.settings, uuids...); err != nil {
+		return err
+	}
+	if sessionStorage, ok := unwrapStorage[SessionStorageInterface](handler.settings.Storage); ok && claims[sessionIdClaim] != "" {
+		return sessionStorage.DeleteSession(claims[userIdClaim], claims[sessionIdClaim])
+	}
+	return nil
 }
 
+// forceLogoutHandler authenticates the requester by access token and logs out the target user
+// if ForceLogoutAuthorizer allows it
 func (handler *Handler) forceLogoutHandler(c *gin.Context) {
+	hooks := &handler.settings.Hooks
+	if handler.settings.ForceLogoutAuthorizer == nil {
+		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, ErrEmptyForceLogoutAuthorizer.Error())
+		return
+	}
+	requester, authErr := authenticateRequest(handler.settings, c.Request, true)
+	if authErr != nil {
+		handler.fail(c, hooks.OnAuthFailure, authErr.code, authErr.err, authErr.userId, authErr.sessionId)
+		return
+	}
 	mapUserId := map[string]string{}
 	if err := c.ShouldBind(&mapUserId); err != nil || mapUserId[userIdRequestParam] == "" {
 		handler.settings.ErrResponseFunc(c, http.StatusBadRequest, ErrUserIdIsNotProvided.Error())
 		return
 	}
-	if deleteErr := handler.settings.Storage.DeleteAllTokens(mapUserId[userIdRequestParam]); deleteErr != nil {
+	targetUserId := mapUserId[userIdRequestParam]
+	if err := handler.settings.ForceLogoutAuthorizer(c, requester.requester(), targetUserId); err != nil {
+		handler.settings.ErrResponseFunc(c, http.StatusForbidden, err.Error())
+		return
+	}
+	removed, deleteErr := deleteAllSessions(handler.settings.Storage, targetUserId)
+	if deleteErr != nil {
 		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, deleteErr.Error())
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{})
+	invalidateUser(handler.settings, targetUserId)
+	hooks.call(hooks.OnForceLogout, c, targetUserId, "", nil)
+	c.JSON(http.StatusOK, ForceLogoutResult{UserId: targetUserId, RemovedSessions: removed})
+}
+
+// deleteAllSessions deletes all tokens of the user, -1 is returned as the number of removed sessions
+// if the storage cannot count them
+func deleteAllSessions(storage StorageInterface, userId string) (int64, error) {
+	if removalStorage, ok := unwrapStorage[SessionRemovalStorageInterface](storage); ok {
+		return removalStorage.DeleteAllSessions(userId)
+	}
+	return -1, storage.DeleteAllTokens(userId)
+}
+
+func (handler *Handler) fail(c *gin.Context, hook HookFunc, code int, err error, userId string, sessionId string) {
+	handler.settings.Hooks.call(hook, c, userId, sessionId, err)
+	handler.settings.ErrResponseFunc(c, code, err.Error())
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -113,7 +114,7 @@ func testRefreshInit(saveTokensErr string, hasRefreshTokenErr string,
 
 func TestRefreshSuccess(t *testing.T) {
 	tService := tokenService{}
-	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), "1", "access", "refresh")
+	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 
 	rr := testRefreshInit("", "", "", refreshData.token)
 
@@ -129,7 +130,7 @@ func TestRefreshSuccess(t *testing.T) {
 
 func TestRefreshParseTokenError(t *testing.T) {
 	tService := tokenService{}
-	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), "1", "access", "refresh")
+	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 
 	rr := testRefreshInit("", "", "", refreshData.token+"wrong")
 
@@ -142,7 +143,7 @@ func TestRefreshParseTokenError(t *testing.T) {
 
 func TestRefreshHasRefreshTokenError(t *testing.T) {
 	tService := tokenService{}
-	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), "1", "access", "refresh")
+	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 
 	rr := testRefreshInit("", "expired", "", refreshData.token)
 
@@ -155,7 +156,7 @@ func TestRefreshHasRefreshTokenError(t *testing.T) {
 
 func TestRefreshDeleteTokensError(t *testing.T) {
 	tService := tokenService{}
-	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), "1", "access", "refresh")
+	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 
 	rr := testRefreshInit("", "", "delete error", refreshData.token)
 
@@ -168,7 +169,7 @@ func TestRefreshDeleteTokensError(t *testing.T) {
 
 func TestRefreshSaveTokensError(t *testing.T) {
 	tService := tokenService{}
-	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), "1", "access", "refresh")
+	refreshData, _ := tService._createRefreshToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 
 	rr := testRefreshInit("save error", "", "", refreshData.token)
 
@@ -183,7 +184,7 @@ func TestRefreshExpiredError(t *testing.T) {
 	tService := tokenService{}
 	settings := getSettingsFixture()
 	settings.RefreshLifetime = time.Nanosecond
-	refreshData, _ := tService._createRefreshToken(settings, "1", "access", "refresh")
+	refreshData, _ := tService._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
 
 	rr := testRefreshInit("save error", "", "", refreshData.token)
 
@@ -227,7 +228,7 @@ func testLogoutInit(hasAccessTokenError string, deleteTokensError string,
 
 func TestLogoutSuccess(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testLogoutInit("", "", accessData.token, true)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
@@ -235,7 +236,7 @@ func TestLogoutSuccess(t *testing.T) {
 
 func TestLogoutNoHeaderError(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testLogoutInit("", "", accessData.token, false)
 	var res map[string]string
 	json.NewDecoder(rr.Body).Decode(&res)
@@ -246,7 +247,7 @@ func TestLogoutNoHeaderError(t *testing.T) {
 
 func TestLogoutInvalidTokenError(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testLogoutInit("", "", accessData.token+"wrong", true)
 	var res map[string]string
 	json.NewDecoder(rr.Body).Decode(&res)
@@ -257,7 +258,7 @@ func TestLogoutInvalidTokenError(t *testing.T) {
 
 func TestLogoutHasAccessTokenError(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testLogoutInit("expired", "", accessData.token, true)
 	var res map[string]string
 	json.NewDecoder(rr.Body).Decode(&res)
@@ -270,7 +271,7 @@ func TestLogoutExpiredError(t *testing.T) {
 	tService := tokenService{}
 	settings := getSettingsFixture()
 	settings.AccessLifetime = time.Nanosecond
-	accessData, _ := tService._createAccessToken(settings, "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testLogoutInit("", "", accessData.token, true)
 	var res map[string]string
 	json.NewDecoder(rr.Body).Decode(&res)
@@ -281,7 +282,7 @@ func TestLogoutExpiredError(t *testing.T) {
 
 func TestLogoutDeleteTokensError(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testLogoutInit("", "delete error", accessData.token, true)
 	var res map[string]string
 	json.NewDecoder(rr.Body).Decode(&res)
@@ -291,7 +292,16 @@ func TestLogoutDeleteTokensError(t *testing.T) {
 }
 
 func testForceLogoutInit(deleteAllTokensErr string, provideParams bool) *httptest.ResponseRecorder {
+	return testForceLogoutAuthorizerInit(deleteAllTokensErr, provideParams, true,
+		func(c *gin.Context, requesterUser interface{}, targetUserId string) error {
+			return nil
+		})
+}
+
+func testForceLogoutAuthorizerInit(deleteAllTokensErr string, provideParams bool, provideAccessToken bool,
+	authorizer func(c *gin.Context, requesterUser interface{}, targetUserId string) error) *httptest.ResponseRecorder {
 	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
 	if deleteAllTokensErr == "" {
 		strgMock.On("DeleteAllTokens", mock.Anything).Return(nil)
 	} else {
@@ -299,6 +309,7 @@ func testForceLogoutInit(deleteAllTokensErr string, provideParams bool) *httptes
 	}
 	settings := getSettingsFixture()
 	settings.Storage = strgMock
+	settings.ForceLogoutAuthorizer = authorizer
 
 	handler := &Handler{settings: settings}
 	gin.SetMode(gin.TestMode)
@@ -312,6 +323,10 @@ func testForceLogoutInit(deleteAllTokensErr string, provideParams bool) *httptes
 	}
 	request, _ := http.NewRequest(http.MethodPost, "/force-logout", bytes.NewBuffer(params))
 	request.Header.Add("Content-Type", "application/json")
+	if provideAccessToken {
+		accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "admin"}, "access", "refresh")
+		request.Header.Add("Authorization", "Bearer "+accessData.token)
+	}
 	router.ServeHTTP(rr, request)
 	return rr
 }
@@ -319,7 +334,11 @@ func testForceLogoutInit(deleteAllTokensErr string, provideParams bool) *httptes
 func TestForceLogoutSuccess(t *testing.T) {
 	rr := testForceLogoutInit("", true)
 
+	var res ForceLogoutResult
+	json.NewDecoder(rr.Body).Decode(&res)
+
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, ForceLogoutResult{UserId: "1", RemovedSessions: -1}, res)
 }
 
 func TestForceLogoutInvalidParamsError(t *testing.T) {
@@ -341,3 +360,260 @@ func TestForceLogoutDeleteTokensError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, rr.Code)
 	assert.Equal(t, "delete error", res["error_message"])
 }
+
+func TestForceLogoutNotAuthenticatedError(t *testing.T) {
+	rr := testForceLogoutAuthorizerInit("", true, false,
+		func(c *gin.Context, requesterUser interface{}, targetUserId string) error {
+			return nil
+		})
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestForceLogoutAuthorizer(t *testing.T) {
+	var requester interface{}
+	var target string
+	rr := testForceLogoutAuthorizerInit("", true, true,
+		func(c *gin.Context, requesterUser interface{}, targetUserId string) error {
+			requester, target = requesterUser, targetUserId
+			return errors.New("not an admin")
+		})
+
+	var res map[string]string
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, "not an admin", res["error_message"])
+	assert.Equal(t, "admin", requester)
+	assert.Equal(t, "1", target)
+}
+
+func TestForceLogoutEmptyAuthorizerError(t *testing.T) {
+	rr := testForceLogoutAuthorizerInit("", true, true, nil)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestDeleteAllSessionsRemovedCount(t *testing.T) {
+	strgMock := new(sessionRemovalStorageMock)
+	strgMock.On("DeleteAllSessions", mock.Anything).Return(int64(3), nil)
+
+	removed, err := deleteAllSessions(&instrumentedStorage{storage: strgMock}, "1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), removed)
+	strgMock.AssertNotCalled(t, "DeleteAllTokens")
+}
+
+func testSessionRefreshInit(settings *Settings, session *Session, refreshToken string) *httptest.ResponseRecorder {
+	strgMock := new(sessionStorageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	strgMock.On("HasRefreshToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	strgMock.On("SaveSession", mock.Anything).Return(nil)
+	if session == nil {
+		strgMock.On("GetSession", mock.Anything).Return(nil, ErrSessionNotFound)
+	} else {
+		strgMock.On("GetSession", mock.Anything).Return(session, nil)
+	}
+	settings.Storage = strgMock
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.Default()
+	router.POST("/refresh", handler.GetRefreshHandler())
+	params, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	request, _ := http.NewRequest(http.MethodPost, "/refresh", bytes.NewBuffer(params))
+	request.Header.Add("Content-Type", "application/json")
+	router.ServeHTTP(rr, request)
+	return rr
+}
+
+func TestRefreshSessionExpiredError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.SessionMaxLifetime = time.Hour
+	sessionStart := time.Now().Add(-time.Hour * 2).Unix()
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings,
+		&tokenParams{userId: "1", sessionId: "sid", sessionStart: sessionStart}, "access", "refresh")
+
+	rr := testSessionRefreshInit(settings, nil, refreshData.token)
+	var res map[string]string
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, ErrSessionExpired.Error(), res["error_message"])
+}
+
+func TestRefreshStoredSessionExpiredError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.SessionMaxLifetime = time.Hour
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings,
+		&tokenParams{userId: "1", sessionId: "sid", sessionStart: time.Now().Unix()}, "access", "refresh")
+	session := &Session{Id: "sid", UserId: "1", CreatedAt: time.Now().Add(-time.Hour * 2).Unix()}
+
+	rr := testSessionRefreshInit(settings, session, refreshData.token)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestRefreshSessionCapsExpiration(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.SessionMaxLifetime = time.Minute + time.Second*30
+	sessionStart := time.Now().Add(-time.Minute).Unix()
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings,
+		&tokenParams{userId: "1", sessionId: "sid", sessionStart: sessionStart}, "access", "refresh")
+
+	rr := testSessionRefreshInit(settings, nil, refreshData.token)
+	var res map[string]string
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, strconv.FormatInt(sessionStart+90, 10), res["refresh_expire"])
+	assert.Equal(t, strconv.FormatInt(sessionStart+90, 10), res["access_expire"])
+}
+
+func TestRefreshDisableSlidingRefresh(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.DisableSlidingRefresh = true
+	refreshExpire := time.Now().Add(time.Second * 30).Unix()
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings,
+		&tokenParams{userId: "1", sessionId: "sid", sessionStart: time.Now().Unix(), refreshExpire: refreshExpire},
+		"access", "refresh")
+
+	rr := testSessionRefreshInit(settings, nil, refreshData.token)
+	var res map[string]string
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, strconv.FormatInt(refreshExpire, 10), res["refresh_expire"])
+}
+
+func TestLoginAuthenticatorEmptyResultError(t *testing.T) {
+	for _, result := range []*AuthResult{nil, {}} {
+		settings := getSettingsFixture()
+		settings.Storage = new(storageMock)
+		settings.LoginAuthenticator = func(c *gin.Context) (*AuthResult, error) {
+			return result, nil
+		}
+		handler := &Handler{settings: settings}
+
+		gin.SetMode(gin.TestMode)
+		rr := httptest.NewRecorder()
+		router := gin.New()
+		router.POST("/login", handler.GetLoginHandler())
+		request, _ := http.NewRequest(http.MethodPost, "/login", nil)
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Contains(t, rr.Body.String(), ErrUserIdIsNotProvided.Error())
+	}
+}
+
+func TestLoginAuthenticatorResult(t *testing.T) {
+	strgMock := new(sessionStorageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	strgMock.On("SaveSession", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	settings.LoginAuthenticator = func(c *gin.Context) (*AuthResult, error) {
+		return &AuthResult{
+			UserId:          "1",
+			Claims:          map[string]interface{}{"role": "admin", userIdClaim: "2"},
+			Metadata:        map[string]string{"device": "kiosk"},
+			AccessLifetime:  time.Second * 10,
+			RefreshLifetime: time.Hour * 24 * 30,
+		}, nil
+	}
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.Default()
+	router.POST("/login", handler.GetLoginHandler())
+	request, _ := http.NewRequest(http.MethodPost, "/login", nil)
+	router.ServeHTTP(rr, request)
+
+	var res map[string]string
+	json.NewDecoder(rr.Body).Decode(&res)
+	service := &tokenService{}
+	accessToken, _ := service.parseToken(res["access_token"], settings.AccessSecretKey, settings.SigningMethod)
+	refreshToken, _ := service.parseToken(res["refresh_token"], settings.RefreshSecretKey, settings.SigningMethod)
+	params, _ := service.restoreParams(refreshToken)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, strconv.FormatInt(time.Now().Add(time.Second*10).Unix(), 10), res["access_expire"])
+	assert.Equal(t, strconv.FormatInt(time.Now().Add(time.Hour*24*30).Unix(), 10), res["refresh_expire"])
+	assert.Equal(t, map[string]interface{}{"role": "admin"}, service.getCustomClaims(accessToken))
+	assert.Equal(t, "1", params.userId)
+	assert.Equal(t, time.Second*10, params.accessLifetime)
+	assert.Equal(t, time.Hour*24*30, params.refreshLifetime)
+	assert.Equal(t, map[string]interface{}{"role": "admin"}, params.claims)
+}
+
+func testLogoutAllInit(strgMock StorageInterface, body string, provideAccessToken bool) *httptest.ResponseRecorder {
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/logout-all", handler.GetLogoutAllHandler())
+	request, _ := http.NewRequest(http.MethodPost, "/logout-all", bytes.NewBufferString(body))
+	request.Header.Add("Content-Type", "application/json")
+	if provideAccessToken {
+		accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1", sessionId: "sid"},
+			"access", "refresh")
+		request.Header.Add("Authorization", "Bearer "+accessData.token)
+	}
+	router.ServeHTTP(rr, request)
+	return rr
+}
+
+func TestLogoutAllSuccess(t *testing.T) {
+	strgMock := new(sessionRemovalStorageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteAllSessions", mock.Anything).Return(int64(3), nil)
+	rr := testLogoutAllInit(strgMock, "", true)
+
+	var res LogoutAllResult
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, int64(3), res.RemovedSessions)
+	strgMock.AssertNotCalled(t, "DeleteAllTokensExcept")
+}
+
+func TestLogoutAllKeepCurrent(t *testing.T) {
+	strgMock := new(sessionRemovalStorageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteAllTokensExcept", mock.Anything).Return(int64(2), nil)
+	rr := testLogoutAllInit(strgMock, `{"keep_current": true}`, true)
+
+	var res LogoutAllResult
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, int64(2), res.RemovedSessions)
+	strgMock.AssertNotCalled(t, "DeleteAllSessions")
+}
+
+func TestLogoutAllKeepCurrentUnsupportedStorage(t *testing.T) {
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	rr := testLogoutAllInit(strgMock, `{"keep_current": true}`, true)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	strgMock.AssertNotCalled(t, "DeleteAllTokens")
+}
+
+func TestLogoutAllErrors(t *testing.T) {
+	strgMock := new(sessionRemovalStorageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteAllSessions", mock.Anything).Return(int64(0), errors.New("delete error"))
+
+	assert.Equal(t, http.StatusUnauthorized, testLogoutAllInit(strgMock, "", false).Code)
+	assert.Equal(t, http.StatusBadRequest, testLogoutAllInit(strgMock, `{"keep_current": "maybe"}`, true).Code)
+	assert.Equal(t, http.StatusInternalServerError, testLogoutAllInit(strgMock, "", true).Code)
+}
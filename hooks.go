@@ -0,0 +1,42 @@
+package gwt
+
+import "github.com/gin-gonic/gin"
+
+// HookFunc is a callback that receives lifecycle event data.
+// userId and sessionId are empty if they are not known at the moment the event happens,
+// err is nil for successful events and holds the reason of the failure otherwise.
+// Gin context is nil when the event happens in net/http handlers and middleware.
+type HookFunc func(c *gin.Context, userId string, sessionId string, err error)
+
+// Hooks is a set of optional callbacks called by handlers and middleware.
+// Session id is kept across token rotations, uuid of the refresh token is used for tokens issued without it.
+type Hooks struct {
+
+	// OnLoginSuccess is called after login handler has issued tokens
+	OnLoginSuccess HookFunc
+
+	// OnLoginFailure is called when login handler fails to authenticate user or to issue tokens
+	OnLoginFailure HookFunc
+
+	// OnRefresh is called after refresh handler has issued new tokens
+	OnRefresh HookFunc
+
+	// OnRefreshFailure is called when refresh handler rejects refresh token or fails to issue new tokens
+	OnRefreshFailure HookFunc
+
+	// OnLogout is called after logout handler has deleted tokens
+	OnLogout HookFunc
+
+	// OnForceLogout is called after all user tokens have been deleted by force logout handler or service,
+	// and with the session id after Service.RevokeSession. Gin context is nil when logout is forced by service.
+	OnForceLogout HookFunc
+
+	// OnAuthFailure is called when auth middleware or logout handler rejects a request
+	OnAuthFailure HookFunc
+}
+
+func (hooks *Hooks) call(hook HookFunc, c *gin.Context, userId string, sessionId string, err error) {
+	if hook != nil {
+		hook(c, userId, sessionId, err)
+	}
+}
@@ -0,0 +1,116 @@
+package gwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type hookCall struct {
+	userId    string
+	sessionId string
+	err       error
+}
+
+func recordHook(calls *[]hookCall) HookFunc {
+	return func(c *gin.Context, userId string, sessionId string, err error) {
+		*calls = append(*calls, hookCall{userId: userId, sessionId: sessionId, err: err})
+	}
+}
+
+func TestLoginHooks(t *testing.T) {
+	var success, failure []hookCall
+	strgMock := new(storageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	settings.Hooks = Hooks{OnLoginSuccess: recordHook(&success), OnLoginFailure: recordHook(&failure)}
+	authErr := errors.New("invalid credentials")
+	settings.Authenticator = func(c *gin.Context) (string, error) {
+		if c.Query("fail") != "" {
+			return "", authErr
+		}
+		return "1", nil
+	}
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", handler.GetLoginHandler())
+	for _, url := range []string{"/login", "/login?fail=1"} {
+		request, _ := http.NewRequest(http.MethodPost, url, nil)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	assert.Len(t, success, 1)
+	assert.Equal(t, "1", success[0].userId)
+	assert.NotEmpty(t, success[0].sessionId)
+	assert.Nil(t, success[0].err)
+	assert.Len(t, failure, 1)
+	assert.Equal(t, authErr, failure[0].err)
+}
+
+func TestRefreshHooks(t *testing.T) {
+	var refresh []hookCall
+	settings := getSettingsFixture()
+	settings.Hooks = Hooks{OnRefresh: recordHook(&refresh)}
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+
+	strgMock := new(storageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	strgMock.On("HasRefreshToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/refresh", handler.GetRefreshHandler())
+	params, _ := json.Marshal(map[string]string{"refresh_token": refreshData.token})
+	request, _ := http.NewRequest(http.MethodPost, "/refresh", bytes.NewBuffer(params))
+	request.Header.Add("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	assert.Len(t, refresh, 1)
+	assert.Equal(t, "1", refresh[0].userId)
+	assert.NotEqual(t, "refresh", refresh[0].sessionId)
+}
+
+func TestAuthFailureHook(t *testing.T) {
+	var failure []hookCall
+	settings := getSettingsFixture()
+	settings.Hooks = Hooks{OnAuthFailure: recordHook(&failure)}
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(ErrTokenExpired)
+	settings.Storage = strgMock
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	mw := &Middleware{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(mw.GetAuthMiddleware()).GET("/test-auth", func(c *gin.Context) {})
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	assert.Len(t, failure, 1)
+	assert.Equal(t, "1", failure[0].userId)
+	assert.Equal(t, "refresh", failure[0].sessionId)
+	assert.Equal(t, ErrTokenExpired, failure[0].err)
+}
+
+func TestForceLogoutServiceHook(t *testing.T) {
+	var forceLogout []hookCall
+	service := testInitService("")
+	service.settings.Hooks = Hooks{OnForceLogout: recordHook(&forceLogout)}
+
+	assert.Nil(t, service.ForceLogoutUser("1"))
+	assert.Len(t, forceLogout, 1)
+	assert.Equal(t, "1", forceLogout[0].userId)
+}
@@ -0,0 +1,113 @@
+package gwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// Actor is stored in the context by auth middleware along with the user when the token is issued
+// to the admin impersonating the user
+type Actor struct {
+	// UserId is the id of the admin
+	UserId string
+
+	// SessionId is the id of the impersonation session
+	SessionId string
+}
+
+// GetActor returns the admin impersonating the authenticated user, false is returned for regular tokens
+func GetActor(c *gin.Context) (*Actor, bool) {
+	value, exists := c.Get(ActorKey)
+	if !exists {
+		return nil, false
+	}
+	actor, ok := value.(*Actor)
+	return actor, ok
+}
+
+// Impersonate issues tokens of the target user to the admin. Tokens carry RFC 8693 act claim with the admin id,
+// expire after lifetime and cannot be refreshed. Lifetime is capped by Settings.ImpersonationLifetime.
+// No session of the user is saved, so impersonation is not listed among the user sessions.
+// Storage must implement ImpersonationStorageInterface.
+func (service *Service) Impersonate(adminId string, targetUserId string, reason string,
+	lifetime time.Duration) (*ImpersonationTokens, error) {
+	if adminId == "" || targetUserId == "" || reason == "" || lifetime <= 0 {
+		return nil, ErrInvalidImpersonation
+	}
+	if lifetime > service.settings.ImpersonationLifetime {
+		return nil, ErrImpersonationLifetimeExceeded
+	}
+	impersonationStorage, ok := unwrapStorage[ImpersonationStorageInterface](service.settings.Storage)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	params := &tokenParams{userId: targetUserId, actorId: adminId, accessLifetime: lifetime, refreshLifetime: lifetime}
+	accessData, refreshData, err := saveTokenPair(service.settings, params)
+	if err != nil {
+		return nil, err
+	}
+	impersonation := &Impersonation{
+		Id:          accessData.sessionId,
+		ActorId:     adminId,
+		UserId:      targetUserId,
+		Reason:      reason,
+		AccessUuid:  accessData.uuid,
+		RefreshUuid: refreshData.uuid,
+		CreatedAt:   params.sessionStart,
+		ExpiresAt:   refreshData.expire,
+	}
+	if saveErr := impersonationStorage.SaveImpersonation(impersonation, refreshData.expire); saveErr != nil {
+		_ = service.settings.Storage.DeleteTokens(targetUserId, accessData.uuid, refreshData.uuid)
+		return nil, saveErr
+	}
+	return &ImpersonationTokens{
+		AccessToken:   accessData.token,
+		AccessExpire:  accessData.expire,
+		RefreshToken:  refreshData.token,
+		RefreshExpire: refreshData.expire,
+		Impersonation: impersonation,
+	}, nil
+}
+
+// ListImpersonations returns active impersonation sessions
+func (service *Service) ListImpersonations() ([]*Impersonation, error) {
+	impersonationStorage, ok := unwrapStorage[ImpersonationStorageInterface](service.settings.Storage)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	impersonations, err := impersonationStorage.GetImpersonations()
+	if err != nil {
+		return nil, err
+	}
+	active := make([]*Impersonation, 0, len(impersonations))
+	for _, impersonation := range impersonations {
+		if impersonation.ExpiresAt > service.settings.now().Unix() {
+			active = append(active, impersonation)
+		}
+	}
+	return active, nil
+}
+
+// RevokeImpersonation deletes tokens of the impersonation, sessions of the user are kept
+func (service *Service) RevokeImpersonation(id string) error {
+	impersonations, err := service.ListImpersonations()
+	if err != nil {
+		return err
+	}
+	for _, impersonation := range impersonations {
+		if impersonation.Id != id {
+			continue
+		}
+		if deleteErr := service.settings.Storage.DeleteTokens(impersonation.UserId, impersonation.AccessUuid,
+			impersonation.RefreshUuid); deleteErr != nil {
+			return deleteErr
+		}
+		if deleteErr := deleteOpaqueTokens(service.settings, impersonation.AccessUuid,
+			impersonation.RefreshUuid); deleteErr != nil {
+			return deleteErr
+		}
+		impersonationStorage, _ := unwrapStorage[ImpersonationStorageInterface](service.settings.Storage)
+		return impersonationStorage.DeleteImpersonation(id)
+	}
+	return ErrImpersonationNotFound
+}
@@ -0,0 +1,129 @@
+package gwt
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestImpersonateSuccess(t *testing.T) {
+	strgMock := new(impersonationStorageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	strgMock.On("SaveImpersonation", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	service := &Service{settings: settings}
+
+	tokens, err := service.Impersonate("admin", "1", "ticket 42", time.Minute*5)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", tokens.Impersonation.ActorId)
+	assert.Equal(t, "1", tokens.Impersonation.UserId)
+	assert.Equal(t, "ticket 42", tokens.Impersonation.Reason)
+	assert.InDelta(t, time.Now().Add(time.Minute*5).Unix(), tokens.RefreshExpire, 1)
+	strgMock.AssertCalled(t, "SaveImpersonation")
+
+	parsedToken, _ := (&tokenService{}).parseToken(tokens.AccessToken, settings.AccessSecretKey, settings.SigningMethod)
+	assert.Equal(t, "admin", (&tokenService{}).getActorId(parsedToken))
+}
+
+func TestImpersonateErrors(t *testing.T) {
+	service := &Service{settings: getSettingsFixture()}
+
+	_, invalidErr := service.Impersonate("admin", "1", "", time.Minute)
+	assert.Equal(t, ErrInvalidImpersonation, invalidErr)
+
+	_, storageErr := service.Impersonate("admin", "1", "ticket 42", time.Minute)
+	assert.Equal(t, ErrUnsupportedStorage, storageErr)
+
+	_, lifetimeErr := service.Impersonate("admin", "1", "ticket 42", time.Hour+time.Second)
+	assert.Equal(t, ErrImpersonationLifetimeExceeded, lifetimeErr)
+}
+
+func TestImpersonateSaveImpersonationError(t *testing.T) {
+	strgMock := new(impersonationStorageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	strgMock.On("SaveImpersonation", mock.Anything).Return(errors.New("save error"))
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	service := &Service{settings: settings}
+
+	_, err := service.Impersonate("admin", "1", "ticket 42", time.Minute)
+
+	assert.EqualError(t, err, "save error")
+	strgMock.AssertCalled(t, "DeleteTokens")
+}
+
+func TestImpersonationRefreshRejected(t *testing.T) {
+	refreshData, _ := (&tokenService{})._createRefreshToken(getSettingsFixture(),
+		&tokenParams{userId: "1", actorId: "admin"}, "access", "refresh")
+	rr := testRefreshInit("", "", "", refreshData.token)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthMiddlewareActor(t *testing.T) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings,
+		&tokenParams{userId: "1", actorId: "admin", sessionId: "sid"}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	mw := &Middleware{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	var actor *Actor
+	var user interface{}
+	router.Use(mw.GetAuthMiddleware()).GET("/test-auth", func(c *gin.Context) {
+		actor, _ = GetActor(c)
+		user, _ = c.Get(UserKey)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", user)
+	assert.Equal(t, &Actor{UserId: "admin", SessionId: "sid"}, actor)
+}
+
+func TestListImpersonations(t *testing.T) {
+	strgMock := new(impersonationStorageMock)
+	active := &Impersonation{Id: "active", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	strgMock.On("GetImpersonations", mock.Anything).Return([]*Impersonation{active,
+		{Id: "expired", ExpiresAt: time.Now().Add(-time.Minute).Unix()}}, nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	service := &Service{settings: settings}
+
+	impersonations, err := service.ListImpersonations()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*Impersonation{active}, impersonations)
+}
+
+func TestRevokeImpersonation(t *testing.T) {
+	strgMock := new(impersonationStorageMock)
+	strgMock.On("GetImpersonations", mock.Anything).Return([]*Impersonation{{Id: "sid", UserId: "1",
+		AccessUuid: "access", RefreshUuid: "refresh", ExpiresAt: time.Now().Add(time.Minute).Unix()}}, nil)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	strgMock.On("DeleteImpersonation", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	service := &Service{settings: settings}
+
+	assert.Nil(t, service.RevokeImpersonation("sid"))
+	strgMock.AssertCalled(t, "DeleteTokens")
+	strgMock.AssertCalled(t, "DeleteImpersonation")
+
+	assert.Equal(t, ErrImpersonationNotFound, service.RevokeImpersonation("unknown"))
+}
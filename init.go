@@ -6,15 +6,47 @@ import (
 )
 
 var (
-	userIdClaim        = "user_id"
-	accessUuidClaim    = "access_uuid"
-	refreshUuidClaim   = "refresh_uuid"
-	expiredClaim       = "exp"
-	authHeader         = "Authorization"
-	userIdRequestParam = "user_id"
-	UserKey            = "user"
+	userIdClaim          = "user_id"
+	accessUuidClaim      = "access_uuid"
+	refreshUuidClaim     = "refresh_uuid"
+	mfaClaim             = "mfa"
+	mfaUuidClaim         = "mfa_uuid"
+	sessionIdClaim       = "session_id"
+	sessionStartClaim    = "session_start"
+	sessionMetadataClaim = "session_metadata"
+	accessLifetimeClaim  = "access_lifetime"
+	refreshLifetimeClaim = "refresh_lifetime"
+	expiredClaim         = "exp"
+	issuedAtClaim        = "iat"
+	clientIdClaim        = "client_id"
+	actorClaim           = "act"
+	authHeader           = "Authorization"
+	userIdRequestParam   = "user_id"
+	UserKey              = "user"
+	UserIdKey            = "user_id"
+	ClaimsKey            = "claims"
+	ClientKey            = "client"
+	ActorKey             = "actor"
 )
 
+// reservedClaims are used by the package, custom claims with these names are ignored
+var reservedClaims = map[string]bool{
+	userIdClaim:          true,
+	accessUuidClaim:      true,
+	refreshUuidClaim:     true,
+	expiredClaim:         true,
+	issuedAtClaim:        true,
+	mfaClaim:             true,
+	mfaUuidClaim:         true,
+	sessionIdClaim:       true,
+	sessionStartClaim:    true,
+	sessionMetadataClaim: true,
+	accessLifetimeClaim:  true,
+	refreshLifetimeClaim: true,
+	clientIdClaim:        true,
+	actorClaim:           true,
+}
+
 var availSigningMethods = map[string]string{
 	"HS256": "true",
 	"HS384": "true",
@@ -36,11 +68,13 @@ type DefaultErrResponse struct {
 type DefaultLogoutResponse struct{}
 
 var (
-	defaultSigningMethod     = "HS256"
-	defaultAccessLifetime    = time.Minute * 10
-	defaultRefreshLifetime   = time.Hour * 24
-	defaultAuthHeadName      = "Bearer"
-	defaultLoginResponseFunc = func(c *gin.Context, code int, accessToken string,
+	defaultSigningMethod         = "HS256"
+	defaultAccessLifetime        = time.Minute * 10
+	defaultRefreshLifetime       = time.Hour * 24
+	defaultAuthHeadName          = "Bearer"
+	defaultMFATokenLifetime      = time.Minute * 5
+	defaultImpersonationLifetime = time.Hour
+	defaultLoginResponseFunc     = func(c *gin.Context, code int, accessToken string,
 		accessExpire int64, refreshToken string, refreshExpire int64) {
 		c.JSON(code, DefaultLoginResponse{
 			AccessToken:   accessToken,
@@ -56,6 +90,9 @@ var (
 	defaultLogoutResponseFunc = func(c *gin.Context, code int) {
 		c.JSON(code, DefaultLogoutResponse{})
 	}
+	defaultMFARequiredResponseFunc = func(c *gin.Context, code int, mfaToken string, mfaExpire int64) {
+		c.JSON(code, DefaultMFAResponse{MFAToken: mfaToken, MFAExpire: mfaExpire})
+	}
 )
 
 type Gwt struct {
@@ -65,27 +102,40 @@ type Gwt struct {
 }
 
 func Init(settings Settings) (*Gwt, error) {
-	if settings.Storage == nil {
-		return nil, ErrEmptyStorage
+	refreshSecretKeySet := settings.RefreshSecretKey != nil
+	settings.setDefaults()
+	if err := settings.validate(); err != nil {
+		return nil, err
 	}
-	if settings.Authenticator == nil {
-		return nil, ErrEmptyAuthenticator
+	if refreshSecretKeySet {
+		settings.warnSecretReuse()
 	}
-	if settings.GetUserFunc == nil {
-		return nil, ErrEmptyGetUserFunc
+	if clockStorage, ok := settings.Storage.(ClockStorageInterface); ok {
+		clockStorage.SetClock(settings.Clock)
 	}
-	if settings.AccessSecretKey == nil {
-		return nil, ErrEmptyAccessSecretKey
+	if clockCache, ok := settings.UserCache.(ClockStorageInterface); ok {
+		clockCache.SetClock(settings.Clock)
 	}
+	settings.metrics = newMetrics()
+	settings.Storage = &instrumentedStorage{storage: settings.Storage, metrics: settings.metrics}
+
+	return &Gwt{
+		Middleware: &Middleware{settings: &settings},
+		Handler:    &Handler{settings: &settings},
+		Service:    &Service{settings: &settings},
+	}, nil
+}
+
+// setDefaults fills optional settings which are not set
+func (settings *Settings) setDefaults() {
 	if settings.RefreshSecretKey == nil {
 		settings.RefreshSecretKey = settings.AccessSecretKey
 	}
 	if settings.SigningMethod == "" {
 		settings.SigningMethod = defaultSigningMethod
-	} else {
-		if availSigningMethods[settings.SigningMethod] == "" {
-			return nil, ErrUnknownSigningMethod
-		}
+	}
+	if settings.TokenFormat == "" {
+		settings.TokenFormat = TokenFormatJWT
 	}
 	if settings.AccessLifetime == 0 {
 		settings.AccessLifetime = defaultAccessLifetime
@@ -105,10 +155,26 @@ func Init(settings Settings) (*Gwt, error) {
 	if settings.LogoutResponseFunc == nil {
 		settings.LogoutResponseFunc = defaultLogoutResponseFunc
 	}
-
-	return &Gwt{
-		Middleware: &Middleware{settings: &settings},
-		Handler:    &Handler{settings: &settings},
-		Service:    &Service{settings: &settings},
-	}, nil
+	if settings.MFATokenLifetime == 0 {
+		settings.MFATokenLifetime = defaultMFATokenLifetime
+	}
+	if settings.ImpersonationLifetime == 0 {
+		settings.ImpersonationLifetime = defaultImpersonationLifetime
+	}
+	if settings.MFARequiredResponseFunc == nil {
+		settings.MFARequiredResponseFunc = defaultMFARequiredResponseFunc
+	}
+	// lockout is set even if login throttle is disabled, as second factor checks are always throttled
+	if settings.LoginThrottle.LockoutWindow == 0 {
+		settings.LoginThrottle.LockoutWindow = defaultLockoutWindow
+	}
+	if settings.LoginThrottle.MaxLockoutWindow == 0 {
+		settings.LoginThrottle.MaxLockoutWindow = defaultMaxLockoutWindow
+	}
+	if settings.LoginThrottle.AttemptsLifetime == 0 {
+		settings.LoginThrottle.AttemptsLifetime = defaultAttemptsLifetime
+	}
+	if settings.Clock == nil {
+		settings.Clock = systemClock{}
+	}
 }
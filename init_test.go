@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestInitSuccess(t *testing.T) {
@@ -55,12 +56,12 @@ func TestInitEmptyAccessSecretError(t *testing.T) {
 
 func TestDefaultRefreshSecret(t *testing.T) {
 	settings := getSettingsFixture()
-	settings.AccessSecretKey = []byte("access_secret")
+	settings.AccessSecretKey = []byte("access_secret_key_of_at_least_32_bytes")
 	settings.RefreshSecretKey = nil
 	auth, err := Init(*settings)
 
 	assert.Nil(t, err)
-	assert.Equal(t, []byte("access_secret"), auth.Service.settings.RefreshSecretKey)
+	assert.Equal(t, []byte("access_secret_key_of_at_least_32_bytes"), auth.Service.settings.RefreshSecretKey)
 }
 
 func TestDefaultSigningMethod(t *testing.T) {
@@ -84,6 +85,7 @@ func TestUnknownSigningMethodError(t *testing.T) {
 func TestDefaultAccessLifetime(t *testing.T) {
 	settings := getSettingsFixture()
 	settings.AccessLifetime = 0
+	settings.RefreshLifetime = time.Hour
 	auth, err := Init(*settings)
 
 	assert.Nil(t, err)
@@ -121,3 +123,15 @@ func TestDefaultFunctions(t *testing.T) {
 	assert.IsType(t, func(c *gin.Context, code int, message string) {}, auth.Service.settings.ErrResponseFunc)
 	assert.IsType(t, func(c *gin.Context, code int) {}, auth.Service.settings.LogoutResponseFunc)
 }
+
+func TestInitLoginAuthenticatorSuccess(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Authenticator = nil
+	settings.LoginAuthenticator = func(c *gin.Context) (*AuthResult, error) {
+		return &AuthResult{UserId: "1"}, nil
+	}
+	auth, err := Init(*settings)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, auth)
+}
@@ -8,3 +8,117 @@ type StorageInterface interface {
 	HasAccessToken(uuid string, token string, userId string) error
 	DeleteAllTokens(userId string) error
 }
+
+// LoginAttemptStorageInterface is implemented by storages able to keep failed login attempts,
+// it is required by LoginThrottle
+type LoginAttemptStorageInterface interface {
+	// AddLoginAttempt increments failed attempts counter of the key and returns its value,
+	// counter is kept until expire
+	AddLoginAttempt(key string, expire int64) (int64, error)
+	// GetLoginAttempts returns number of failed attempts and unix time of the last one
+	GetLoginAttempts(key string) (int64, int64, error)
+	DeleteLoginAttempts(key string) error
+}
+
+// MFAStorageInterface is implemented by storages able to keep second factor secrets,
+// it is required by MFA verify handler and MFA service methods. Failed checks are throttled per user,
+// so the storage keeps login attempts too.
+type MFAStorageInterface interface {
+	LoginAttemptStorageInterface
+	// SaveMFASecret replaces user secret and recovery codes hashes
+	SaveMFASecret(userId string, secret string, recoveryCodes []string) error
+	// GetMFASecret returns ErrMFANotEnrolled if user has no secret
+	GetMFASecret(userId string) (string, error)
+	// UseMFARecoveryCode deletes recovery code hash, returns error if user has no such code
+	UseMFARecoveryCode(userId string, recoveryCode string) error
+	DeleteMFASecret(userId string) error
+	// UseMFAStep saves the time step of accepted TOTP code, returns ErrInvalidMFACode if the step is not later
+	// than the last accepted step of the user, so a code is accepted once
+	UseMFAStep(userId string, step int64) error
+	// SaveMFAToken keeps id of the issued mfa token until expire
+	SaveMFAToken(tokenId string, expire int64) error
+	// UseMFAToken deletes id of the mfa token, returns ErrMFATokenNotFound if the id is unknown or expired,
+	// so a token is accepted once
+	UseMFAToken(tokenId string) error
+}
+
+// SessionRemovalStorageInterface is implemented by storages able to delete all sessions of the user
+// and report the number of removed sessions, required by logout all handler to keep the current session
+type SessionRemovalStorageInterface interface {
+	// DeleteAllSessions deletes all tokens and sessions of the user, returns the number of removed sessions
+	DeleteAllSessions(userId string) (int64, error)
+
+	// DeleteAllTokensExcept deletes all tokens and sessions of the user except the given token pair and its session,
+	// returns the number of removed sessions
+	DeleteAllTokensExcept(userId string, accessUuid string, refreshUuid string) (int64, error)
+}
+
+// ImpersonationStorageInterface is implemented by storages keeping impersonation sessions,
+// required by Service.Impersonate
+type ImpersonationStorageInterface interface {
+	SaveImpersonation(impersonation *Impersonation, expire int64) error
+
+	// GetImpersonations returns impersonation sessions which have not expired
+	GetImpersonations() ([]*Impersonation, error)
+	DeleteImpersonation(id string) error
+}
+
+// AccessTokenStorageInterface is implemented by storages able to keep an access token without refresh token,
+// required by client credentials grant
+type AccessTokenStorageInterface interface {
+	SaveAccessToken(userId string, accessUuid string, accessExpire int64, accessToken string) error
+}
+
+// TicketStorageInterface is implemented by storages keeping single-use websocket connection tickets,
+// required by ticket handler. Tickets are saved by hash, so the storage never sees the ticket itself.
+type TicketStorageInterface interface {
+	SaveTicket(ticketHash string, accessToken string, expire int64) error
+
+	// UseTicket deletes the ticket and returns the access token it was issued for,
+	// ErrTicketNotFound is returned if there is no such ticket or it has expired
+	UseTicket(ticketHash string) (string, error)
+}
+
+// OpaqueTokenStorageInterface is implemented by storages keeping claims of opaque tokens, required by
+// TokenFormatOpaque. Tokens are saved by hash, so the storage never sees the token itself.
+type OpaqueTokenStorageInterface interface {
+	// SaveOpaqueToken saves the token, storage may forget it after token.Expire
+	SaveOpaqueToken(tokenHash string, token *OpaqueToken) error
+
+	// GetOpaqueToken returns the token saved by hash, ErrTokenNotFound is returned if there is no such token
+	// or it has expired
+	GetOpaqueToken(tokenHash string) (*OpaqueToken, error)
+
+	// DeleteOpaqueTokens deletes tokens saved with the uuids, unknown and empty uuids are ignored
+	DeleteOpaqueTokens(uuids ...string) error
+}
+
+// SessionStorageInterface is implemented by storages able to keep sessions along with tokens.
+// Sessions are optional, they are saved on login and refresh and deleted on logout when storage supports them.
+type SessionStorageInterface interface {
+	// SaveSession creates or replaces the session, storage may forget it after expire
+	SaveSession(session *Session, expire int64) error
+	// GetSession returns ErrSessionNotFound if there is no such session
+	GetSession(userId string, sessionId string) (*Session, error)
+	DeleteSession(userId string, sessionId string) error
+}
+
+// SessionListStorageInterface is implemented by storages able to list sessions of the user,
+// required by Service.ListSessions
+type SessionListStorageInterface interface {
+	// GetSessions returns sessions of the user which have not expired
+	GetSessions(userId string) ([]*Session, error)
+}
+
+// ClockStorageInterface is implemented by storages and user caches computing expiration from current time,
+// Init sets Settings.Clock to them
+type ClockStorageInterface interface {
+	SetClock(clock Clock)
+}
+
+// PurgeStorageInterface is implemented by storages keeping expired records until they are purged,
+// required by Service.PurgeExpired
+type PurgeStorageInterface interface {
+	// PurgeExpired deletes records which have expired by now
+	PurgeExpired() error
+}
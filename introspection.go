@@ -0,0 +1,113 @@
+package gwt
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+var (
+	accessTokenType  = "access_token"
+	refreshTokenType = "refresh_token"
+)
+
+type IntrospectionRequestData struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+// storedToken is a token which signature, expiration and presence in the storage have been verified
+type storedToken struct {
+	tokenType string
+	token     *jwt.Token
+	claims    map[string]string
+}
+
+// findStoredToken checks token as access and refresh one, the type from hint is checked first
+func (handler *Handler) findStoredToken(tkn string, hint string) (*storedToken, error) {
+	tokenTypes := []string{accessTokenType, refreshTokenType}
+	if hint == refreshTokenType {
+		tokenTypes = []string{refreshTokenType, accessTokenType}
+	}
+	err := ErrTokenInvalid
+	for _, tokenType := range tokenTypes {
+		var found *storedToken
+		if found, err = handler.getStoredToken(tkn, tokenType); err == nil {
+			return found, nil
+		}
+	}
+	return nil, err
+}
+
+func (handler *Handler) getStoredToken(tkn string, tokenType string) (*storedToken, error) {
+	service := &tokenService{}
+	secret := handler.settings.AccessSecretKey
+	if tokenType == refreshTokenType {
+		secret = handler.settings.RefreshSecretKey
+	}
+	parsedToken, parseErr := service.decodeToken(handler.settings, tkn, secret)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	claims, getClaimsErr := service.getClaims(parsedToken, []string{accessUuidClaim, refreshUuidClaim, userIdClaim,
+		expiredClaim, issuedAtClaim, sessionIdClaim, clientIdClaim})
+	if getClaimsErr != nil {
+		return nil, getClaimsErr
+	}
+	if tokenType == refreshTokenType {
+		if err := handler.settings.Storage.HasRefreshToken(claims[refreshUuidClaim], tkn, claims[userIdClaim]); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := handler.settings.Storage.HasAccessToken(claims[accessUuidClaim], tkn, claims[userIdClaim]); err != nil {
+			return nil, err
+		}
+	}
+	if expErr := service.isExpired(handler.settings, claims[expiredClaim]); expErr != nil {
+		return nil, expErr
+	}
+	return &storedToken{tokenType: tokenType, token: parsedToken, claims: claims}, nil
+}
+
+func (handler *Handler) introspectionHandler(c *gin.Context) {
+	if handler.settings.ClientAuthenticator == nil {
+		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, ErrEmptyClientAuthenticator.Error())
+		return
+	}
+	if err := handler.settings.ClientAuthenticator(c); err != nil {
+		handler.settings.ErrResponseFunc(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	requestData := IntrospectionRequestData{}
+	if err := c.ShouldBind(&requestData); err != nil || requestData.Token == "" {
+		handler.settings.ErrResponseFunc(c, http.StatusBadRequest, ErrTokenIsNotProvided.Error())
+		return
+	}
+	found, err := handler.findStoredToken(requestData.Token, requestData.TokenTypeHint)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	service := &tokenService{}
+	response := gin.H{}
+	for name, value := range service.getCustomClaims(found.token) {
+		response[name] = value
+	}
+	response["active"] = true
+	response["sub"] = found.claims[userIdClaim]
+	response["token_type"] = found.tokenType
+	if exp, expErr := service.parseUnix(found.claims[expiredClaim]); expErr == nil {
+		response["exp"] = exp
+	}
+	if iat, iatErr := service.parseUnix(found.claims[issuedAtClaim]); iatErr == nil {
+		response["iat"] = iat
+	}
+	if clientId := found.claims[clientIdClaim]; clientId != "" {
+		response[clientIdClaim] = clientId
+	}
+	if sessionId := found.claims[sessionIdClaim]; sessionId != "" {
+		response[sessionIdClaim] = sessionId
+	}
+	c.JSON(http.StatusOK, response)
+}
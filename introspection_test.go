@@ -0,0 +1,105 @@
+package gwt
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func testIntrospectionInit(token string, hint string, hasAccessTokenErr error, hasRefreshTokenErr error,
+	clientAuthenticator func(c *gin.Context) error) (*httptest.ResponseRecorder, map[string]interface{}) {
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(hasAccessTokenErr)
+	strgMock.On("HasRefreshToken", mock.Anything).Return(hasRefreshTokenErr)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	settings.ClientAuthenticator = clientAuthenticator
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/introspect", handler.GetIntrospectionHandler())
+	form := url.Values{"token": {token}, "token_type_hint": {hint}}
+	request, _ := http.NewRequest(http.MethodPost, "/introspect", strings.NewReader(form.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(rr, request)
+
+	var res map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&res)
+	return rr, res
+}
+
+func allowClient(c *gin.Context) error {
+	return nil
+}
+
+func TestIntrospectionAccessTokenActive(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(),
+		&tokenParams{userId: "1", sessionId: "sid", claims: map[string]interface{}{"role": "admin"}}, "access", "refresh")
+	rr, res := testIntrospectionInit(accessData.token, "", nil, errors.New("not found"), allowClient)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, true, res["active"])
+	assert.Equal(t, "1", res["sub"])
+	assert.Equal(t, "access_token", res["token_type"])
+	assert.Equal(t, float64(accessData.expire), res["exp"])
+	assert.NotNil(t, res["iat"])
+	assert.Equal(t, "sid", res["session_id"])
+	assert.Equal(t, "admin", res["role"])
+}
+
+func TestIntrospectionRefreshTokenActive(t *testing.T) {
+	refreshData, _ := (&tokenService{})._createRefreshToken(getSettingsFixture(),
+		&tokenParams{userId: "1"}, "access", "refresh")
+	rr, res := testIntrospectionInit(refreshData.token, "refresh_token", errors.New("not found"), nil, allowClient)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, true, res["active"])
+	assert.Equal(t, "refresh_token", res["token_type"])
+}
+
+func TestIntrospectionTokenInactive(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(),
+		&tokenParams{userId: "1"}, "access", "refresh")
+	rr, res := testIntrospectionInit(accessData.token, "", errors.New("not found"), errors.New("not found"), allowClient)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, map[string]interface{}{"active": false}, res)
+}
+
+func TestIntrospectionInvalidToken(t *testing.T) {
+	rr, res := testIntrospectionInit("wrong", "", nil, nil, allowClient)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, false, res["active"])
+}
+
+func TestIntrospectionClientError(t *testing.T) {
+	rr, res := testIntrospectionInit("token", "", nil, nil, func(c *gin.Context) error {
+		return errors.New("invalid client")
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "invalid client", res["error_message"])
+}
+
+func TestIntrospectionEmptyClientAuthenticatorError(t *testing.T) {
+	rr, res := testIntrospectionInit("token", "", nil, nil, nil)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, ErrEmptyClientAuthenticator.Error(), res["error_message"])
+}
+
+func TestIntrospectionNoTokenError(t *testing.T) {
+	rr, _ := testIntrospectionInit("", "", nil, nil, allowClient)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,95 @@
+package gwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// jweEncryptions are content encryption algorithms of JWE by key length, the key is used directly (alg dir)
+var jweEncryptions = map[int]string{
+	16: "A128GCM",
+	24: "A192GCM",
+	32: "A256GCM",
+}
+
+// jweHeader is the protected header of JWE compact serialization (RFC 7516)
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Cty string `json:"cty,omitempty"`
+}
+
+// encryptToken wraps the signed token into JWE compact serialization with alg dir and AES GCM
+func encryptToken(key []byte, token string) (string, error) {
+	aead, err := newTokenCipher(key)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(jweHeader{Alg: "dir", Enc: jweEncryptions[len(key)], Cty: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+	iv := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nil, iv, []byte(token), []byte(encodedHeader))
+	tagStart := len(sealed) - aead.Overhead()
+	return strings.Join([]string{
+		encodedHeader,
+		"",
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(sealed[:tagStart]),
+		base64.RawURLEncoding.EncodeToString(sealed[tagStart:]),
+	}, "."), nil
+}
+
+// DecryptToken returns the signed token wrapped into JWE with Settings.TokenEncryptionKey, e.g. to inspect its claims.
+// ErrTokenInvalid is returned for plain tokens and tokens encrypted with another key or algorithm.
+func DecryptToken(key []byte, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[1] != "" {
+		return "", ErrTokenInvalid
+	}
+	headerJson, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+	header := jweHeader{}
+	if err = json.Unmarshal(headerJson, &header); err != nil || header.Alg != "dir" ||
+		header.Enc != jweEncryptions[len(key)] {
+		return "", ErrTokenInvalid
+	}
+	aead, err := newTokenCipher(key)
+	if err != nil {
+		return "", err
+	}
+	iv, ivErr := base64.RawURLEncoding.DecodeString(parts[2])
+	ciphertext, ciphertextErr := base64.RawURLEncoding.DecodeString(parts[3])
+	tag, tagErr := base64.RawURLEncoding.DecodeString(parts[4])
+	if ivErr != nil || ciphertextErr != nil || tagErr != nil || len(iv) != aead.NonceSize() ||
+		len(tag) != aead.Overhead() {
+		return "", ErrTokenInvalid
+	}
+	plain, err := aead.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+	return string(plain), nil
+}
+
+func newTokenCipher(key []byte) (cipher.AEAD, error) {
+	if jweEncryptions[len(key)] == "" {
+		return nil, ErrInvalidEncryptionKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
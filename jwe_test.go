@@ -0,0 +1,125 @@
+package gwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var testEncryptionKey = []byte("token-encryption-key-of-32-bytes")
+
+func TestEncryptTokenRoundTrip(t *testing.T) {
+	for size, enc := range jweEncryptions {
+		key := []byte(strings.Repeat("k", size))
+		encrypted, err := encryptToken(key, "header.claims.signature")
+
+		assert.Nil(t, err)
+		parts := strings.Split(encrypted, ".")
+		assert.Len(t, parts, 5)
+		assert.Empty(t, parts[1])
+		header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+		assert.JSONEq(t, `{"alg": "dir", "enc": "`+enc+`", "cty": "JWT"}`, string(header))
+		assert.NotContains(t, encrypted, "claims")
+
+		decrypted, err := DecryptToken(key, encrypted)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "header.claims.signature", decrypted)
+	}
+}
+
+func TestEncryptTokenUsesRandomIV(t *testing.T) {
+	first, _ := encryptToken(testEncryptionKey, "token")
+	second, _ := encryptToken(testEncryptionKey, "token")
+
+	assert.NotEqual(t, first, second)
+}
+
+func TestDecryptTokenErrors(t *testing.T) {
+	encrypted, _ := encryptToken(testEncryptionKey, "header.claims.signature")
+	parts := strings.Split(encrypted, ".")
+	tamperedCiphertext := []byte(parts[3])
+	tamperedCiphertext[0] ^= 1
+	otherEncHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"dir","enc":"A128GCM"}`))
+
+	cases := map[string]string{
+		"plain token":       "header.claims.signature",
+		"tampered":          strings.Join([]string{parts[0], "", parts[2], string(tamperedCiphertext), parts[4]}, "."),
+		"encrypted key set": strings.Join([]string{parts[0], "key", parts[2], parts[3], parts[4]}, "."),
+		"other enc":         strings.Join([]string{otherEncHeader, "", parts[2], parts[3], parts[4]}, "."),
+		"short tag":         strings.Join([]string{parts[0], "", parts[2], parts[3], parts[4][:4]}, "."),
+	}
+	for name, token := range cases {
+		_, err := DecryptToken(testEncryptionKey, token)
+
+		assert.Equal(t, ErrTokenInvalid, err, name)
+	}
+
+	_, err := DecryptToken([]byte("token-encryption-key-of-32-byte!"), encrypted)
+	assert.Equal(t, ErrTokenInvalid, err)
+}
+
+func TestEncryptTokenInvalidKey(t *testing.T) {
+	_, err := encryptToken([]byte("short"), "token")
+
+	assert.Equal(t, ErrInvalidEncryptionKey, err)
+}
+
+func TestInitInvalidEncryptionKeyError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.TokenEncryptionKey = []byte("short")
+	_, err := Init(*settings)
+
+	assert.ErrorIs(t, err, ErrInvalidEncryptionKey)
+}
+
+func TestHTTPAuthMiddlewareEncryptedToken(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.TokenEncryptionKey = testEncryptionKey
+	accessData, _ := (&tokenService{})._createAccessToken(settings,
+		&tokenParams{userId: "1", claims: map[string]interface{}{"tenant": "acme"}}, "access", "refresh")
+
+	var claims map[string]interface{}
+	rr := testHTTPAuthMiddlewareInit(settings, "Bearer "+accessData.token, func(w http.ResponseWriter, r *http.Request) {
+		claims = ClaimsFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	assert.Len(t, strings.Split(accessData.token, "."), 5)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, claims)
+}
+
+func TestHTTPAuthMiddlewareRejectsPlainTokenWhenEncrypting(t *testing.T) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	settings.TokenEncryptionKey = testEncryptionKey
+
+	rr := testHTTPAuthMiddlewareInit(settings, "Bearer "+accessData.token, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestRefreshEncryptedToken(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.TokenEncryptionKey = testEncryptionKey
+	service := &tokenService{}
+	refreshData, _ := service._createRefreshToken(settings,
+		&tokenParams{userId: "1", claims: map[string]interface{}{"tenant": "acme"}}, "access", "refresh")
+
+	rr := testSessionRefreshInit(settings, nil, refreshData.token)
+	var res map[string]string
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	accessToken, err := service.decodeToken(settings, res["access_token"], settings.AccessSecretKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "acme", service.getCustomClaims(accessToken)["tenant"])
+	_, err = service.decodeToken(settings, res["refresh_token"], settings.RefreshSecretKey)
+	assert.Nil(t, err)
+}
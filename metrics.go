@@ -0,0 +1,502 @@
+package gwt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var storageLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range storageLatencyBuckets {
+		if value <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// metrics keeps in-process counters and histograms, nil metrics ignores all observations
+type metrics struct {
+	mu            sync.Mutex
+	requests      map[[2]string]uint64
+	rejections    map[string]uint64
+	storageErrors map[string]uint64
+	storage       map[string]*histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:      map[[2]string]uint64{},
+		rejections:    map[string]uint64{},
+		storageErrors: map[string]uint64{},
+		storage:       map[string]*histogram{},
+	}
+}
+
+func (m *metrics) observeRequest(handler string, status int) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if status >= http.StatusBadRequest {
+		result = "failure"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{handler, result}]++
+}
+
+func (m *metrics) observeRejection(code int, err error) {
+	if m == nil {
+		return
+	}
+	reason := "internal_error"
+	switch {
+	case errors.Is(err, ErrNoAuthHeader):
+		reason = "no_auth_header"
+	case errors.Is(err, ErrInvalidAuthHeader):
+		reason = "invalid_auth_header"
+	case errors.Is(err, ErrTokenInvalid):
+		reason = "token_invalid"
+	case errors.Is(err, ErrTokenExpired):
+		reason = "token_expired"
+	default:
+		if code < http.StatusInternalServerError {
+			reason = "token_not_found"
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejections[reason]++
+}
+
+func (m *metrics) observeStorage(operation string, started time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.storage[operation]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(storageLatencyBuckets))}
+		m.storage[operation] = h
+	}
+	h.observe(time.Since(started).Seconds())
+	if err != nil {
+		m.storageErrors[operation]++
+	}
+}
+
+// write writes all metrics in prometheus text exposition format
+func (m *metrics) write(sb *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sb.WriteString("# HELP gwt_requests_total Requests handled by auth handlers.\n")
+	sb.WriteString("# TYPE gwt_requests_total counter\n")
+	requestKeys := make([][2]string, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		return requestKeys[i][0]+requestKeys[i][1] < requestKeys[j][0]+requestKeys[j][1]
+	})
+	for _, key := range requestKeys {
+		fmt.Fprintf(sb, "gwt_requests_total{handler=%q,result=%q} %d\n", key[0], key[1], m.requests[key])
+	}
+
+	sb.WriteString("# HELP gwt_auth_rejections_total Requests rejected by auth middleware.\n")
+	sb.WriteString("# TYPE gwt_auth_rejections_total counter\n")
+	for _, reason := range sortedKeys(m.rejections) {
+		fmt.Fprintf(sb, "gwt_auth_rejections_total{reason=%q} %d\n", reason, m.rejections[reason])
+	}
+
+	sb.WriteString("# HELP gwt_storage_errors_total Failed storage operations.\n")
+	sb.WriteString("# TYPE gwt_storage_errors_total counter\n")
+	for _, operation := range sortedKeys(m.storageErrors) {
+		fmt.Fprintf(sb, "gwt_storage_errors_total{operation=%q} %d\n", operation, m.storageErrors[operation])
+	}
+
+	sb.WriteString("# HELP gwt_storage_operation_duration_seconds Latency of storage operations.\n")
+	sb.WriteString("# TYPE gwt_storage_operation_duration_seconds histogram\n")
+	operations := make([]string, 0, len(m.storage))
+	for operation := range m.storage {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		h := m.storage[operation]
+		for i, bound := range storageLatencyBuckets {
+			fmt.Fprintf(sb, "gwt_storage_operation_duration_seconds_bucket{operation=%q,le=\"%g\"} %d\n",
+				operation, bound, h.buckets[i])
+		}
+		fmt.Fprintf(sb, "gwt_storage_operation_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", operation, h.count)
+		fmt.Fprintf(sb, "gwt_storage_operation_duration_seconds_sum{operation=%q} %g\n", operation, h.sum)
+		fmt.Fprintf(sb, "gwt_storage_operation_duration_seconds_count{operation=%q} %d\n", operation, h.count)
+	}
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// instrumentedStorage measures latency of every storage operation,
+// optional storage interfaces return ErrUnsupportedStorage when wrapped storage does not implement them
+type instrumentedStorage struct {
+	storage StorageInterface
+	metrics *metrics
+}
+
+func (is *instrumentedStorage) DeleteTokens(userId string, uuid ...string) error {
+	return is.observe("DeleteTokens", func() error {
+		return is.storage.DeleteTokens(userId, uuid...)
+	})
+}
+func (is *instrumentedStorage) SaveTokens(userId string, accessUuid string, refreshUuid string, accessExpire int64,
+	refreshExpire int64, accessToken string, refreshToken string) error {
+	return is.observe("SaveTokens", func() error {
+		return is.storage.SaveTokens(userId, accessUuid, refreshUuid, accessExpire, refreshExpire, accessToken, refreshToken)
+	})
+}
+func (is *instrumentedStorage) HasRefreshToken(uuid string, token string, userId string) error {
+	return is.observe("HasRefreshToken", func() error {
+		return is.storage.HasRefreshToken(uuid, token, userId)
+	})
+}
+func (is *instrumentedStorage) HasAccessToken(uuid string, token string, userId string) error {
+	return is.observe("HasAccessToken", func() error {
+		return is.storage.HasAccessToken(uuid, token, userId)
+	})
+}
+func (is *instrumentedStorage) DeleteAllTokens(userId string) error {
+	return is.observe("DeleteAllTokens", func() error {
+		return is.storage.DeleteAllTokens(userId)
+	})
+}
+
+func (is *instrumentedStorage) AddLoginAttempt(key string, expire int64) (count int64, err error) {
+	attemptStorage, ok := is.storage.(LoginAttemptStorageInterface)
+	if !ok {
+		return 0, ErrUnsupportedStorage
+	}
+	err = is.observe("AddLoginAttempt", func() (opErr error) {
+		count, opErr = attemptStorage.AddLoginAttempt(key, expire)
+		return opErr
+	})
+	return count, err
+}
+func (is *instrumentedStorage) GetLoginAttempts(key string) (count int64, last int64, err error) {
+	attemptStorage, ok := is.storage.(LoginAttemptStorageInterface)
+	if !ok {
+		return 0, 0, ErrUnsupportedStorage
+	}
+	err = is.observe("GetLoginAttempts", func() (opErr error) {
+		count, last, opErr = attemptStorage.GetLoginAttempts(key)
+		return opErr
+	})
+	return count, last, err
+}
+func (is *instrumentedStorage) DeleteLoginAttempts(key string) error {
+	attemptStorage, ok := is.storage.(LoginAttemptStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("DeleteLoginAttempts", func() error {
+		return attemptStorage.DeleteLoginAttempts(key)
+	})
+}
+
+func (is *instrumentedStorage) SaveMFASecret(userId string, secret string, recoveryCodes []string) error {
+	mfaStorage, ok := is.storage.(MFAStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("SaveMFASecret", func() error {
+		return mfaStorage.SaveMFASecret(userId, secret, recoveryCodes)
+	})
+}
+func (is *instrumentedStorage) GetMFASecret(userId string) (secret string, err error) {
+	mfaStorage, ok := is.storage.(MFAStorageInterface)
+	if !ok {
+		return "", ErrUnsupportedStorage
+	}
+	err = is.observe("GetMFASecret", func() (opErr error) {
+		secret, opErr = mfaStorage.GetMFASecret(userId)
+		return opErr
+	})
+	return secret, err
+}
+func (is *instrumentedStorage) UseMFARecoveryCode(userId string, recoveryCode string) error {
+	mfaStorage, ok := is.storage.(MFAStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("UseMFARecoveryCode", func() error {
+		return mfaStorage.UseMFARecoveryCode(userId, recoveryCode)
+	})
+}
+func (is *instrumentedStorage) DeleteMFASecret(userId string) error {
+	mfaStorage, ok := is.storage.(MFAStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("DeleteMFASecret", func() error {
+		return mfaStorage.DeleteMFASecret(userId)
+	})
+}
+func (is *instrumentedStorage) UseMFAStep(userId string, step int64) error {
+	mfaStorage, ok := is.storage.(MFAStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("UseMFAStep", func() error {
+		return mfaStorage.UseMFAStep(userId, step)
+	})
+}
+func (is *instrumentedStorage) SaveMFAToken(tokenId string, expire int64) error {
+	mfaStorage, ok := is.storage.(MFAStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("SaveMFAToken", func() error {
+		return mfaStorage.SaveMFAToken(tokenId, expire)
+	})
+}
+func (is *instrumentedStorage) UseMFAToken(tokenId string) error {
+	mfaStorage, ok := is.storage.(MFAStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("UseMFAToken", func() error {
+		return mfaStorage.UseMFAToken(tokenId)
+	})
+}
+
+func (is *instrumentedStorage) SaveSession(session *Session, expire int64) error {
+	sessionStorage, ok := is.storage.(SessionStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("SaveSession", func() error {
+		return sessionStorage.SaveSession(session, expire)
+	})
+}
+func (is *instrumentedStorage) GetSession(userId string, sessionId string) (session *Session, err error) {
+	sessionStorage, ok := is.storage.(SessionStorageInterface)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	err = is.observe("GetSession", func() (opErr error) {
+		session, opErr = sessionStorage.GetSession(userId, sessionId)
+		return opErr
+	})
+	return session, err
+}
+func (is *instrumentedStorage) DeleteSession(userId string, sessionId string) error {
+	sessionStorage, ok := is.storage.(SessionStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("DeleteSession", func() error {
+		return sessionStorage.DeleteSession(userId, sessionId)
+	})
+}
+func (is *instrumentedStorage) GetSessions(userId string) (sessions []*Session, err error) {
+	sessionListStorage, ok := is.storage.(SessionListStorageInterface)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	err = is.observe("GetSessions", func() (opErr error) {
+		sessions, opErr = sessionListStorage.GetSessions(userId)
+		return opErr
+	})
+	return sessions, err
+}
+
+func (is *instrumentedStorage) SaveImpersonation(impersonation *Impersonation, expire int64) error {
+	impersonationStorage, ok := is.storage.(ImpersonationStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("SaveImpersonation", func() error {
+		return impersonationStorage.SaveImpersonation(impersonation, expire)
+	})
+}
+func (is *instrumentedStorage) GetImpersonations() (impersonations []*Impersonation, err error) {
+	impersonationStorage, ok := is.storage.(ImpersonationStorageInterface)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	err = is.observe("GetImpersonations", func() (opErr error) {
+		impersonations, opErr = impersonationStorage.GetImpersonations()
+		return opErr
+	})
+	return impersonations, err
+}
+func (is *instrumentedStorage) DeleteImpersonation(id string) error {
+	impersonationStorage, ok := is.storage.(ImpersonationStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("DeleteImpersonation", func() error {
+		return impersonationStorage.DeleteImpersonation(id)
+	})
+}
+
+func (is *instrumentedStorage) DeleteAllSessions(userId string) (removed int64, err error) {
+	removalStorage, ok := is.storage.(SessionRemovalStorageInterface)
+	if !ok {
+		return 0, ErrUnsupportedStorage
+	}
+	err = is.observe("DeleteAllSessions", func() (opErr error) {
+		removed, opErr = removalStorage.DeleteAllSessions(userId)
+		return opErr
+	})
+	return removed, err
+}
+
+func (is *instrumentedStorage) DeleteAllTokensExcept(userId string, accessUuid string, refreshUuid string) (removed int64, err error) {
+	removalStorage, ok := is.storage.(SessionRemovalStorageInterface)
+	if !ok {
+		return 0, ErrUnsupportedStorage
+	}
+	err = is.observe("DeleteAllTokensExcept", func() (opErr error) {
+		removed, opErr = removalStorage.DeleteAllTokensExcept(userId, accessUuid, refreshUuid)
+		return opErr
+	})
+	return removed, err
+}
+
+func (is *instrumentedStorage) SaveAccessToken(userId string, accessUuid string, accessExpire int64,
+	accessToken string) error {
+	accessStorage, ok := is.storage.(AccessTokenStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("SaveAccessToken", func() error {
+		return accessStorage.SaveAccessToken(userId, accessUuid, accessExpire, accessToken)
+	})
+}
+
+func (is *instrumentedStorage) PurgeExpired() error {
+	purgeStorage, ok := is.storage.(PurgeStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("PurgeExpired", func() error {
+		return purgeStorage.PurgeExpired()
+	})
+}
+
+func (is *instrumentedStorage) SaveTicket(ticketHash string, accessToken string, expire int64) error {
+	ticketStorage, ok := is.storage.(TicketStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("SaveTicket", func() error {
+		return ticketStorage.SaveTicket(ticketHash, accessToken, expire)
+	})
+}
+
+func (is *instrumentedStorage) UseTicket(ticketHash string) (accessToken string, err error) {
+	ticketStorage, ok := is.storage.(TicketStorageInterface)
+	if !ok {
+		return "", ErrUnsupportedStorage
+	}
+	err = is.observe("UseTicket", func() (opErr error) {
+		accessToken, opErr = ticketStorage.UseTicket(ticketHash)
+		return opErr
+	})
+	return accessToken, err
+}
+
+func (is *instrumentedStorage) SaveOpaqueToken(tokenHash string, token *OpaqueToken) error {
+	opaqueStorage, ok := is.storage.(OpaqueTokenStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("SaveOpaqueToken", func() error {
+		return opaqueStorage.SaveOpaqueToken(tokenHash, token)
+	})
+}
+
+func (is *instrumentedStorage) GetOpaqueToken(tokenHash string) (token *OpaqueToken, err error) {
+	opaqueStorage, ok := is.storage.(OpaqueTokenStorageInterface)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	err = is.observe("GetOpaqueToken", func() (opErr error) {
+		token, opErr = opaqueStorage.GetOpaqueToken(tokenHash)
+		return opErr
+	})
+	return token, err
+}
+
+func (is *instrumentedStorage) DeleteOpaqueTokens(uuids ...string) error {
+	opaqueStorage, ok := is.storage.(OpaqueTokenStorageInterface)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return is.observe("DeleteOpaqueTokens", func() error {
+		return opaqueStorage.DeleteOpaqueTokens(uuids...)
+	})
+}
+
+func (is *instrumentedStorage) observe(operation string, fn func() error) error {
+	started := time.Now()
+	err := fn()
+	is.metrics.observeStorage(operation, started, err)
+	return err
+}
+
+// unwrapStorage returns storage as T if the storage, or the storage wrapped by instrumentedStorage, implements T.
+// instrumentedStorage itself implements every optional interface and fails with ErrUnsupportedStorage.
+func unwrapStorage[T any](storage StorageInterface) (T, bool) {
+	wrapped := storage
+	if is, ok := storage.(*instrumentedStorage); ok {
+		wrapped = is.storage
+	}
+	if _, ok := wrapped.(T); !ok {
+		var empty T
+		return empty, false
+	}
+	typed, ok := storage.(T)
+	return typed, ok
+}
+
+func (handler *Handler) metricsHandler(c *gin.Context) {
+	sb := &strings.Builder{}
+	if handler.settings.metrics != nil {
+		handler.settings.metrics.write(sb)
+	}
+	c.Data(http.StatusOK, metricsContentType, []byte(sb.String()))
+}
+
+// instrument counts results of the handler by response status
+func (handler *Handler) instrument(name string, h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h(c)
+		handler.settings.metrics.observeRequest(name, c.Writer.Status())
+	}
+}
@@ -0,0 +1,84 @@
+package gwt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	strgMock := new(storageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	auth, _ := Init(*settings)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", auth.Handler.GetLoginHandler())
+	router.GET("/metrics", auth.Handler.GetMetricsHandler())
+	router.Use(auth.Middleware.GetAuthMiddleware()).GET("/test-auth", func(c *gin.Context) {})
+
+	request, _ := http.NewRequest(http.MethodPost, "/login", nil)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+	request, _ = http.NewRequest(http.MethodGet, "/test-auth", nil)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	rr := httptest.NewRecorder()
+	request, _ = http.NewRequest(http.MethodGet, "/metrics", nil)
+	router.ServeHTTP(rr, request)
+	body := rr.Body.String()
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, metricsContentType, rr.Header().Get("Content-Type"))
+	assert.Contains(t, body, `gwt_requests_total{handler="login",result="success"} 1`)
+	assert.Contains(t, body, `gwt_auth_rejections_total{reason="no_auth_header"} 1`)
+	assert.Contains(t, body, `gwt_storage_operation_duration_seconds_count{operation="SaveTokens"} 1`)
+	assert.Contains(t, body, `gwt_storage_operation_duration_seconds_bucket{operation="SaveTokens",le="+Inf"} 1`)
+}
+
+func TestInstrumentedStorageErrors(t *testing.T) {
+	strgMock := new(storageMock)
+	strgMock.On("DeleteAllTokens", mock.Anything).Return(errors.New("delete error"))
+	m := newMetrics()
+	strg := &instrumentedStorage{storage: strgMock, metrics: m}
+
+	assert.EqualError(t, strg.DeleteAllTokens("1"), "delete error")
+	assert.Equal(t, uint64(1), m.storageErrors["DeleteAllTokens"])
+	assert.Equal(t, uint64(1), m.storage["DeleteAllTokens"].count)
+}
+
+func TestObserveRejectionWrappedError(t *testing.T) {
+	m := newMetrics()
+	m.observeRejection(http.StatusUnauthorized, fmt.Errorf("storage: %w", ErrTokenExpired))
+	m.observeRejection(http.StatusBadRequest, fmt.Errorf("decode: %w", ErrTokenInvalid))
+
+	assert.Equal(t, uint64(1), m.rejections["token_expired"])
+	assert.Equal(t, uint64(1), m.rejections["token_invalid"])
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *metrics
+	assert.NotPanics(t, func() {
+		m.observeRequest("login", http.StatusOK)
+		m.observeRejection(http.StatusUnauthorized, ErrTokenExpired)
+	})
+}
+
+func TestUnwrapStorage(t *testing.T) {
+	strg := &instrumentedStorage{storage: new(sessionStorageMock), metrics: newMetrics()}
+
+	_, ok := unwrapStorage[SessionStorageInterface](strg)
+	assert.True(t, ok)
+	_, ok = unwrapStorage[MFAStorageInterface](strg)
+	assert.False(t, ok)
+	_, ok = unwrapStorage[MFAStorageInterface](new(mfaStorageMock))
+	assert.True(t, ok)
+	_, ok = unwrapStorage[SessionStorageInterface](nil)
+	assert.False(t, ok)
+}
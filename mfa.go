@@ -0,0 +1,198 @@
+package gwt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	totpPeriod             = int64(30)
+	totpDigits             = 6
+	totpSkew               = int64(1)
+	mfaSecretLength        = 20
+	mfaRecoveryCodesNumber = 10
+	mfaRecoveryCodeLength  = 10
+	mfaRecoveryCodeChars   = "abcdefghjkmnpqrstuvwxyz23456789"
+	mfaMaxAttempts         = int64(5)
+)
+
+// MFAEnrollment is returned by Service.EnrollMFA, recovery codes are shown to user once and stored hashed
+type MFAEnrollment struct {
+	Secret        string
+	URL           string
+	RecoveryCodes []string
+}
+
+type MFAVerifyRequestData struct {
+	MFAToken string `json:"mfa_token" form:"mfa_token"`
+	Code     string `json:"code" form:"code"`
+}
+
+type DefaultMFAResponse struct {
+	MFAToken  string `json:"mfa_token"`
+	MFAExpire int64  `json:"mfa_expire"`
+}
+
+// generateTOTP returns RFC 6238 code of the time step
+func generateTOTP(secret []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, code%mod)
+}
+
+// validateTOTP checks code against current time step and adjacent steps to tolerate clock drift,
+// the matching time step is returned
+func validateTOTP(encodedSecret string, code string, now time.Time) (int64, bool) {
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(encodedSecret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	step := now.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		if subtle.ConstantTimeCompare([]byte(generateTOTP(secret, step+i)), []byte(code)) == 1 {
+			return step + i, true
+		}
+	}
+	return 0, false
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+func newMFAEnrollment(issuer string, accountName string) (*MFAEnrollment, error) {
+	secret := make([]byte, mfaSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	enrollment := &MFAEnrollment{Secret: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)}
+	query := url.Values{}
+	query.Set("secret", enrollment.Secret)
+	query.Set("issuer", issuer)
+	enrollment.URL = (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + accountName,
+		RawQuery: query.Encode(),
+	}).String()
+
+	for i := 0; i < mfaRecoveryCodesNumber; i++ {
+		code := make([]byte, mfaRecoveryCodeLength)
+		if _, err := rand.Read(code); err != nil {
+			return nil, err
+		}
+		for j := range code {
+			code[j] = mfaRecoveryCodeChars[int(code[j])%len(mfaRecoveryCodeChars)]
+		}
+		enrollment.RecoveryCodes = append(enrollment.RecoveryCodes, string(code))
+	}
+	return enrollment, nil
+}
+
+// verifyMFACode checks TOTP code, then falls back to one-time recovery code. The time step of accepted
+// TOTP code is saved, so the code cannot be used again.
+func verifyMFACode(settings *Settings, mfaStorage MFAStorageInterface, userId string, code string) error {
+	secret, err := mfaStorage.GetMFASecret(userId)
+	if err != nil {
+		return err
+	}
+	if step, ok := validateTOTP(secret, strings.TrimSpace(code), settings.now()); ok {
+		return mfaStorage.UseMFAStep(userId, step)
+	}
+	if mfaStorage.UseMFARecoveryCode(userId, hashRecoveryCode(code)) != nil {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// mfaThrottleKey limits failed second factor checks of the user, LoginThrottle.MaxAttemptsPerIdentifier
+// is used if it is set
+func (handler *Handler) mfaThrottleKey(userId string) throttleKey {
+	maxAttempts := handler.settings.LoginThrottle.MaxAttemptsPerIdentifier
+	if maxAttempts <= 0 {
+		maxAttempts = mfaMaxAttempts
+	}
+	return throttleKey{key: "mfa:" + userId, maxAttempts: maxAttempts, identifier: true}
+}
+
+func (handler *Handler) mfaVerifyHandler(c *gin.Context) {
+	hooks := &handler.settings.Hooks
+	requestData := MFAVerifyRequestData{}
+	if err := c.ShouldBind(&requestData); err != nil || requestData.MFAToken == "" || requestData.Code == "" {
+		handler.fail(c, hooks.OnLoginFailure, http.StatusBadRequest, ErrMFATokenIsNotProvided, "", "")
+		return
+	}
+	params, verifyErr := handler.verifyMFA(handler.ginLoginRequest(c), &requestData)
+	if verifyErr != nil {
+		handler.fail(c, hooks.OnLoginFailure, verifyErr.code, verifyErr.err, verifyErr.userId, "")
+		return
+	}
+	handler.issueTokens(c, params)
+}
+
+// verifyMFA checks mfa token and second factor code of the user, failed checks are throttled per user.
+// The token is accepted once, params of the login it was issued for are returned.
+func (handler *Handler) verifyMFA(req *loginRequest, requestData *MFAVerifyRequestData) (*tokenParams, *tokenError) {
+	service := &tokenService{}
+	mfaStorage, ok := unwrapStorage[MFAStorageInterface](handler.settings.Storage)
+	if !ok {
+		return nil, &tokenError{code: http.StatusInternalServerError, err: ErrUnsupportedStorage}
+	}
+	parsedToken, parseErr := service.decodeToken(handler.settings, requestData.MFAToken, handler.settings.AccessSecretKey)
+	if parseErr != nil {
+		return nil, &tokenError{code: http.StatusUnauthorized, err: parseErr}
+	}
+	claims, getClaimsErr := service.getClaims(parsedToken, []string{mfaClaim, mfaUuidClaim, userIdClaim, expiredClaim})
+	if getClaimsErr != nil || claims[mfaClaim] != "true" {
+		return nil, &tokenError{code: http.StatusUnauthorized, err: ErrTokenInvalid}
+	}
+	userId := claims[userIdClaim]
+	if expErr := service.isExpired(handler.settings, claims[expiredClaim]); expErr != nil {
+		return nil, &tokenError{code: http.StatusUnauthorized, err: expErr, userId: userId}
+	}
+	throttleKeys := []throttleKey{handler.mfaThrottleKey(userId)}
+	if throttleErr := handler.checkLoginThrottle(req.response, throttleKeys, req.captcha); throttleErr != nil {
+		throttleErr.userId = userId
+		return nil, throttleErr
+	}
+	if verifyErr := verifyMFACode(handler.settings, mfaStorage, userId, requestData.Code); verifyErr != nil {
+		handler.registerFailedLogin(throttleKeys)
+		return nil, &tokenError{code: http.StatusUnauthorized, err: verifyErr, userId: userId}
+	}
+	handler.resetFailedLogins(throttleKeys)
+	if useErr := mfaStorage.UseMFAToken(claims[mfaUuidClaim]); useErr != nil {
+		if errors.Is(useErr, ErrMFATokenNotFound) {
+			return nil, &tokenError{code: http.StatusUnauthorized, err: useErr, userId: userId}
+		}
+		return nil, &tokenError{code: http.StatusInternalServerError, err: useErr, userId: userId}
+	}
+
+	params, restoreErr := service.restoreParams(parsedToken)
+	if restoreErr != nil {
+		return nil, &tokenError{code: http.StatusUnauthorized, err: restoreErr, userId: userId}
+	}
+	return params, nil
+}
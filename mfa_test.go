@@ -0,0 +1,236 @@
+package gwt
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var mfaSecretFixture = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateTOTP(t *testing.T) {
+	assert.Equal(t, "287082", generateTOTP([]byte("12345678901234567890"), 59/totpPeriod))
+	assert.Equal(t, "005924", generateTOTP([]byte("12345678901234567890"), 1234567890/totpPeriod))
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step, ok := validateTOTP(mfaSecretFixture, "005924", now)
+	assert.True(t, ok)
+	assert.Equal(t, 1234567890/totpPeriod, step)
+
+	step, ok = validateTOTP(mfaSecretFixture, "005924", now.Add(time.Second*30))
+	assert.True(t, ok)
+	assert.Equal(t, 1234567890/totpPeriod, step)
+
+	_, ok = validateTOTP(mfaSecretFixture, "005924", now.Add(time.Minute*2))
+	assert.False(t, ok)
+	_, ok = validateTOTP(mfaSecretFixture, "123", now)
+	assert.False(t, ok)
+	_, ok = validateTOTP("!!!", "005924", now)
+	assert.False(t, ok)
+}
+
+func TestNewMFAEnrollment(t *testing.T) {
+	enrollment, err := newMFAEnrollment("App", "user@example.com")
+
+	assert.Nil(t, err)
+	assert.Len(t, enrollment.RecoveryCodes, mfaRecoveryCodesNumber)
+	assert.True(t, strings.HasPrefix(enrollment.URL, "otpauth://totp/App:user@example.com?"))
+	assert.Contains(t, enrollment.URL, "secret="+enrollment.Secret)
+}
+
+func newMFAStorageMock(useRecoveryCodeErr error) *mfaStorageMock {
+	strgMock := new(mfaStorageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	strgMock.On("SaveMFAToken", mock.Anything).Return(nil)
+	strgMock.On("UseMFAToken", mock.Anything).Return(nil)
+	strgMock.On("GetMFASecret", mock.Anything).Return(mfaSecretFixture, nil)
+	strgMock.On("UseMFAStep", mock.Anything).Return(nil)
+	strgMock.On("UseMFARecoveryCode", mock.Anything).Return(useRecoveryCodeErr)
+	strgMock.On("GetLoginAttempts", mock.Anything).Return(int64(0), int64(0), nil)
+	strgMock.On("AddLoginAttempt", mock.Anything).Return(int64(1), nil)
+	strgMock.On("DeleteLoginAttempts", mock.Anything).Return(nil)
+	return strgMock
+}
+
+func getMFASettingsFixture(strgMock *mfaStorageMock) *Settings {
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	settings.MFATokenLifetime = time.Minute
+	return settings
+}
+
+func TestLoginSecondFactorRequired(t *testing.T) {
+	strgMock := newMFAStorageMock(nil)
+	settings := getMFASettingsFixture(strgMock)
+	settings.Authenticator = func(c *gin.Context) (string, error) {
+		return "1", ErrSecondFactorRequired
+	}
+	auth, _ := Init(*settings)
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/login", auth.Handler.GetLoginHandler())
+	request, _ := http.NewRequest(http.MethodPost, "/login", nil)
+	router.ServeHTTP(rr, request)
+
+	var res DefaultMFAResponse
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.NotEmpty(t, res.MFAToken)
+	assert.Greater(t, res.MFAExpire, time.Now().Unix())
+	strgMock.AssertCalled(t, "SaveMFAToken")
+}
+
+func TestLoginSecondFactorUnsupportedStorage(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Authenticator = func(c *gin.Context) (string, error) {
+		return "1", ErrSecondFactorRequired
+	}
+	auth, _ := Init(*settings)
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/login", auth.Handler.GetLoginHandler())
+	request, _ := http.NewRequest(http.MethodPost, "/login", nil)
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrUnsupportedStorage.Error())
+}
+
+func testMFAVerifyInit(settings *Settings, mfaToken string, code string) *httptest.ResponseRecorder {
+	auth, _ := Init(*settings)
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/mfa", auth.Handler.GetMFAVerifyHandler())
+	params, _ := json.Marshal(map[string]string{"mfa_token": mfaToken, "code": code})
+	request, _ := http.NewRequest(http.MethodPost, "/mfa", bytes.NewBuffer(params))
+	request.Header.Add("Content-Type", "application/json")
+	router.ServeHTTP(rr, request)
+	return rr
+}
+
+func TestMFAVerifySuccess(t *testing.T) {
+	strgMock := newMFAStorageMock(ErrInvalidMFACode)
+	settings := getMFASettingsFixture(strgMock)
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	code := generateTOTP([]byte("12345678901234567890"), time.Now().Unix()/totpPeriod)
+	rr := testMFAVerifyInit(settings, mfaToken, code)
+
+	var res map[string]string
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, res["access_token"])
+	assert.NotEmpty(t, res["refresh_token"])
+	strgMock.AssertCalled(t, "UseMFAStep")
+	strgMock.AssertCalled(t, "UseMFAToken")
+	strgMock.AssertCalled(t, "DeleteLoginAttempts")
+}
+
+func TestMFAVerifyRecoveryCodeSuccess(t *testing.T) {
+	settings := getMFASettingsFixture(newMFAStorageMock(nil))
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	rr := testMFAVerifyInit(settings, mfaToken, "recoverycode")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestMFAVerifyInvalidCodeError(t *testing.T) {
+	strgMock := newMFAStorageMock(ErrInvalidMFACode)
+	settings := getMFASettingsFixture(strgMock)
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	rr := testMFAVerifyInit(settings, mfaToken, "000000")
+
+	var res map[string]string
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, ErrInvalidMFACode.Error(), res["error_message"])
+	strgMock.AssertCalled(t, "AddLoginAttempt")
+	strgMock.AssertNotCalled(t, "UseMFAToken")
+}
+
+func TestMFAVerifyReusedCodeError(t *testing.T) {
+	strgMock := new(mfaStorageMock)
+	strgMock.On("SaveMFAToken", mock.Anything).Return(nil)
+	strgMock.On("GetMFASecret", mock.Anything).Return(mfaSecretFixture, nil)
+	strgMock.On("UseMFAStep", mock.Anything).Return(ErrInvalidMFACode)
+	strgMock.On("GetLoginAttempts", mock.Anything).Return(int64(0), int64(0), nil)
+	strgMock.On("AddLoginAttempt", mock.Anything).Return(int64(1), nil)
+	settings := getMFASettingsFixture(strgMock)
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	code := generateTOTP([]byte("12345678901234567890"), time.Now().Unix()/totpPeriod)
+	rr := testMFAVerifyInit(settings, mfaToken, code)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrInvalidMFACode.Error())
+}
+
+func TestMFAVerifyUsedTokenError(t *testing.T) {
+	strgMock := new(mfaStorageMock)
+	strgMock.On("SaveMFAToken", mock.Anything).Return(nil)
+	strgMock.On("UseMFAToken", mock.Anything).Return(ErrMFATokenNotFound)
+	strgMock.On("GetMFASecret", mock.Anything).Return(mfaSecretFixture, nil)
+	strgMock.On("UseMFARecoveryCode", mock.Anything).Return(nil)
+	strgMock.On("GetLoginAttempts", mock.Anything).Return(int64(0), int64(0), nil)
+	strgMock.On("DeleteLoginAttempts", mock.Anything).Return(nil)
+	settings := getMFASettingsFixture(strgMock)
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	rr := testMFAVerifyInit(settings, mfaToken, "recoverycode")
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrMFATokenNotFound.Error())
+	strgMock.AssertNotCalled(t, "SaveTokens")
+}
+
+func TestMFAVerifyThrottledWithoutLoginThrottle(t *testing.T) {
+	strgMock := new(mfaStorageMock)
+	strgMock.On("SaveMFAToken", mock.Anything).Return(nil)
+	strgMock.On("GetLoginAttempts", mock.Anything).Return(mfaMaxAttempts, time.Now().Unix(), nil)
+	settings := getMFASettingsFixture(strgMock)
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	rr := testMFAVerifyInit(settings, mfaToken, "000000")
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get(retryAfterHeader))
+	strgMock.AssertNotCalled(t, "GetMFASecret")
+}
+
+func TestMFAVerifyUnsupportedStorage(t *testing.T) {
+	settings := getMFASettingsFixture(newMFAStorageMock(nil))
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	settings.Storage = new(storageMock)
+	rr := testMFAVerifyInit(settings, mfaToken, "000000")
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrUnsupportedStorage.Error())
+}
+
+func TestMFAVerifyAccessTokenError(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
+	rr := testMFAVerifyInit(getMFASettingsFixture(newMFAStorageMock(nil)), accessData.token, "000000")
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMFAVerifyNoParamsError(t *testing.T) {
+	rr := testMFAVerifyInit(getMFASettingsFixture(newMFAStorageMock(nil)), "", "")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
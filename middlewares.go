@@ -3,6 +3,8 @@ package gwt
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 type Middleware struct {
@@ -15,39 +17,163 @@ func (mw *Middleware) GetAuthMiddleware() gin.HandlerFunc {
 
 func (mw *Middleware) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		service := &tokenService{}
-		if additionalHeader := mw.settings.AdditionalAuthHeader; additionalHeader != "" {
-			c.Request.Header.Add(authHeader, c.Request.Header.Get(additionalHeader))
-		}
-		accessToken, getErr := getHeaderToken(c.Request.Header.Get(authHeader), mw.settings.AuthHeadName)
-		if getErr != nil {
-			mw.settings.ErrResponseFunc(c, http.StatusUnauthorized, getErr.Error())
-			return
-		}
-		parsedToken, parseErr := service.parseToken(accessToken, mw.settings.AccessSecretKey, mw.settings.SigningMethod)
-		if parseErr != nil {
-			mw.settings.ErrResponseFunc(c, http.StatusBadRequest, parseErr.Error())
+		authenticated, authErr := authenticateRequest(mw.settings, c.Request, !mw.settings.LazyUserLoading)
+		if authErr != nil {
+			mw.fail(c, authErr.code, authErr.err, authErr.userId, authErr.sessionId)
 			return
 		}
-		claims, getClaimsErr := service.getClaims(parsedToken, []string{accessUuidClaim, userIdClaim, expiredClaim})
-		if getClaimsErr != nil {
-			mw.settings.ErrResponseFunc(c, http.StatusBadRequest, getClaimsErr.Error())
-			return
-		}
-		if tokenExpErr := mw.settings.Storage.HasAccessToken(claims[accessUuidClaim], accessToken, claims[userIdClaim]); tokenExpErr != nil {
-			mw.settings.ErrResponseFunc(c, http.StatusUnauthorized, tokenExpErr.Error())
-			return
+		authenticated.setContext(c)
+		c.Next()
+	}
+}
+
+// principal is the owner of the authenticated access token, it is kept in the request context
+type principal struct {
+	settings     *Settings
+	mu           sync.Mutex
+	userId       string
+	accessToken  string
+	user         interface{}
+	userLoaded   bool
+	client       *ClientPrincipal
+	actor        *Actor
+	claims       map[string]string
+	customClaims map[string]interface{}
+}
+
+// authenticateRequest checks access token of the request and loads the user if loadUser is true,
+// GetUserFunc is not called for client tokens
+func authenticateRequest(settings *Settings, r *http.Request, loadUser bool) (*principal, *tokenError) {
+	service := &tokenService{}
+	accessToken, getErr := requestAccessToken(settings, r)
+	if getErr != nil {
+		return nil, getErr
+	}
+	parsedToken, parseErr := service.decodeToken(settings, accessToken, settings.AccessSecretKey)
+	if parseErr != nil {
+		return nil, &tokenError{code: parseErrorCode(parseErr), err: parseErr}
+	}
+	claims, getClaimsErr := service.getClaims(parsedToken, []string{accessUuidClaim, refreshUuidClaim, userIdClaim,
+		expiredClaim, sessionIdClaim, clientIdClaim})
+	if getClaimsErr != nil {
+		return nil, &tokenError{code: http.StatusBadRequest, err: getClaimsErr}
+	}
+	userId, sessionId := claims[userIdClaim], service.getSessionId(claims)
+	if tokenExpErr := settings.Storage.HasAccessToken(claims[accessUuidClaim], accessToken, claims[userIdClaim]); tokenExpErr != nil {
+		return nil, &tokenError{code: http.StatusUnauthorized, err: tokenExpErr, userId: userId, sessionId: sessionId}
+	}
+	if expErr := service.isExpired(settings, claims[expiredClaim]); expErr != nil {
+		return nil, &tokenError{code: http.StatusUnauthorized, err: expErr, userId: userId, sessionId: sessionId}
+	}
+	authenticated := &principal{settings: settings, userId: userId, accessToken: accessToken, claims: claims,
+		customClaims: service.getCustomClaims(parsedToken)}
+	if clientId := claims[clientIdClaim]; clientId != "" {
+		scope, _ := authenticated.customClaims[scopeClaim].(string)
+		authenticated.client = &ClientPrincipal{ClientId: clientId, Scopes: strings.Fields(scope)}
+		return authenticated, nil
+	}
+	if actorId := service.getActorId(parsedToken); actorId != "" {
+		authenticated.actor = &Actor{UserId: actorId, SessionId: sessionId}
+	}
+	if !loadUser {
+		return authenticated, nil
+	}
+	user, userErr := getUser(settings, claims[userIdClaim])
+	if userErr != nil {
+		return nil, &tokenError{code: http.StatusInternalServerError, err: userErr, userId: userId, sessionId: sessionId}
+	}
+	authenticated.user, authenticated.userLoaded = user, true
+	return authenticated, nil
+}
+
+// requestAccessToken returns access token of the request. Websocket upgrade requests may pass a ticket
+// in the query or the token in Sec-WebSocket-Protocol, as browsers cannot set headers on them.
+func requestAccessToken(settings *Settings, r *http.Request) (string, *tokenError) {
+	if isWebSocketUpgrade(r) {
+		if ticket := r.URL.Query().Get(ticketQueryParam); ticket != "" {
+			return useTicket(settings, ticket)
 		}
-		if expErr := service.isExpired(claims[expiredClaim]); expErr != nil {
-			mw.settings.ErrResponseFunc(c, http.StatusUnauthorized, expErr.Error())
-			return
+		if accessToken := getProtocolToken(r.Header.Values(webSocketProtocolHeader)); accessToken != "" {
+			return accessToken, nil
 		}
-		user, userErr := mw.settings.GetUserFunc(claims[userIdClaim])
-		if userErr != nil {
-			mw.settings.ErrResponseFunc(c, http.StatusInternalServerError, userErr.Error())
-			return
+	}
+	if additionalHeader := settings.AdditionalAuthHeader; additionalHeader != "" {
+		r.Header.Add(authHeader, r.Header.Get(additionalHeader))
+	}
+	accessToken, getErr := getHeaderToken(r.Header.Get(authHeader), settings.AuthHeadName)
+	if getErr != nil {
+		return "", &tokenError{code: http.StatusUnauthorized, err: getErr}
+	}
+	return accessToken, nil
+}
+
+// requester returns the user, or the client principal for client tokens
+func (p *principal) requester() interface{} {
+	if p.client != nil {
+		return p.client
+	}
+	return p.user
+}
+
+func (p *principal) sessionId() string {
+	return (&tokenService{}).getSessionId(p.claims)
+}
+
+// loadUser returns the user loaded by the middleware or loads it with GetUserFunc once
+func (p *principal) loadUser() (interface{}, error) {
+	if p.client != nil {
+		return nil, ErrNotAuthUser
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.userLoaded {
+		return p.user, nil
+	}
+	user, err := getUser(p.settings, p.userId)
+	if err != nil {
+		return nil, err
+	}
+	p.user, p.userLoaded = user, true
+	return user, nil
+}
+
+// setContext stores the principal in gin context and in the context of the request,
+// so net/http accessors like UserFromContext work in gin handlers as well
+func (p *principal) setContext(c *gin.Context) {
+	if p.client != nil {
+		c.Set(ClientKey, p.client)
+	} else {
+		c.Set(UserIdKey, p.userId)
+		if p.userLoaded {
+			c.Set(UserKey, p.user)
 		}
-		c.Set(UserKey, user)
-		c.Next()
 	}
+	if p.actor != nil {
+		c.Set(ActorKey, p.actor)
+	}
+	c.Set(ClaimsKey, p.customClaims)
+	c.Request = c.Request.WithContext(withPrincipal(c.Request.Context(), p))
+}
+
+func (mw *Middleware) fail(c *gin.Context, code int, err error, userId string, sessionId string) {
+	mw.settings.Hooks.call(mw.settings.Hooks.OnAuthFailure, c, userId, sessionId, err)
+	mw.settings.metrics.observeRejection(code, err)
+	mw.settings.ErrResponseFunc(c, code, err.Error())
+}
+
+// LoadUser returns the user authenticated by auth middleware. With LazyUserLoading the user is loaded
+// with GetUserFunc on first call and kept in the context for the rest of the request.
+func LoadUser(c *gin.Context) (interface{}, error) {
+	if user, exists := c.Get(UserKey); exists {
+		return user, nil
+	}
+	if c.Request == nil {
+		return nil, ErrNotAuthUser
+	}
+	user, err := UserFromContext(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	c.Set(UserKey, user)
+	return user, nil
 }
@@ -52,7 +52,7 @@ func testAuthMiddlewareInit(hasAccessTokenErr string, accessToken string,
 
 func TestAdditionalAuthTokenSuccess(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testAuthMiddlewareInit("", accessData.token,
 		false, false, true)
 
@@ -61,7 +61,7 @@ func TestAdditionalAuthTokenSuccess(t *testing.T) {
 
 func TestAuthMiddlewareSuccess(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testAuthMiddlewareInit("", accessData.token,
 		true, false, false)
 
@@ -70,7 +70,7 @@ func TestAuthMiddlewareSuccess(t *testing.T) {
 
 func TestNoAuthHeaderError(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testAuthMiddlewareInit("", accessData.token,
 		false, false, false)
 
@@ -79,7 +79,7 @@ func TestNoAuthHeaderError(t *testing.T) {
 
 func TestParseTokenError(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testAuthMiddlewareInit("", accessData.token+"wrong",
 		true, false, false)
 
@@ -88,7 +88,7 @@ func TestParseTokenError(t *testing.T) {
 
 func TestHasAccessTokenError(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testAuthMiddlewareInit("no token", accessData.token,
 		true, false, false)
 
@@ -99,7 +99,7 @@ func TestAccessTokenExpiredError(t *testing.T) {
 	tService := tokenService{}
 	settings := getSettingsFixture()
 	settings.AccessLifetime = time.Nanosecond
-	accessData, _ := tService._createAccessToken(settings, "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testAuthMiddlewareInit("", accessData.token,
 		true, false, false)
 
@@ -108,9 +108,95 @@ func TestAccessTokenExpiredError(t *testing.T) {
 
 func TestGetUserError(t *testing.T) {
 	tService := tokenService{}
-	accessData, _ := tService._createAccessToken(getSettingsFixture(), "1", "access", "refresh")
+	accessData, _ := tService._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
 	rr := testAuthMiddlewareInit("", accessData.token,
 		true, true, false)
 
 	assert.Equal(t, http.StatusInternalServerError, rr.Code)
 }
+
+func TestAuthMiddlewareSetsClaims(t *testing.T) {
+	settings := getSettingsFixture()
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	accessData, _ := (&tokenService{})._createAccessToken(settings,
+		&tokenParams{userId: "1", claims: map[string]interface{}{"tenant": "acme"}}, "access", "refresh")
+	mw := &Middleware{settings: settings}
+
+	var claims interface{}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(mw.GetAuthMiddleware()).GET("/test-auth", func(c *gin.Context) {
+		claims, _ = c.Get(ClaimsKey)
+	})
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, claims)
+}
+
+func testLazyUserLoadingInit(handler func(c *gin.Context)) (*httptest.ResponseRecorder, *int) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	settings.LazyUserLoading = true
+	calls := 0
+	settings.GetUserFunc = func(userId string) (interface{}, error) {
+		calls++
+		return "user" + userId, nil
+	}
+	mw := &Middleware{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.Use(mw.GetAuthMiddleware()).GET("/test-auth", handler)
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	router.ServeHTTP(rr, request)
+
+	return rr, &calls
+}
+
+func TestLazyUserLoadingSkipsGetUser(t *testing.T) {
+	var userId string
+	var userExists bool
+	rr, calls := testLazyUserLoadingInit(func(c *gin.Context) {
+		userId = c.GetString(UserIdKey)
+		_, userExists = c.Get(UserKey)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", userId)
+	assert.False(t, userExists)
+	assert.Equal(t, 0, *calls)
+}
+
+func TestLoadUserMemoized(t *testing.T) {
+	var first, second interface{}
+	var firstErr, secondErr error
+	rr, calls := testLazyUserLoadingInit(func(c *gin.Context) {
+		first, firstErr = LoadUser(c)
+		second, secondErr = LoadUser(c)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, firstErr)
+	assert.Nil(t, secondErr)
+	assert.Equal(t, "user1", first)
+	assert.Equal(t, "user1", second)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestLoadUserNotAuthenticated(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, err := LoadUser(c)
+
+	assert.Equal(t, ErrNotAuthUser, err)
+}
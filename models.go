@@ -2,6 +2,7 @@ package gwt
 
 import (
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"time"
 )
 
@@ -11,6 +12,7 @@ type refreshTokenData struct {
 	uuid       string
 	expire     int64
 	accessUuid string
+	sessionId  string
 }
 
 type accessTokenData struct {
@@ -19,6 +21,86 @@ type accessTokenData struct {
 	uuid        string
 	expire      int64
 	refreshUuid string
+	sessionId   string
+}
+
+// AuthResult is returned by LoginAuthenticator
+type AuthResult struct {
+	UserId string
+
+	// Claims are added to access and refresh tokens and kept across rotations,
+	// claims used by the package are ignored. Auth middleware sets them to gin context by ClaimsKey.
+	Claims map[string]interface{}
+
+	// Metadata is saved with the session by storages implementing SessionStorageInterface
+	Metadata map[string]string
+
+	// AccessLifetime overrides Settings.AccessLifetime for this login, e.g. for kiosk logins. Optional.
+	AccessLifetime time.Duration
+
+	// RefreshLifetime overrides Settings.RefreshLifetime for this login, e.g. for "remember me". Optional.
+	RefreshLifetime time.Duration
+}
+
+// Session is a login of the user, it is kept across token rotations
+// by storages implementing SessionStorageInterface
+type Session struct {
+	Id          string `json:"id"`
+	UserId      string `json:"user_id"`
+	AccessUuid  string `json:"access_uuid"`
+	RefreshUuid string `json:"refresh_uuid"`
+
+	// CreatedAt is unix time of the login
+	CreatedAt int64 `json:"created_at"`
+
+	// ExpiresAt is unix time the session can not be refreshed after, zero if session lifetime is unlimited
+	ExpiresAt int64 `json:"expires_at"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ForceLogoutResult is returned by force logout handler
+type ForceLogoutResult struct {
+	UserId string `json:"user_id"`
+
+	// RemovedSessions is the number of removed sessions, -1 if storage does not implement SessionRemovalStorageInterface
+	RemovedSessions int64 `json:"removed_sessions"`
+}
+
+// LogoutAllResult is returned by logout all handler
+type LogoutAllResult struct {
+	// RemovedSessions is the number of removed sessions, -1 if storage does not implement SessionRemovalStorageInterface
+	RemovedSessions int64 `json:"removed_sessions"`
+}
+
+// Impersonation is a session of the admin logged in as another user
+type Impersonation struct {
+	// Id is the id of the impersonation session
+	Id          string `json:"id"`
+	ActorId     string `json:"actor_id"`
+	UserId      string `json:"user_id"`
+	Reason      string `json:"reason"`
+	AccessUuid  string `json:"-"`
+	RefreshUuid string `json:"-"`
+	CreatedAt   int64  `json:"created_at"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// Tokens are issued by Service.IssueTokens
+type Tokens struct {
+	AccessToken   string
+	AccessExpire  int64
+	RefreshToken  string
+	RefreshExpire int64
+}
+
+// ImpersonationTokens are tokens issued by Service.Impersonate, refresh token can only be used to log out
+type ImpersonationTokens struct {
+	AccessToken   string
+	AccessExpire  int64
+	RefreshToken  string
+	RefreshExpire int64
+	Impersonation *Impersonation
 }
 
 type Settings struct {
@@ -31,27 +113,92 @@ type Settings struct {
 	AccessSecretKey []byte
 
 	// RefreshSecretKey used for signing. Optional, AccessSecretKey is used by default.
+	// Secret keys must be at least as long as the hash: 32 bytes for HS256, 48 for HS384 and 64 for HS512.
 	RefreshSecretKey []byte
 
+	// TokenFormat is the format of issued tokens, TokenFormatOpaque requires storage implementing
+	// OpaqueTokenStorageInterface. Optional, TokenFormatJWT by default.
+	TokenFormat TokenFormat
+
+	// TokenEncryptionKey encrypts signed tokens into JWE compact serialization with alg dir,
+	// so claims are not readable by clients. Key length chooses content encryption: 16 bytes for A128GCM,
+	// 24 for A192GCM and 32 for A256GCM. Optional, tokens are only signed by default. Opaque tokens are not encrypted.
+	TokenEncryptionKey []byte
+
+	// SecretReuse tells Init what to do when RefreshSecretKey equals AccessSecretKey.
+	// Optional, a warning is logged by default.
+	SecretReuse SecretReusePolicy
+
 	// AccessLifetime is a duration that an access token is valid. Optional, ten minutes by defaults.
 	AccessLifetime time.Duration
 
 	// RefreshLifetime is a duration that a refresh token is valid. Optional, one day by defaults.
 	RefreshLifetime time.Duration
 
+	// SessionMaxLifetime is an absolute duration of the session since login, tokens are not refreshed after it.
+	// Optional, session lifetime is unlimited by default.
+	SessionMaxLifetime time.Duration
+
+	// DisableSlidingRefresh makes refresh handler keep expiration of the rotated refresh token,
+	// so session expires RefreshLifetime after login. By default, refresh token expiration is extended
+	// by RefreshLifetime on every refresh.
+	DisableSlidingRefresh bool
+
 	// AdditionalAuthHeader is the header that will be used with default Authorization header
 	// data from this header will be copied into default
 	// this feature can be used to avoid Safari bug
 	// when safari gets 3xx response, Authentication header will be broken in next request
+	// It must be a valid header name other than Authorization.
 	AdditionalAuthHeader string
 
 	// AuthHeadName is a string in the header. Default value is "Bearer"
+	// It must be an http token, e.g. without spaces.
 	AuthHeadName string
 
 	// Callback function that should perform the authentication of the user based on login info.
-	// Must return user id as string. Required.
+	// Must return user id as string. Required by gin login handler if LoginAuthenticator is not set.
+	// Return user id with ErrSecondFactorRequired to issue mfa token instead of token pair,
+	// tokens are issued by MFA verify handler after second factor is checked.
 	Authenticator func(c *gin.Context) (string, error)
 
+	// LoginAuthenticator is an alternative to Authenticator returning extra claims, session metadata
+	// and per-login token lifetimes. It is used instead of Authenticator if set.
+	// Return the result with ErrSecondFactorRequired to require second factor.
+	LoginAuthenticator func(c *gin.Context) (*AuthResult, error)
+
+	// RequestAuthenticator is LoginAuthenticator of net/http login handler, required by it.
+	// Return the result with ErrSecondFactorRequired to require second factor.
+	RequestAuthenticator func(r *http.Request) (*AuthResult, error)
+
+	// ClientAuthenticator authenticates the client calling introspection handler, e.g. by basic auth
+	// credentials of the api gateway. Required by introspection handler, other handlers don't use it.
+	ClientAuthenticator func(c *gin.Context) error
+
+	// RevocationClientAuthenticator authenticates the client calling revocation handler.
+	// Optional, public clients can revoke their tokens if it is not set.
+	RevocationClientAuthenticator func(c *gin.Context) error
+
+	// TokenClientAuthenticator authenticates the client calling password and refresh_token grants of the token endpoint.
+	// Optional, public clients can use the grants if it is not set.
+	TokenClientAuthenticator func(c *gin.Context) error
+
+	// Clients is a registry of confidential clients allowed to use client credentials grant of the token endpoint.
+	// Optional, client credentials grant is disabled if it is not set.
+	Clients ClientRegistryInterface
+
+	// LazyUserLoading makes auth middleware only validate the token and store user id under UserIdKey,
+	// the user is loaded by LoadUser on first access within the request. Optional, disabled by default.
+	LazyUserLoading bool
+
+	// UserCache caches users returned by GetUserFunc, e.g. NewLRUUserCache(10000, time.Minute).
+	// Cached user is invalidated on force logout and by Service.InvalidateUser. Optional, disabled by default.
+	UserCache UserCacheInterface
+
+	// ForceLogoutAuthorizer checks that the authenticated requester may log out the target user.
+	// Requester is the user returned by GetUserFunc, or *ClientPrincipal for client tokens.
+	// Required by force logout handler, return an error to reject the request with 403.
+	ForceLogoutAuthorizer func(c *gin.Context, requesterUser interface{}, targetUserId string) error
+
 	// GetUserFunc is function than returns application user model
 	GetUserFunc func(userId string) (interface{}, error)
 
@@ -65,6 +212,28 @@ type Settings struct {
 	// LoginResponseFunc is function that returns data after an error has been happened
 	ErrResponseFunc func(c *gin.Context, code int, message string)
 
+	// MFARequiredResponseFunc is function that returns mfa token when second factor is required
+	MFARequiredResponseFunc func(c *gin.Context, code int, mfaToken string, mfaExpire int64)
+
+	// MFATokenLifetime is a duration that mfa token is valid. Optional, five minutes by default.
+	MFATokenLifetime time.Duration
+
+	// ImpersonationLifetime is the maximum lifetime of impersonation tokens. Optional, one hour by default.
+	ImpersonationLifetime time.Duration
+
 	// Storage is struct than stores auth data
 	Storage StorageInterface
+
+	// Hooks are optional callbacks called on login, refresh, logout and authentication failures
+	Hooks Hooks
+
+	// LoginThrottle limits failed login attempts, storage must implement LoginAttemptStorageInterface.
+	// Optional, disabled by default.
+	LoginThrottle LoginThrottle
+
+	// Clock returns current time for token creation and expiry checks, it is also set to storages
+	// implementing ClockStorageInterface. Optional, system clock by default.
+	Clock Clock
+
+	metrics *metrics
 }
@@ -0,0 +1,237 @@
+package gwt
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// GetHTTPAuthMiddleware returns net/http auth middleware, e.g. for chi router. The principal of the token is stored
+// in the request context, use UserFromContext, UserIdFromContext and ClaimsFromContext to get it.
+// Errors are responded with DefaultErrResponse.
+func (mw *Middleware) GetHTTPAuthMiddleware() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authenticated, authErr := authenticateRequest(mw.settings, r, !mw.settings.LazyUserLoading)
+			if authErr != nil {
+				mw.settings.Hooks.call(mw.settings.Hooks.OnAuthFailure, nil, authErr.userId, authErr.sessionId, authErr.err)
+				mw.settings.metrics.observeRejection(authErr.code, authErr.err)
+				writeHTTPError(w, authErr.code, authErr.err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), authenticated)))
+		})
+	}
+}
+
+// GetHTTPLoginHandler returns net/http login handler, Settings.RequestAuthenticator is required.
+// Tokens are responded with DefaultLoginResponse.
+func (handler *Handler) GetHTTPLoginHandler() http.Handler {
+	return handler.instrumentHTTP("login", handler.httpLoginHandler)
+}
+
+// GetHTTPMFAVerifyHandler returns net/http handler that checks second factor code of the user authenticated
+// with mfa token and issues tokens, mfa token and code are read from json or form body
+func (handler *Handler) GetHTTPMFAVerifyHandler() http.Handler {
+	return handler.instrumentHTTP("mfa_verify", handler.httpMFAVerifyHandler)
+}
+
+// GetHTTPRefreshHandler returns net/http refresh handler, refresh token is read from json or form body
+func (handler *Handler) GetHTTPRefreshHandler() http.Handler {
+	return handler.instrumentHTTP("refresh", handler.httpRefreshHandler)
+}
+
+// GetHTTPLogoutHandler returns net/http logout handler
+func (handler *Handler) GetHTTPLogoutHandler() http.Handler {
+	return handler.instrumentHTTP("logout", handler.httpLogoutHandler)
+}
+
+// httpLoginRequest adapts net/http login request to the login flow
+func (handler *Handler) httpLoginRequest(w http.ResponseWriter, r *http.Request) *loginRequest {
+	settings := handler.settings
+	req := &loginRequest{clientIP: remoteIP(r), response: w.Header()}
+	if authenticator := settings.RequestAuthenticator; authenticator != nil {
+		req.authenticate = func() (*AuthResult, error) {
+			return authenticator(r)
+		}
+	}
+	if identifierFunc := settings.LoginThrottle.RequestIdentifierFunc; identifierFunc != nil {
+		req.identifier = func() string {
+			return identifierFunc(r)
+		}
+	}
+	if captchaFunc := settings.LoginThrottle.RequestCaptchaFunc; captchaFunc != nil {
+		req.captcha = func() error {
+			return captchaFunc(r)
+		}
+	}
+	return req
+}
+
+func (handler *Handler) httpLoginHandler(w http.ResponseWriter, r *http.Request) {
+	hooks := &handler.settings.Hooks
+	result, mfaRequired, loginErr := handler.login(handler.httpLoginRequest(w, r))
+	if loginErr != nil {
+		handler.failHTTP(w, hooks.OnLoginFailure, loginErr.code, loginErr.err, loginErr.userId, "")
+		return
+	}
+	if mfaRequired {
+		mfaToken, mfaExpire, mfaErr := (&tokenService{})._createMFAToken(handler.settings, newTokenParams(result))
+		if mfaErr != nil {
+			handler.failHTTP(w, hooks.OnLoginFailure, http.StatusInternalServerError, mfaErr, result.UserId, "")
+			return
+		}
+		writeJSON(w, http.StatusAccepted, DefaultMFAResponse{MFAToken: mfaToken, MFAExpire: mfaExpire})
+		return
+	}
+	handler.issueHTTPTokens(w, newTokenParams(result))
+}
+
+func (handler *Handler) httpMFAVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	hooks := &handler.settings.Hooks
+	requestData := readMFAVerifyRequest(r)
+	if requestData.MFAToken == "" || requestData.Code == "" {
+		handler.failHTTP(w, hooks.OnLoginFailure, http.StatusBadRequest, ErrMFATokenIsNotProvided, "", "")
+		return
+	}
+	params, verifyErr := handler.verifyMFA(handler.httpLoginRequest(w, r), requestData)
+	if verifyErr != nil {
+		handler.failHTTP(w, hooks.OnLoginFailure, verifyErr.code, verifyErr.err, verifyErr.userId, "")
+		return
+	}
+	handler.issueHTTPTokens(w, params)
+}
+
+func (handler *Handler) issueHTTPTokens(w http.ResponseWriter, params *tokenParams) {
+	hooks := &handler.settings.Hooks
+	accessData, refreshData, err := saveTokens(handler.settings, params)
+	if err != nil {
+		sessionId := ""
+		if accessData != nil {
+			sessionId = accessData.sessionId
+		}
+		handler.failHTTP(w, hooks.OnLoginFailure, http.StatusInternalServerError, err, params.userId, sessionId)
+		return
+	}
+
+	hooks.call(hooks.OnLoginSuccess, nil, params.userId, accessData.sessionId, nil)
+	writeLoginResponse(w, accessData, refreshData)
+}
+
+func (handler *Handler) httpRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	hooks := &handler.settings.Hooks
+	refreshToken := readRefreshToken(r)
+	if refreshToken == "" {
+		handler.failHTTP(w, hooks.OnRefreshFailure, http.StatusBadRequest, ErrRefreshTokenIsNotProvided, "", "")
+		return
+	}
+	claims, params, checkErr := handler.checkRefreshToken(refreshToken)
+	if checkErr != nil {
+		handler.failHTTP(w, hooks.OnRefreshFailure, checkErr.code, checkErr.err, checkErr.userId, checkErr.sessionId)
+		return
+	}
+	accessData, refreshData, rotateErr := handler.rotateTokens(claims, params)
+	if rotateErr != nil {
+		handler.failHTTP(w, hooks.OnRefreshFailure, rotateErr.code, rotateErr.err, rotateErr.userId, rotateErr.sessionId)
+		return
+	}
+
+	hooks.call(hooks.OnRefresh, nil, params.userId, accessData.sessionId, nil)
+	writeLoginResponse(w, accessData, refreshData)
+}
+
+func (handler *Handler) httpLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	hooks := &handler.settings.Hooks
+	authenticated, authErr := authenticateRequest(handler.settings, r, false)
+	if authErr != nil {
+		handler.failHTTP(w, hooks.OnAuthFailure, authErr.code, authErr.err, authErr.userId, authErr.sessionId)
+		return
+	}
+	if deleteErr := handler.deleteTokens(authenticated.claims); deleteErr != nil {
+		writeHTTPError(w, http.StatusInternalServerError, deleteErr)
+		return
+	}
+
+	hooks.call(hooks.OnLogout, nil, authenticated.userId, authenticated.sessionId(), nil)
+	writeJSON(w, http.StatusOK, DefaultLogoutResponse{})
+}
+
+// readRefreshToken reads refresh token from json body, or from the form for other content types
+func readRefreshToken(r *http.Request) string {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		requestData := RefreshRequestData{}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			return ""
+		}
+		return requestData.RefreshToken
+	}
+	return r.FormValue(refreshTokenType)
+}
+
+// readMFAVerifyRequest reads mfa token and code from json body, or from the form for other content types
+func readMFAVerifyRequest(r *http.Request) *MFAVerifyRequestData {
+	requestData := &MFAVerifyRequestData{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(requestData); err != nil {
+			return &MFAVerifyRequestData{}
+		}
+		return requestData
+	}
+	requestData.MFAToken = r.FormValue("mfa_token")
+	requestData.Code = r.FormValue("code")
+	return requestData
+}
+
+// remoteIP returns ip of the client connection, proxy headers are not trusted
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (handler *Handler) failHTTP(w http.ResponseWriter, hook HookFunc, code int, err error, userId string, sessionId string) {
+	handler.settings.Hooks.call(hook, nil, userId, sessionId, err)
+	writeHTTPError(w, code, err)
+}
+
+func writeLoginResponse(w http.ResponseWriter, accessData *accessTokenData, refreshData *refreshTokenData) {
+	writeJSON(w, http.StatusOK, DefaultLoginResponse{
+		AccessToken:   accessData.token,
+		RefreshToken:  refreshData.token,
+		AccessExpire:  accessData.expire,
+		RefreshExpire: refreshData.expire,
+	})
+}
+
+func writeHTTPError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, DefaultErrResponse{ErrorCode: code, ErrorMessage: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// statusRecorder keeps response status for metrics
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.status = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// instrumentHTTP counts results of net/http handler by response status
+func (handler *Handler) instrumentHTTP(name string, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(recorder, r)
+		handler.settings.metrics.observeRequest(name, recorder.status)
+	})
+}
@@ -0,0 +1,282 @@
+package gwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func testHTTPAuthMiddlewareInit(settings *Settings, header string,
+	next func(w http.ResponseWriter, r *http.Request)) *httptest.ResponseRecorder {
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	mw := &Middleware{settings: settings}
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	if header != "" {
+		request.Header.Add("Authorization", header)
+	}
+	mw.GetHTTPAuthMiddleware()(http.HandlerFunc(next)).ServeHTTP(rr, request)
+
+	return rr
+}
+
+func TestHTTPAuthMiddlewareSuccess(t *testing.T) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings,
+		&tokenParams{userId: "1", claims: map[string]interface{}{"tenant": "acme"}}, "access", "refresh")
+
+	var user interface{}
+	var userId string
+	var claims map[string]interface{}
+	rr := testHTTPAuthMiddlewareInit(settings, "Bearer "+accessData.token, func(w http.ResponseWriter, r *http.Request) {
+		user, _ = UserFromContext(r.Context())
+		userId, _ = UserIdFromContext(r.Context())
+		claims = ClaimsFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", user)
+	assert.Equal(t, "1", userId)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, claims)
+}
+
+func TestHTTPAuthMiddlewareNoHeaderError(t *testing.T) {
+	settings := getSettingsFixture()
+	var hookErr error
+	settings.Hooks.OnAuthFailure = func(c *gin.Context, userId string, sessionId string, err error) {
+		hookErr = err
+	}
+	nextCalled := false
+	rr := testHTTPAuthMiddlewareInit(settings, "", func(w http.ResponseWriter, r *http.Request) {
+		nextCalled = true
+	})
+
+	var res DefaultErrResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, DefaultErrResponse{ErrorCode: http.StatusUnauthorized, ErrorMessage: ErrNoAuthHeader.Error()}, res)
+	assert.Equal(t, ErrNoAuthHeader, hookErr)
+	assert.False(t, nextCalled)
+}
+
+func TestHTTPAuthMiddlewareLazyUserLoading(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.LazyUserLoading = true
+	calls := 0
+	settings.GetUserFunc = func(userId string) (interface{}, error) {
+		calls++
+		return "user" + userId, nil
+	}
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+
+	var first, second interface{}
+	rr := testHTTPAuthMiddlewareInit(settings, "Bearer "+accessData.token, func(w http.ResponseWriter, r *http.Request) {
+		first, _ = UserFromContext(r.Context())
+		second, _ = UserFromContext(r.Context())
+	})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "user1", first)
+	assert.Equal(t, "user1", second)
+	assert.Equal(t, 1, calls)
+}
+
+func TestUserFromContextNotAuthenticated(t *testing.T) {
+	_, err := UserFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+	_, isUser := UserIdFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+
+	assert.Equal(t, ErrNotAuthUser, err)
+	assert.False(t, isUser)
+}
+
+func TestGinAuthMiddlewareSetsRequestContext(t *testing.T) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	mw := &Middleware{settings: settings}
+
+	var user interface{}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(mw.GetAuthMiddleware()).GET("/test-auth", func(c *gin.Context) {
+		user, _ = UserFromContext(c.Request.Context())
+	})
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	assert.Equal(t, "1", user)
+}
+
+func testHTTPLoginInit(settings *Settings) *httptest.ResponseRecorder {
+	strgMock := new(storageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	handler := &Handler{settings: settings}
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodPost, "/login", nil)
+	handler.GetHTTPLoginHandler().ServeHTTP(rr, request)
+
+	return rr
+}
+
+func TestHTTPLoginSuccess(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.RequestAuthenticator = func(r *http.Request) (*AuthResult, error) {
+		return &AuthResult{UserId: "1"}, nil
+	}
+	rr := testHTTPLoginInit(settings)
+
+	var res DefaultLoginResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, res.AccessToken)
+	assert.NotEmpty(t, res.RefreshToken)
+}
+
+func TestHTTPLoginErrors(t *testing.T) {
+	failing := getSettingsFixture()
+	failing.RequestAuthenticator = func(r *http.Request) (*AuthResult, error) {
+		return nil, errors.New("invalid credentials")
+	}
+	empty := getSettingsFixture()
+	empty.RequestAuthenticator = func(r *http.Request) (*AuthResult, error) {
+		return nil, nil
+	}
+	cases := []struct {
+		settings *Settings
+		code     int
+		message  string
+	}{
+		{failing, http.StatusUnauthorized, "invalid credentials"},
+		{empty, http.StatusInternalServerError, ErrUserIdIsNotProvided.Error()},
+		{getSettingsFixture(), http.StatusInternalServerError, ErrEmptyAuthenticator.Error()},
+	}
+	for _, testCase := range cases {
+		rr := testHTTPLoginInit(testCase.settings)
+		var res DefaultErrResponse
+		_ = json.NewDecoder(rr.Body).Decode(&res)
+
+		assert.Equal(t, testCase.code, rr.Code)
+		assert.Equal(t, testCase.message, res.ErrorMessage)
+	}
+}
+
+func TestHTTPRefresh(t *testing.T) {
+	settings := getSettingsFixture()
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	jsonBody, _ := json.Marshal(RefreshRequestData{RefreshToken: refreshData.token})
+	cases := []struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		{"application/json", string(jsonBody), http.StatusOK},
+		{"application/x-www-form-urlencoded", url.Values{"refresh_token": {refreshData.token}}.Encode(), http.StatusOK},
+		{"application/json", "{}", http.StatusBadRequest},
+	}
+	for _, testCase := range cases {
+		strgMock := new(storageMock)
+		strgMock.On("HasRefreshToken", mock.Anything).Return(nil)
+		strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+		strgMock.On("SaveTokens", mock.Anything).Return(nil)
+		settings.Storage = strgMock
+		handler := &Handler{settings: settings}
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/refresh", strings.NewReader(testCase.body))
+		request.Header.Add("Content-Type", testCase.contentType)
+		handler.GetHTTPRefreshHandler().ServeHTTP(rr, request)
+
+		assert.Equal(t, testCase.code, rr.Code)
+	}
+}
+
+func TestHTTPMFAVerify(t *testing.T) {
+	settings := getMFASettingsFixture(newMFAStorageMock(nil))
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	jsonBody, _ := json.Marshal(MFAVerifyRequestData{MFAToken: mfaToken, Code: "recoverycode"})
+	cases := []struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		{"application/json", string(jsonBody), http.StatusOK},
+		{"application/x-www-form-urlencoded", url.Values{"mfa_token": {mfaToken}, "code": {"recoverycode"}}.Encode(),
+			http.StatusOK},
+		{"application/json", "{}", http.StatusBadRequest},
+		{"application/x-www-form-urlencoded", url.Values{"mfa_token": {"token"}, "code": {"code"}}.Encode(),
+			http.StatusUnauthorized},
+	}
+	for _, testCase := range cases {
+		settings.Storage = newMFAStorageMock(nil)
+		handler := &Handler{settings: settings}
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/mfa", strings.NewReader(testCase.body))
+		request.Header.Add("Content-Type", testCase.contentType)
+		handler.GetHTTPMFAVerifyHandler().ServeHTTP(rr, request)
+
+		assert.Equal(t, testCase.code, rr.Code)
+	}
+}
+
+func TestHTTPMFAVerifyInvalidCodeError(t *testing.T) {
+	strgMock := newMFAStorageMock(ErrInvalidMFACode)
+	settings := getMFASettingsFixture(strgMock)
+	mfaToken, _, _ := (&tokenService{})._createMFAToken(settings, &tokenParams{userId: "1"})
+	handler := &Handler{settings: settings}
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodPost, "/mfa",
+		strings.NewReader(url.Values{"mfa_token": {mfaToken}, "code": {"000000"}}.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	handler.GetHTTPMFAVerifyHandler().ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrInvalidMFACode.Error())
+	strgMock.AssertCalled(t, "AddLoginAttempt")
+	strgMock.AssertNotCalled(t, "SaveTokens")
+}
+
+func TestHTTPLogout(t *testing.T) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	handler := &Handler{settings: settings}
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodPost, "/logout", bytes.NewBuffer(nil))
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	handler.GetHTTPLogoutHandler().ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	strgMock.AssertCalled(t, "DeleteTokens")
+}
+
+func TestRemoteIP(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = "10.0.0.1:1234"
+
+	assert.Equal(t, "10.0.0.1", remoteIP(request))
+}
@@ -0,0 +1,184 @@
+package gwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+var (
+	scopeClaim            = "scope"
+	passwordGrantType     = "password"
+	refreshTokenGrantType = "refresh_token"
+)
+
+// RFC 6749 error codes of the token endpoint
+var (
+	oauth2InvalidRequest       = "invalid_request"
+	oauth2InvalidClient        = "invalid_client"
+	oauth2InvalidGrant         = "invalid_grant"
+	oauth2InvalidScope         = "invalid_scope"
+	oauth2UnsupportedGrantType = "unsupported_grant_type"
+	oauth2ServerError          = "server_error"
+)
+
+// OAuth2TokenResponse is RFC 6749 access token response
+type OAuth2TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuth2ErrorResponse is RFC 6749 error response
+type OAuth2ErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// tokenEndpointHandler dispatches password, refresh_token and client_credentials grants,
+// request parameters are form encoded. Clients of client_credentials grant are authenticated by Settings.Clients.
+func (handler *Handler) tokenEndpointHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	grantType := c.PostForm("grant_type")
+	if handler.settings.TokenClientAuthenticator != nil && grantType != clientCredentialsGrantType {
+		if err := handler.settings.TokenClientAuthenticator(c); err != nil {
+			handler.oauth2Error(c, http.StatusUnauthorized, oauth2InvalidClient, err.Error())
+			return
+		}
+	}
+	switch grantType {
+	case passwordGrantType:
+		handler.passwordGrant(c)
+	case refreshTokenGrantType:
+		handler.refreshTokenGrant(c)
+	case clientCredentialsGrantType:
+		handler.clientCredentialsGrant(c)
+	case "":
+		handler.oauth2Error(c, http.StatusBadRequest, oauth2InvalidRequest, "grant_type is not provided")
+	default:
+		handler.oauth2Error(c, http.StatusBadRequest, oauth2UnsupportedGrantType, "unsupported grant type "+grantType)
+	}
+}
+
+// passwordGrant authenticates the user with Authenticator or LoginAuthenticator,
+// requested scope is kept in the tokens unless the authenticator has set its own scope claim
+func (handler *Handler) passwordGrant(c *gin.Context) {
+	hooks := &handler.settings.Hooks
+	result, mfaRequired, loginErr := handler.login(handler.ginLoginRequest(c))
+	if loginErr != nil {
+		hooks.call(hooks.OnLoginFailure, c, loginErr.userId, "", loginErr.err)
+		handler.tokenErrorResponse(c, loginErr)
+		return
+	}
+	if mfaRequired {
+		hooks.call(hooks.OnLoginFailure, c, result.UserId, "", ErrSecondFactorRequired)
+		handler.oauth2Error(c, http.StatusBadRequest, oauth2InvalidGrant, ErrSecondFactorRequired.Error())
+		return
+	}
+	params := newTokenParams(result)
+	if scope := c.PostForm(scopeClaim); scope != "" {
+		if _, ok := params.claims[scopeClaim]; !ok {
+			claims := map[string]interface{}{scopeClaim: scope}
+			for name, value := range params.claims {
+				claims[name] = value
+			}
+			params.claims = claims
+		}
+	}
+	accessData, refreshData, err := saveTokens(handler.settings, params)
+	if err != nil {
+		hooks.call(hooks.OnLoginFailure, c, params.userId, "", err)
+		handler.oauth2Error(c, http.StatusInternalServerError, oauth2ServerError, err.Error())
+		return
+	}
+
+	hooks.call(hooks.OnLoginSuccess, c, params.userId, accessData.sessionId, nil)
+	handler.oauth2TokenResponse(c, params, accessData, refreshData)
+}
+
+// refreshTokenGrant rotates the token pair, requested scope must not exceed the originally granted one
+func (handler *Handler) refreshTokenGrant(c *gin.Context) {
+	hooks := &handler.settings.Hooks
+	refreshToken := c.PostForm(refreshTokenType)
+	if refreshToken == "" {
+		hooks.call(hooks.OnRefreshFailure, c, "", "", ErrRefreshTokenIsNotProvided)
+		handler.oauth2Error(c, http.StatusBadRequest, oauth2InvalidRequest, ErrRefreshTokenIsNotProvided.Error())
+		return
+	}
+	claims, params, checkErr := handler.checkRefreshToken(refreshToken)
+	if checkErr != nil {
+		hooks.call(hooks.OnRefreshFailure, c, checkErr.userId, checkErr.sessionId, checkErr.err)
+		handler.tokenErrorResponse(c, checkErr)
+		return
+	}
+	if scope := c.PostForm(scopeClaim); scope != "" {
+		granted, _ := params.claims[scopeClaim].(string)
+		if !isSubScope(scope, granted) {
+			hooks.call(hooks.OnRefreshFailure, c, params.userId, params.sessionId, ErrInvalidScope)
+			handler.oauth2Error(c, http.StatusBadRequest, oauth2InvalidScope, ErrInvalidScope.Error())
+			return
+		}
+		params.claims[scopeClaim] = scope
+	}
+	accessData, refreshData, rotateErr := handler.rotateTokens(claims, params)
+	if rotateErr != nil {
+		hooks.call(hooks.OnRefreshFailure, c, rotateErr.userId, rotateErr.sessionId, rotateErr.err)
+		handler.tokenErrorResponse(c, rotateErr)
+		return
+	}
+
+	hooks.call(hooks.OnRefresh, c, params.userId, accessData.sessionId, nil)
+	handler.oauth2TokenResponse(c, params, accessData, refreshData)
+}
+
+func (handler *Handler) oauth2TokenResponse(c *gin.Context, params *tokenParams,
+	accessData *accessTokenData, refreshData *refreshTokenData) {
+	scope, _ := params.claims[scopeClaim].(string)
+	response := OAuth2TokenResponse{
+		AccessToken: accessData.token,
+		TokenType:   handler.settings.AuthHeadName,
+		ExpiresIn:   accessData.expire - handler.settings.now().Unix(),
+		Scope:       scope,
+	}
+	if refreshData != nil {
+		response.RefreshToken = refreshData.token
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// tokenErrorResponse maps login and refresh errors to RFC 6749 errors, rejected credentials
+// and tokens are reported as invalid_grant, Retry-After header of the login throttle is kept
+func (handler *Handler) tokenErrorResponse(c *gin.Context, tokenErr *tokenError) {
+	switch {
+	case tokenErr.code >= http.StatusInternalServerError:
+		handler.oauth2Error(c, tokenErr.code, oauth2ServerError, tokenErr.err.Error())
+	case tokenErr.code == http.StatusTooManyRequests:
+		handler.oauth2Error(c, tokenErr.code, oauth2InvalidGrant, tokenErr.err.Error())
+	default:
+		handler.oauth2Error(c, http.StatusBadRequest, oauth2InvalidGrant, tokenErr.err.Error())
+	}
+}
+
+func (handler *Handler) oauth2Error(c *gin.Context, code int, errCode string, description string) {
+	if code == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", "Basic")
+	}
+	c.AbortWithStatusJSON(code, OAuth2ErrorResponse{Error: errCode, ErrorDescription: description})
+}
+
+// isSubScope checks that every space separated scope token of requested is present in granted
+func isSubScope(requested string, granted string) bool {
+	grantedScopes := map[string]bool{}
+	for _, scope := range strings.Fields(granted) {
+		grantedScopes[scope] = true
+	}
+	for _, scope := range strings.Fields(requested) {
+		if !grantedScopes[scope] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,208 @@
+package gwt
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func testTokenEndpointInit(settings *Settings, strgMock *storageMock, form url.Values) *httptest.ResponseRecorder {
+	settings.Storage = strgMock
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/token", handler.GetTokenEndpointHandler())
+	request, _ := http.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(rr, request)
+
+	return rr
+}
+
+func getPasswordGrantSettings() *Settings {
+	settings := getSettingsFixture()
+	settings.Authenticator = func(c *gin.Context) (string, error) {
+		if c.PostForm("username") == "user" && c.PostForm("password") == "pass" {
+			return "1", nil
+		}
+		return "", errors.New("invalid credentials")
+	}
+	return settings
+}
+
+func TestTokenEndpointPasswordGrant(t *testing.T) {
+	strgMock := new(storageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	rr := testTokenEndpointInit(getPasswordGrantSettings(), strgMock, url.Values{"grant_type": {"password"},
+		"username": {"user"}, "password": {"pass"}, "scope": {"read write"}})
+
+	var res OAuth2TokenResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	assert.Equal(t, "Bearer", res.TokenType)
+	assert.Equal(t, "read write", res.Scope)
+	assert.InDelta(t, getSettingsFixture().AccessLifetime.Seconds(), res.ExpiresIn, 1)
+	assert.NotEmpty(t, res.AccessToken)
+	assert.NotEmpty(t, res.RefreshToken)
+
+	parsedToken, _ := (&tokenService{}).parseToken(res.AccessToken, getSettingsFixture().AccessSecretKey, "HS256")
+	assert.Equal(t, "read write", parsedToken.Claims.(jwt.MapClaims)[scopeClaim])
+}
+
+func TestTokenEndpointPasswordGrantInvalidCredentials(t *testing.T) {
+	strgMock := new(storageMock)
+	rr := testTokenEndpointInit(getPasswordGrantSettings(), strgMock, url.Values{"grant_type": {"password"},
+		"username": {"user"}, "password": {"wrong"}})
+
+	var res OAuth2ErrorResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid_grant", res.Error)
+	assert.Equal(t, "invalid credentials", res.ErrorDescription)
+	strgMock.AssertNotCalled(t, "SaveTokens")
+}
+
+func TestTokenEndpointPasswordGrantEmptyUserIdError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Authenticator = func(c *gin.Context) (string, error) {
+		return "", nil
+	}
+	rr := testTokenEndpointInit(settings, new(storageMock), url.Values{"grant_type": {"password"},
+		"username": {"user"}, "password": {"pass"}})
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestTokenEndpointPasswordGrantSaveError(t *testing.T) {
+	strgMock := new(storageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(errors.New("save error"))
+	rr := testTokenEndpointInit(getPasswordGrantSettings(), strgMock, url.Values{"grant_type": {"password"},
+		"username": {"user"}, "password": {"pass"}})
+
+	var res OAuth2ErrorResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "server_error", res.Error)
+}
+
+func TestTokenEndpointRefreshTokenGrant(t *testing.T) {
+	settings := getSettingsFixture()
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings, &tokenParams{userId: "1",
+		claims: map[string]interface{}{scopeClaim: "read write"}}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasRefreshToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	rr := testTokenEndpointInit(settings, strgMock, url.Values{"grant_type": {"refresh_token"},
+		"refresh_token": {refreshData.token}, "scope": {"read"}})
+
+	var res OAuth2TokenResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "read", res.Scope)
+	assert.NotEmpty(t, res.AccessToken)
+	assert.NotEmpty(t, res.RefreshToken)
+	strgMock.AssertCalled(t, "DeleteTokens")
+}
+
+func TestTokenEndpointRefreshTokenGrantInvalidScope(t *testing.T) {
+	settings := getSettingsFixture()
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings, &tokenParams{userId: "1",
+		claims: map[string]interface{}{scopeClaim: "read"}}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasRefreshToken", mock.Anything).Return(nil)
+	rr := testTokenEndpointInit(settings, strgMock, url.Values{"grant_type": {"refresh_token"},
+		"refresh_token": {refreshData.token}, "scope": {"read write"}})
+
+	var res OAuth2ErrorResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid_scope", res.Error)
+	strgMock.AssertNotCalled(t, "DeleteTokens")
+}
+
+func TestTokenEndpointRefreshTokenGrantRevoked(t *testing.T) {
+	settings := getSettingsFixture()
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasRefreshToken", mock.Anything).Return(ErrTokenExpired)
+	rr := testTokenEndpointInit(settings, strgMock, url.Values{"grant_type": {"refresh_token"},
+		"refresh_token": {refreshData.token}})
+
+	var res OAuth2ErrorResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid_grant", res.Error)
+}
+
+func TestTokenEndpointRequestErrors(t *testing.T) {
+	cases := []struct {
+		form     url.Values
+		errCode  string
+		httpCode int
+	}{
+		{url.Values{}, "invalid_request", http.StatusBadRequest},
+		{url.Values{"grant_type": {"authorization_code"}}, "unsupported_grant_type", http.StatusBadRequest},
+		{url.Values{"grant_type": {"refresh_token"}}, "invalid_request", http.StatusBadRequest},
+	}
+	for _, testCase := range cases {
+		rr := testTokenEndpointInit(getSettingsFixture(), new(storageMock), testCase.form)
+		var res OAuth2ErrorResponse
+		_ = json.NewDecoder(rr.Body).Decode(&res)
+
+		assert.Equal(t, testCase.httpCode, rr.Code)
+		assert.Equal(t, testCase.errCode, res.Error)
+	}
+}
+
+func TestTokenEndpointInvalidClient(t *testing.T) {
+	settings := getPasswordGrantSettings()
+	settings.TokenClientAuthenticator = func(c *gin.Context) error {
+		return errors.New("invalid client")
+	}
+	rr := testTokenEndpointInit(settings, new(storageMock), url.Values{"grant_type": {"password"},
+		"username": {"user"}, "password": {"pass"}})
+
+	var res OAuth2ErrorResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "invalid_client", res.Error)
+}
+
+func TestTokenEndpointIgnoresIntrospectionClientAuthenticator(t *testing.T) {
+	settings := getPasswordGrantSettings()
+	settings.ClientAuthenticator = func(c *gin.Context) error {
+		return errors.New("invalid client")
+	}
+	strgMock := new(storageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	rr := testTokenEndpointInit(settings, strgMock, url.Values{"grant_type": {"password"},
+		"username": {"user"}, "password": {"pass"}})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestIsSubScope(t *testing.T) {
+	assert.True(t, isSubScope("read", "read write"))
+	assert.True(t, isSubScope("write read", "read write"))
+	assert.False(t, isSubScope("admin", "read write"))
+	assert.False(t, isSubScope("read", ""))
+}
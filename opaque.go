@@ -0,0 +1,95 @@
+package gwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"net/http"
+)
+
+// TokenFormat is the format of issued access, refresh and mfa tokens
+type TokenFormat string
+
+const (
+	// TokenFormatJWT issues signed JWTs carrying their claims, it is the default
+	TokenFormatJWT TokenFormat = "jwt"
+
+	// TokenFormatOpaque issues random tokens, their claims are kept by storage implementing
+	// OpaqueTokenStorageInterface and looked up on every request
+	TokenFormatOpaque TokenFormat = "opaque"
+)
+
+// opaqueTokenLength is the number of random bytes in opaque token
+const opaqueTokenLength = 32
+
+// OpaqueToken is what an opaque token stands for, storage keeps it by token hash until Expire and deletes it by Uuid.
+// Access token is kept until its refresh token expires, so it can be revoked after it has expired, the exp claim
+// is checked separately.
+type OpaqueToken struct {
+	UserId string                 `json:"user_id"`
+	Uuid   string                 `json:"uuid"`
+	Expire int64                  `json:"expire"`
+	Claims map[string]interface{} `json:"claims"`
+}
+
+// createOpaqueToken saves the claims under the hash of a new random token and returns the token
+func createOpaqueToken(settings *Settings, claims jwt.MapClaims, tokenUuid string, keepUntil int64) (string, error) {
+	opaqueStorage, ok := unwrapStorage[OpaqueTokenStorageInterface](settings.Storage)
+	if !ok {
+		return "", ErrUnsupportedStorage
+	}
+	buf := make([]byte, opaqueTokenLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	userId, _ := claims[userIdClaim].(string)
+	if err := opaqueStorage.SaveOpaqueToken(hashOpaqueToken(token),
+		&OpaqueToken{UserId: userId, Uuid: tokenUuid, Expire: keepUntil, Claims: claims}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// resolveOpaqueToken returns the token with claims kept by storage, ErrTokenNotFound is returned if the token
+// is unknown, has been revoked or has expired
+func resolveOpaqueToken(settings *Settings, tkn string) (*jwt.Token, error) {
+	opaqueStorage, ok := unwrapStorage[OpaqueTokenStorageInterface](settings.Storage)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	opaqueToken, err := opaqueStorage.GetOpaqueToken(hashOpaqueToken(tkn))
+	if err != nil {
+		return nil, err
+	}
+	return &jwt.Token{Claims: jwt.MapClaims(opaqueToken.Claims), Valid: true}, nil
+}
+
+// deleteOpaqueTokens deletes opaque tokens by uuids, so they are not kept after logout or revocation
+func deleteOpaqueTokens(settings *Settings, uuids ...string) error {
+	if settings.TokenFormat != TokenFormatOpaque {
+		return nil
+	}
+	opaqueStorage, ok := unwrapStorage[OpaqueTokenStorageInterface](settings.Storage)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return opaqueStorage.DeleteOpaqueTokens(uuids...)
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseErrorCode is the response code of the token which cannot be parsed. Opaque token missing in the storage
+// is unauthorized, as it has been revoked or has expired, other tokens are malformed.
+func parseErrorCode(err error) int {
+	if errors.Is(err, ErrTokenNotFound) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
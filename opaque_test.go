@@ -0,0 +1,211 @@
+package gwt
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getOpaqueSettingsFixture() (*Settings, *opaqueStorageMock) {
+	strgMock := newOpaqueStorageMock()
+	strgMock.On("SaveOpaqueToken", mock.Anything).Return(nil)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	strgMock.On("DeleteOpaqueTokens", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.TokenFormat = TokenFormatOpaque
+	settings.Storage = strgMock
+	return settings, strgMock
+}
+
+func TestCreateOpaqueAccessToken(t *testing.T) {
+	settings, strgMock := getOpaqueSettingsFixture()
+	accessData, err := (&tokenService{})._createAccessToken(settings,
+		&tokenParams{userId: "1", claims: map[string]interface{}{"tenant": "acme"}}, "access", "refresh")
+
+	assert.Nil(t, err)
+	assert.Len(t, accessData.token, 43)
+	assert.NotContains(t, accessData.token, ".")
+	saved := strgMock.opaqueTokens[hashOpaqueToken(accessData.token)]
+	assert.Equal(t, "1", saved.UserId)
+	assert.Equal(t, "access", saved.Uuid)
+	assert.Equal(t, accessData.expire, saved.Claims[expiredClaim])
+	assert.Equal(t, (&tokenService{})._getRefreshExpire(settings, &tokenParams{}), saved.Expire)
+	assert.Equal(t, "acme", saved.Claims["tenant"])
+	assert.Equal(t, "access", saved.Claims[accessUuidClaim])
+}
+
+func TestCreateOpaqueAccessTokenWithoutRefreshToken(t *testing.T) {
+	settings, strgMock := getOpaqueSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{clientId: "client"}, "access", "")
+
+	assert.Equal(t, accessData.expire, strgMock.opaqueTokens[hashOpaqueToken(accessData.token)].Expire)
+}
+
+func TestCreateOpaqueTokenSaveError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.TokenFormat = TokenFormatOpaque
+	strgMock := newOpaqueStorageMock()
+	strgMock.On("SaveOpaqueToken", mock.Anything).Return(errors.New("save error"))
+	settings.Storage = strgMock
+	_, err := (&tokenService{})._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+
+	assert.Equal(t, ErrFailedToCreateRefreshToken, err)
+}
+
+func TestCreateOpaqueTokenUnsupportedStorage(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.TokenFormat = TokenFormatOpaque
+	settings.Storage = new(storageMock)
+	_, err := createOpaqueToken(settings, map[string]interface{}{userIdClaim: "1"}, "access", 0)
+
+	assert.Equal(t, ErrUnsupportedStorage, err)
+}
+
+func TestDecodeOpaqueToken(t *testing.T) {
+	settings, _ := getOpaqueSettingsFixture()
+	service := &tokenService{}
+	refreshData, _ := service._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	token, err := service.decodeToken(settings, refreshData.token, settings.RefreshSecretKey)
+
+	assert.Nil(t, err)
+	claims, _ := service.getClaims(token, []string{userIdClaim, refreshUuidClaim})
+	assert.Equal(t, map[string]string{userIdClaim: "1", refreshUuidClaim: "refresh"}, claims)
+
+	_, err = service.decodeToken(settings, "unknown", settings.RefreshSecretKey)
+	assert.Equal(t, ErrTokenNotFound, err)
+}
+
+func testOpaqueAuthMiddleware(settings *Settings, token string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	mw := &Middleware{settings: settings}
+	var claims map[string]interface{}
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+	request.Header.Add("Authorization", "Bearer "+token)
+	mw.GetHTTPAuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims = ClaimsFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, request)
+	return rr, claims
+}
+
+func TestHTTPAuthMiddlewareOpaqueToken(t *testing.T) {
+	settings, _ := getOpaqueSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings,
+		&tokenParams{userId: "1", claims: map[string]interface{}{"tenant": "acme"}}, "access", "refresh")
+	rr, claims := testOpaqueAuthMiddleware(settings, accessData.token)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, claims)
+}
+
+func TestHTTPAuthMiddlewareUnknownOpaqueToken(t *testing.T) {
+	settings, _ := getOpaqueSettingsFixture()
+	jwtSettings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(jwtSettings, &tokenParams{userId: "1"}, "access", "refresh")
+
+	for _, token := range []string{"unknown", accessData.token} {
+		rr, _ := testOpaqueAuthMiddleware(settings, token)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Contains(t, rr.Body.String(), ErrTokenNotFound.Error())
+	}
+}
+
+func testOpaqueRevocation(settings *Settings, token string) *httptest.ResponseRecorder {
+	handler := &Handler{settings: settings}
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/revoke", handler.GetRevocationHandler())
+	request, _ := http.NewRequest(http.MethodPost, "/revoke", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(rr, request)
+	return rr
+}
+
+func TestRevokeExpiredOpaqueAccessToken(t *testing.T) {
+	settings, strgMock := getOpaqueSettingsFixture()
+	settings.AccessLifetime = -time.Minute
+	service := &tokenService{}
+	accessData, _ := service._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	refreshData, _ := service._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	rr, _ := testOpaqueAuthMiddleware(settings, accessData.token)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrTokenExpired.Error())
+
+	rr = testOpaqueRevocation(settings, accessData.token)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	strgMock.AssertCalled(t, "DeleteTokens")
+	strgMock.AssertCalled(t, "DeleteOpaqueTokens")
+	assert.NotContains(t, strgMock.opaqueTokens, hashOpaqueToken(accessData.token))
+	assert.NotContains(t, strgMock.opaqueTokens, hashOpaqueToken(refreshData.token))
+}
+
+func TestRevokeUnknownOpaqueTokenLooksUpOnce(t *testing.T) {
+	settings, strgMock := getOpaqueSettingsFixture()
+	rr := testOpaqueRevocation(settings, "unknown")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, strgMock.lookups)
+	strgMock.AssertNotCalled(t, "DeleteTokens")
+}
+
+func TestLogoutDeletesOpaqueTokens(t *testing.T) {
+	settings, strgMock := getOpaqueSettingsFixture()
+	service := &tokenService{}
+	accessData, _ := service._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	refreshData, _ := service._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	handler := &Handler{settings: settings}
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodPost, "/logout", nil)
+	request.Header.Add("Authorization", "Bearer "+accessData.token)
+	handler.GetHTTPLogoutHandler().ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, strgMock.opaqueTokens)
+	_, err := service.decodeToken(settings, refreshData.token, settings.RefreshSecretKey)
+	assert.Equal(t, ErrTokenNotFound, err)
+}
+
+func TestDeleteOpaqueTokensJWTFormat(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Storage = new(storageMock)
+
+	assert.Nil(t, deleteOpaqueTokens(settings, "access", "refresh"))
+}
+
+func TestInitOpaqueTokenFormat(t *testing.T) {
+	settings, _ := getOpaqueSettingsFixture()
+	_, err := Init(*settings)
+	assert.Nil(t, err)
+
+	settings.Storage = new(storageMock)
+	_, err = Init(*settings)
+	assert.ErrorIs(t, err, ErrUnsupportedStorage)
+
+	settings.TokenFormat = "paseto"
+	_, err = Init(*settings)
+	assert.ErrorIs(t, err, ErrUnknownTokenFormat)
+}
+
+func TestDefaultTokenFormat(t *testing.T) {
+	auth, err := Init(*getSettingsFixture())
+
+	assert.Nil(t, err)
+	assert.Equal(t, TokenFormatJWT, auth.Service.settings.TokenFormat)
+}
+
+func TestParseErrorCode(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, parseErrorCode(ErrTokenNotFound))
+	assert.Equal(t, http.StatusBadRequest, parseErrorCode(ErrTokenInvalid))
+}
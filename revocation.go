@@ -0,0 +1,59 @@
+package gwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type RevocationRequestData struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+// revocationHandler revokes token even if it has expired, unknown and invalid tokens are ignored as RFC 7009 requires
+func (handler *Handler) revocationHandler(c *gin.Context) {
+	service := &tokenService{}
+	hooks := &handler.settings.Hooks
+	if handler.settings.RevocationClientAuthenticator != nil {
+		if err := handler.settings.RevocationClientAuthenticator(c); err != nil {
+			handler.settings.ErrResponseFunc(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+	}
+	requestData := RevocationRequestData{}
+	if err := c.ShouldBind(&requestData); err != nil || requestData.Token == "" {
+		handler.settings.ErrResponseFunc(c, http.StatusBadRequest, ErrTokenIsNotProvided.Error())
+		return
+	}
+	secrets := [][]byte{handler.settings.AccessSecretKey, handler.settings.RefreshSecretKey}
+	if requestData.TokenTypeHint == refreshTokenType {
+		secrets = [][]byte{handler.settings.RefreshSecretKey, handler.settings.AccessSecretKey}
+	}
+	if handler.settings.TokenFormat == TokenFormatOpaque {
+		// opaque tokens are looked up in the storage regardless of the secret
+		secrets = secrets[:1]
+	}
+	var claims map[string]string
+	for _, secret := range secrets {
+		parsedToken, parseErr := service.decodeToken(handler.settings, requestData.Token, secret)
+		if parseErr != nil {
+			continue
+		}
+		if claims, _ = service.getClaims(parsedToken, []string{accessUuidClaim, refreshUuidClaim, userIdClaim,
+			sessionIdClaim}); claims != nil {
+			break
+		}
+	}
+	// refresh uuid is empty for access tokens of client credentials grant, they are revoked by access uuid
+	if claims == nil || claims[userIdClaim] == "" || claims[accessUuidClaim] == "" {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+	if deleteErr := handler.deleteTokens(claims); deleteErr != nil {
+		handler.settings.ErrResponseFunc(c, http.StatusServiceUnavailable, deleteErr.Error())
+		return
+	}
+
+	hooks.call(hooks.OnLogout, c, claims[userIdClaim], service.getSessionId(claims), nil)
+	c.JSON(http.StatusOK, gin.H{})
+}
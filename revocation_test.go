@@ -0,0 +1,123 @@
+package gwt
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRevocationInit(token string, hint string, deleteTokensErr error,
+	clientAuthenticator func(c *gin.Context) error) (*httptest.ResponseRecorder, *sessionStorageMock) {
+	strgMock := new(sessionStorageMock)
+	strgMock.On("DeleteTokens", mock.Anything).Return(deleteTokensErr)
+	strgMock.On("DeleteSession", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	settings.RevocationClientAuthenticator = clientAuthenticator
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/revoke", handler.GetRevocationHandler())
+	form := url.Values{"token": {token}, "token_type_hint": {hint}}
+	request, _ := http.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(rr, request)
+
+	return rr, strgMock
+}
+
+func TestRevokeExpiredAccessToken(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.AccessLifetime = -time.Minute
+	accessData, _ := (&tokenService{})._createAccessToken(settings,
+		&tokenParams{userId: "1", sessionId: "sid"}, "access", "refresh")
+	rr, strgMock := testRevocationInit(accessData.token, "", nil, nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	strgMock.AssertCalled(t, "DeleteTokens")
+	strgMock.AssertCalled(t, "DeleteSession")
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.RefreshSecretKey = []byte("refresh_secret")
+	refreshData, _ := (&tokenService{})._createRefreshToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+
+	strgMock := new(storageMock)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	handler := &Handler{settings: settings}
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/revoke", handler.GetRevocationHandler())
+	form := url.Values{"token": {refreshData.token}, "token_type_hint": {"refresh_token"}}
+	request, _ := http.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	strgMock.AssertCalled(t, "DeleteTokens")
+}
+
+func TestRevokeUnknownToken(t *testing.T) {
+	rr, strgMock := testRevocationInit("unknown", "", nil, nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	strgMock.AssertNotCalled(t, "DeleteTokens")
+}
+
+func TestRevokeDeleteTokensError(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(),
+		&tokenParams{userId: "1"}, "access", "refresh")
+	rr, _ := testRevocationInit(accessData.token, "access_token", errors.New("delete error"), nil)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+func TestRevokeClientError(t *testing.T) {
+	rr, strgMock := testRevocationInit("token", "", nil, func(c *gin.Context) error {
+		return errors.New("invalid client")
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	strgMock.AssertNotCalled(t, "DeleteTokens")
+}
+
+func TestRevokeIgnoresIntrospectionClientAuthenticator(t *testing.T) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	settings.ClientAuthenticator = func(c *gin.Context) error {
+		return errors.New("invalid client")
+	}
+	handler := &Handler{settings: settings}
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/revoke", handler.GetRevocationHandler())
+	form := url.Values{"token": {accessData.token}}
+	request, _ := http.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	strgMock.AssertCalled(t, "DeleteTokens")
+}
+
+func TestRevokeNoTokenError(t *testing.T) {
+	rr, _ := testRevocationInit("", "", nil, nil)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -1,9 +1,131 @@
 package gwt
 
+import "errors"
+
 type Service struct {
 	settings *Settings
 }
 
 func (service *Service) ForceLogoutUser(userId string) error {
-	return service.settings.Storage.DeleteAllTokens(userId)
+	if _, err := deleteAllSessions(service.settings.Storage, userId); err != nil {
+		return err
+	}
+	invalidateUser(service.settings, userId)
+	service.settings.Hooks.call(service.settings.Hooks.OnForceLogout, nil, userId, "", nil)
+	return nil
+}
+
+// IssueTokens creates and saves tokens of the user authenticated outside of login handler,
+// e.g. right after sign up. Hooks are not called.
+func (service *Service) IssueTokens(result *AuthResult) (*Tokens, error) {
+	if result == nil || result.UserId == "" {
+		return nil, ErrUserIdIsNotProvided
+	}
+	accessData, refreshData, err := saveTokens(service.settings, newTokenParams(result))
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{
+		AccessToken:   accessData.token,
+		AccessExpire:  accessData.expire,
+		RefreshToken:  refreshData.token,
+		RefreshExpire: refreshData.expire,
+	}, nil
+}
+
+// EnrollMFA generates and saves a new second factor secret and recovery codes of the user,
+// existing secret is replaced. Storage must implement MFAStorageInterface.
+func (service *Service) EnrollMFA(userId string, issuer string, accountName string) (*MFAEnrollment, error) {
+	mfaStorage, ok := unwrapStorage[MFAStorageInterface](service.settings.Storage)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	enrollment, err := newMFAEnrollment(issuer, accountName)
+	if err != nil {
+		return nil, err
+	}
+	var recoveryCodes []string
+	for _, code := range enrollment.RecoveryCodes {
+		recoveryCodes = append(recoveryCodes, hashRecoveryCode(code))
+	}
+	if saveErr := mfaStorage.SaveMFASecret(userId, enrollment.Secret, recoveryCodes); saveErr != nil {
+		return nil, saveErr
+	}
+	return enrollment, nil
+}
+
+// VerifyMFACode checks TOTP or recovery code of the user, can be used to confirm enrollment
+func (service *Service) VerifyMFACode(userId string, code string) error {
+	mfaStorage, ok := unwrapStorage[MFAStorageInterface](service.settings.Storage)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return verifyMFACode(service.settings, mfaStorage, userId, code)
+}
+
+// HasMFA reports whether user has second factor enrolled
+func (service *Service) HasMFA(userId string) (bool, error) {
+	mfaStorage, ok := unwrapStorage[MFAStorageInterface](service.settings.Storage)
+	if !ok {
+		return false, ErrUnsupportedStorage
+	}
+	if _, err := mfaStorage.GetMFASecret(userId); err != nil {
+		if errors.Is(err, ErrMFANotEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// DisableMFA deletes second factor secret and recovery codes of the user
+func (service *Service) DisableMFA(userId string) error {
+	mfaStorage, ok := unwrapStorage[MFAStorageInterface](service.settings.Storage)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	return mfaStorage.DeleteMFASecret(userId)
+}
+
+// PurgeExpired deletes expired records from storage implementing PurgeStorageInterface, e.g. gorm storage,
+// call it periodically. Other storages expire records themselves, nil is returned for them.
+func (service *Service) PurgeExpired() error {
+	purgeStorage, ok := unwrapStorage[PurgeStorageInterface](service.settings.Storage)
+	if !ok {
+		return nil
+	}
+	return purgeStorage.PurgeExpired()
+}
+
+// ListSessions returns active sessions of the user, storage must implement SessionListStorageInterface
+func (service *Service) ListSessions(userId string) ([]*Session, error) {
+	sessionListStorage, ok := unwrapStorage[SessionListStorageInterface](service.settings.Storage)
+	if !ok {
+		return nil, ErrUnsupportedStorage
+	}
+	return sessionListStorage.GetSessions(userId)
+}
+
+// RevokeSession deletes the session of the user and its current tokens, other sessions are kept.
+// Storage must implement SessionStorageInterface.
+func (service *Service) RevokeSession(userId string, sessionId string) error {
+	sessionStorage, ok := unwrapStorage[SessionStorageInterface](service.settings.Storage)
+	if !ok {
+		return ErrUnsupportedStorage
+	}
+	session, err := sessionStorage.GetSession(userId, sessionId)
+	if err != nil {
+		return err
+	}
+	if deleteErr := service.settings.Storage.DeleteTokens(userId, session.AccessUuid, session.RefreshUuid); deleteErr != nil {
+		return deleteErr
+	}
+	if deleteErr := deleteOpaqueTokens(service.settings, session.AccessUuid, session.RefreshUuid); deleteErr != nil {
+		return deleteErr
+	}
+	if deleteErr := sessionStorage.DeleteSession(userId, sessionId); deleteErr != nil {
+		return deleteErr
+	}
+	service.settings.Hooks.call(service.settings.Hooks.OnForceLogout, nil, userId, sessionId, nil)
+	return nil
 }
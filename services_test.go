@@ -2,6 +2,7 @@ package gwt
 
 import (
 	"errors"
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
@@ -33,3 +34,120 @@ func TestForceLogoutUserError(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestEnrollMFASuccess(t *testing.T) {
+	strgMock := new(mfaStorageMock)
+	strgMock.On("SaveMFASecret", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	service := &Service{settings: settings}
+	enrollment, err := service.EnrollMFA("1", "App", "user")
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, enrollment.Secret)
+}
+
+func TestEnrollMFAUnsupportedStorageError(t *testing.T) {
+	service := testInitService("")
+	_, err := service.EnrollMFA("1", "App", "user")
+
+	assert.Equal(t, ErrUnsupportedStorage, err)
+}
+
+func TestHasMFA(t *testing.T) {
+	strgMock := new(mfaStorageMock)
+	strgMock.On("GetMFASecret", mock.Anything).Return("", ErrMFANotEnrolled)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	service := &Service{settings: settings}
+	hasMFA, err := service.HasMFA("1")
+
+	assert.Nil(t, err)
+	assert.False(t, hasMFA)
+}
+
+func TestIssueTokensSuccess(t *testing.T) {
+	strgMock := new(storageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	service := &Service{settings: settings}
+	tokens, err := service.IssueTokens(&AuthResult{UserId: "1", Claims: map[string]interface{}{"tenant": "acme"}})
+
+	assert.Nil(t, err)
+	claims, _ := (&tokenService{}).getClaims(mustParseAccessToken(tokens.AccessToken), []string{userIdClaim, "tenant"})
+	assert.Equal(t, "1", claims[userIdClaim])
+	assert.Equal(t, "acme", claims["tenant"])
+	assert.NotEmpty(t, tokens.RefreshToken)
+	strgMock.AssertCalled(t, "SaveTokens")
+}
+
+func TestIssueTokensEmptyUserIdError(t *testing.T) {
+	service := &Service{settings: getSettingsFixture()}
+	_, err := service.IssueTokens(&AuthResult{})
+
+	assert.Equal(t, ErrUserIdIsNotProvided, err)
+}
+
+func TestPurgeExpired(t *testing.T) {
+	strgMock := new(purgeStorageMock)
+	strgMock.On("PurgeExpired").Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = &instrumentedStorage{storage: strgMock, metrics: newMetrics()}
+
+	assert.Nil(t, (&Service{settings: settings}).PurgeExpired())
+	strgMock.AssertCalled(t, "PurgeExpired")
+
+	settings.Storage = new(storageMock)
+	assert.Nil(t, (&Service{settings: settings}).PurgeExpired())
+}
+
+func TestListSessions(t *testing.T) {
+	strgMock := new(sessionStorageMock)
+	strgMock.On("GetSessions", mock.Anything).Return([]*Session{{Id: "sid", UserId: "1"}}, nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	sessions, err := (&Service{settings: settings}).ListSessions("1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*Session{{Id: "sid", UserId: "1"}}, sessions)
+}
+
+func TestListSessionsUnsupportedStorageError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Storage = new(storageMock)
+	_, err := (&Service{settings: settings}).ListSessions("1")
+
+	assert.Equal(t, ErrUnsupportedStorage, err)
+}
+
+func TestRevokeSessionSuccess(t *testing.T) {
+	strgMock := new(sessionStorageMock)
+	strgMock.On("GetSession", mock.Anything).Return(&Session{Id: "sid", UserId: "1", AccessUuid: "access",
+		RefreshUuid: "refresh"}, nil)
+	strgMock.On("DeleteTokens", mock.Anything).Return(nil)
+	strgMock.On("DeleteSession", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	var hookSessionId string
+	settings.Hooks.OnForceLogout = func(c *gin.Context, userId string, sessionId string, err error) {
+		hookSessionId = sessionId
+	}
+	err := (&Service{settings: settings}).RevokeSession("1", "sid")
+
+	assert.Nil(t, err)
+	strgMock.AssertCalled(t, "DeleteTokens")
+	strgMock.AssertCalled(t, "DeleteSession")
+	assert.Equal(t, "sid", hookSessionId)
+}
+
+func TestRevokeSessionNotFoundError(t *testing.T) {
+	strgMock := new(sessionStorageMock)
+	strgMock.On("GetSession", mock.Anything).Return(nil, ErrSessionNotFound)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	err := (&Service{settings: settings}).RevokeSession("1", "sid")
+
+	assert.Equal(t, ErrSessionNotFound, err)
+	strgMock.AssertNotCalled(t, "DeleteTokens")
+}
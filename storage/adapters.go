@@ -19,6 +19,15 @@ type redisIteratorInterface interface {
 	Val() string
 }
 
+// setIfGreaterScript sets the number if the key is missing or keeps a smaller number, 1 is returned if it is set
+var setIfGreaterScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current and tonumber(current) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1])
+return 1`)
+
 type redisAdapter struct {
 	con *redis.Client
 }
@@ -36,9 +45,48 @@ func (a *redisAdapter) SaveMultipleInPipe(ctx context.Context, values ...redisVa
 func (a *redisAdapter) Get(ctx context.Context, key string) (string, error) {
 	return a.con.Get(ctx, key).Result()
 }
+func (a *redisAdapter) GetDel(ctx context.Context, key string) (string, error) {
+	pipe := a.con.TxPipeline()
+	get := pipe.Get(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return get.Val(), nil
+}
 func (a *redisAdapter) GetScanIterator(ctx context.Context, cursor uint64, match string, count int64) redisIteratorInterface {
 	return a.con.Scan(ctx, cursor, match, count).Iterator()
 }
+func (a *redisAdapter) HIncrAndSet(ctx context.Context, key string, incrField string,
+	values map[string]interface{}, expiration time.Duration) (int64, error) {
+	pipe := a.con.TxPipeline()
+	incr := pipe.HIncrBy(ctx, key, incrField, 1)
+	pipe.HSet(ctx, key, values)
+	pipe.Expire(ctx, key, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+func (a *redisAdapter) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return a.con.HGetAll(ctx, key).Result()
+}
+func (a *redisAdapter) ReplaceSet(ctx context.Context, key string, members ...string) error {
+	pipe := a.con.TxPipeline()
+	pipe.Del(ctx, key)
+	for _, member := range members {
+		pipe.SAdd(ctx, key, member)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+func (a *redisAdapter) SetIfGreater(ctx context.Context, key string, value int64) (bool, error) {
+	set, err := setIfGreaterScript.Run(ctx, a.con, []string{key}, value).Int64()
+	return set == 1, err
+}
+func (a *redisAdapter) SRem(ctx context.Context, key string, member string) (int64, error) {
+	return a.con.SRem(ctx, key, member).Result()
+}
 
 type gormAdapter struct{}
 
@@ -48,12 +96,25 @@ func (a *gormAdapter) Transaction(db *gorm.DB, fc func(tx *gorm.DB) error) error
 func (a *gormAdapter) DeleteUnscoped(db *gorm.DB, query interface{}, model interface{}) *gorm.DB {
 	return db.Unscoped().Where(query).Delete(model)
 }
+func (a *gormAdapter) DeleteUnscopedWhere(db *gorm.DB, model interface{}, query interface{}, args ...interface{}) *gorm.DB {
+	return db.Unscoped().Where(query, args...).Delete(model)
+}
 func (a *gormAdapter) Create(db *gorm.DB, value interface{}) *gorm.DB {
 	return db.Create(value)
 }
+func (a *gormAdapter) SelectAll(db *gorm.DB, destination interface{}, query interface{}, args ...interface{}) *gorm.DB {
+	return db.Where(query, args...).Find(destination)
+}
 func (a *gormAdapter) SelectFirst(db *gorm.DB, query interface{}, destination interface{}) *gorm.DB {
 	return db.Where(query).First(destination)
 }
 func (a *gormAdapter) AutoMigrate(db *gorm.DB, dst ...interface{}) error {
 	return db.AutoMigrate(dst...)
 }
+func (a *gormAdapter) UpdateWhere(db *gorm.DB, model interface{}, column string, value interface{},
+	query interface{}, args ...interface{}) *gorm.DB {
+	return db.Model(model).Where(query, args...).Update(column, value)
+}
+func (a *gormAdapter) Save(db *gorm.DB, value interface{}) *gorm.DB {
+	return db.Save(value)
+}
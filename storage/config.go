@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"errors"
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	gormDialectorsMu sync.RWMutex
+	gormDialectors   = map[string]func(dsn string) gorm.Dialector{}
+)
+
+func init() {
+	gwt.RegisterStorage("redis", newRedisStorageFromConfig, "addr", "username", "password", "db")
+	gwt.RegisterStorage("gorm", newGormStorageFromConfig, "driver", "dsn", "table_prefix")
+}
+
+// RegisterGormDialector makes gorm driver available by name in storage.gorm.driver of gwt configuration.
+// Drivers are not imported by the storage package, register the one of your database:
+//
+//	storage.RegisterGormDialector("postgres", postgres.Open)
+//
+// It panics if the name is registered twice.
+func RegisterGormDialector(name string, open func(dsn string) gorm.Dialector) {
+	gormDialectorsMu.Lock()
+	defer gormDialectorsMu.Unlock()
+	if open == nil {
+		panic("gwt: RegisterGormDialector open is nil")
+	}
+	if _, exists := gormDialectors[name]; exists {
+		panic("gwt: RegisterGormDialector called twice for driver " + name)
+	}
+	gormDialectors[name] = open
+}
+
+// newRedisStorageFromConfig creates redis storage configured by storage.redis section of gwt configuration,
+// connection is established on first command
+func newRedisStorageFromConfig(config *viper.Viper) (gwt.StorageInterface, error) {
+	addr := config.GetString("addr")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	db, err := cast.ToIntE(config.Get("db"))
+	if err != nil || db < 0 {
+		return nil, errors.New("db must be a database number")
+	}
+	return InitRedisStorage(redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Username: config.GetString("username"),
+		Password: config.GetString("password"),
+		DB:       db,
+	})), nil
+}
+
+// newGormStorageFromConfig opens gorm connection configured by storage.gorm section of gwt configuration
+// with the driver registered by RegisterGormDialector and migrates the tables
+func newGormStorageFromConfig(config *viper.Viper) (gwt.StorageInterface, error) {
+	gormDialectorsMu.RLock()
+	open, ok := gormDialectors[config.GetString("driver")]
+	gormDialectorsMu.RUnlock()
+	if !ok {
+		return nil, errors.New("driver must be one of registered gorm drivers: " + registeredGormDrivers())
+	}
+	dsn := config.GetString("dsn")
+	if dsn == "" {
+		return nil, errors.New("dsn is required")
+	}
+	con, err := gorm.Open(open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return InitGormStorage(con, config.GetString("table_prefix"))
+}
+
+func registeredGormDrivers() string {
+	gormDialectorsMu.RLock()
+	defer gormDialectorsMu.RUnlock()
+	if len(gormDialectors) == 0 {
+		return "none, see RegisterGormDialector"
+	}
+	names := make([]string, 0, len(gormDialectors))
+	for name := range gormDialectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
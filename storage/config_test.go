@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"bytes"
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestRedisStorageFromConfig(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	_ = v.ReadConfig(bytes.NewBufferString("storage:\n  type: redis\n  redis:\n    addr: redis:6379\n    db: 2"))
+	settings, err := gwt.SettingsFromConfig(v)
+
+	assert.Nil(t, err)
+	assert.IsType(t, &RedisStorage{}, settings.Storage)
+}
+
+func TestGormStorageFromConfigErrors(t *testing.T) {
+	RegisterGormDialector("config-test", func(dsn string) gorm.Dialector {
+		return nil
+	})
+	cases := []struct {
+		config  string
+		message string
+	}{
+		{"storage:\n  type: gorm\n  gorm:\n    driver: oracle\n    dsn: db",
+			"storage gorm: driver must be one of registered gorm drivers: config-test"},
+		{"storage:\n  type: gorm\n  gorm:\n    driver: config-test", "storage gorm: dsn is required"},
+	}
+	for _, testCase := range cases {
+		v := viper.New()
+		v.SetConfigType("yaml")
+		_ = v.ReadConfig(bytes.NewBufferString(testCase.config))
+		_, err := gwt.SettingsFromConfig(v)
+
+		assert.ErrorIs(t, err, gwt.ErrInvalidConfig, testCase.config)
+		assert.Contains(t, err.Error(), testCase.message, testCase.config)
+	}
+	assert.Panics(t, func() {
+		RegisterGormDialector("config-test", func(dsn string) gorm.Dialector {
+			return nil
+		})
+	})
+}
+
+func TestRedisStorageFromConfigInvalidDB(t *testing.T) {
+	config := viper.New()
+	config.Set("db", "first")
+	_, err := newRedisStorageFromConfig(config)
+
+	assert.EqualError(t, err, "db must be a database number")
+}
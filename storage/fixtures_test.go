@@ -6,6 +6,7 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
+	"time"
 )
 
 type gormAdapterMock struct {
@@ -41,9 +42,23 @@ func (m *gormAdapterMock) Create(db *gorm.DB, value interface{}) *gorm.DB {
 func (m *gormAdapterMock) SelectFirst(db *gorm.DB, query interface{}, destination interface{}) *gorm.DB {
 	return m.Called().Get(0).(*gorm.DB)
 }
+func (m *gormAdapterMock) DeleteUnscopedWhere(db *gorm.DB, model interface{}, query interface{}, args ...interface{}) *gorm.DB {
+	return m.Called().Get(0).(*gorm.DB)
+}
+func (m *gormAdapterMock) SelectAll(db *gorm.DB, destination interface{}, query interface{}, args ...interface{}) *gorm.DB {
+	return m.Called().Get(0).(*gorm.DB)
+}
 func (m *gormAdapterMock) AutoMigrate(db *gorm.DB, dst ...interface{}) error {
 	return m.Called().Error(0)
 }
+func (m *gormAdapterMock) Save(db *gorm.DB, value interface{}) *gorm.DB {
+	return m.Called().Get(0).(*gorm.DB)
+}
+
+func (m *gormAdapterMock) UpdateWhere(db *gorm.DB, model interface{}, column string, value interface{},
+	query interface{}, args ...interface{}) *gorm.DB {
+	return m.Called().Get(0).(*gorm.DB)
+}
 
 type redisAdapterMock struct {
 	mock.Mock
@@ -58,9 +73,28 @@ func (m *redisAdapterMock) SaveMultipleInPipe(ctx context.Context, values ...red
 func (m *redisAdapterMock) Get(ctx context.Context, key string) (string, error) {
 	return m.Called().String(0), m.Called().Error(1)
 }
+func (m *redisAdapterMock) GetDel(ctx context.Context, key string) (string, error) {
+	return m.Called().String(0), m.Called().Error(1)
+}
 func (m *redisAdapterMock) GetScanIterator(ctx context.Context, cursor uint64, match string, count int64) redisIteratorInterface {
 	return m.Called().Get(0).(redisIteratorInterface)
 }
+func (m *redisAdapterMock) HIncrAndSet(ctx context.Context, key string, incrField string,
+	values map[string]interface{}, expiration time.Duration) (int64, error) {
+	return m.Called().Get(0).(int64), m.Called().Error(1)
+}
+func (m *redisAdapterMock) ReplaceSet(ctx context.Context, key string, members ...string) error {
+	return m.Called().Error(0)
+}
+func (m *redisAdapterMock) SRem(ctx context.Context, key string, member string) (int64, error) {
+	return m.Called().Get(0).(int64), m.Called().Error(1)
+}
+func (m *redisAdapterMock) SetIfGreater(ctx context.Context, key string, value int64) (bool, error) {
+	return m.Called().Bool(0), m.Called().Error(1)
+}
+func (m *redisAdapterMock) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return m.Called().Get(0).(map[string]string), m.Called().Error(1)
+}
 
 type redisIteratorMock struct {
 	mock.Mock
@@ -86,3 +120,11 @@ func (m *redisIteratorMock) Val() string {
 func (m *redisIteratorMock) setInit() {
 	m.init = true
 }
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (clock *fixedClock) Now() time.Time {
+	return clock.now
+}
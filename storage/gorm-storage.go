@@ -1,21 +1,39 @@
 package storage
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/ennaque/go-gin-jwt"
 	"github.com/spf13/viper"
 	"gorm.io/gorm"
+	"time"
 )
 
-var gwtTokensTablePrefix = "_gwt_token_data"
+var (
+	gwtTokensTablePrefix        = "_gwt_token_data"
+	gwtLoginAttemptsTablePrefix = "_gwt_login_attempts"
+	gwtSessionsTablePrefix      = "_gwt_sessions"
+	gwtMFASecretsTablePrefix    = "_gwt_mfa_secrets"
+	gwtMFACodesTablePrefix      = "_gwt_mfa_recovery_codes"
+	gwtMFATokensTablePrefix     = "_gwt_mfa_tokens"
+	gwtImpersonationsPrefix     = "_gwt_impersonations"
+	gwtTicketsTablePrefix       = "_gwt_tickets"
+	gwtOpaqueTokensTablePrefix  = "_gwt_opaque_tokens"
+)
 
 type gormStorage struct {
 	con     *gorm.DB
 	adapter gormAdapterInterface
+	clock   gwt.Clock
 }
 
 func (gs *gormStorage) DeleteTokens(userId string, uuid ...string) error {
 	err := gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
 		for _, id := range uuid {
+			// empty uuid is skipped, as gorm would match all tokens of the user
+			if id == "" {
+				continue
+			}
 			if err := gs.adapter.DeleteUnscoped(tx, &tokenData{UserId: userId, Uuid: id}, &tokenData{}).Error; err != nil {
 				return err
 			}
@@ -45,6 +63,10 @@ func (gs *gormStorage) SaveTokens(userId string, accessUuid string, refreshUuid
 	}
 	return nil
 }
+func (gs *gormStorage) SaveAccessToken(userId string, accessUuid string, accessExpire int64, accessToken string) error {
+	return gs.adapter.Create(gs.con, &tokenData{Token: accessToken, Uuid: accessUuid, Expire: accessExpire,
+		UserId: userId, TokenType: "access"}).Error
+}
 func (gs *gormStorage) HasRefreshToken(uuid string, token string, userId string) error {
 	var data tokenData
 	if err := gs.adapter.SelectFirst(gs.con,
@@ -62,16 +84,373 @@ func (gs *gormStorage) HasAccessToken(uuid string, token string, userId string)
 	return nil
 }
 func (gs *gormStorage) DeleteAllTokens(userId string) error {
-	if err := gs.adapter.DeleteUnscoped(gs.con, &tokenData{UserId: userId}, &tokenData{}).Error; err != nil {
+	return gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		if err := gs.adapter.DeleteUnscoped(tx, &tokenData{UserId: userId}, &tokenData{}).Error; err != nil {
+			return err
+		}
+		return gs.adapter.DeleteUnscoped(tx, &sessionData{UserId: userId}, &sessionData{}).Error
+	})
+}
+func (gs *gormStorage) DeleteAllSessions(userId string) (int64, error) {
+	var removed int64
+	err := gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		refreshResult := gs.adapter.DeleteUnscoped(tx, &tokenData{UserId: userId, TokenType: "refresh"}, &tokenData{})
+		if refreshResult.Error != nil {
+			return refreshResult.Error
+		}
+		removed = refreshResult.RowsAffected
+		if err := gs.adapter.DeleteUnscoped(tx, &tokenData{UserId: userId}, &tokenData{}).Error; err != nil {
+			return err
+		}
+		return gs.adapter.DeleteUnscoped(tx, &sessionData{UserId: userId}, &sessionData{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+func (gs *gormStorage) DeleteAllTokensExcept(userId string, accessUuid string, refreshUuid string) (int64, error) {
+	var removed int64
+	err := gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		refreshResult := gs.adapter.DeleteUnscopedWhere(tx, &tokenData{}, "user_id = ? AND token_type = ? AND uuid <> ?",
+			userId, "refresh", refreshUuid)
+		if refreshResult.Error != nil {
+			return refreshResult.Error
+		}
+		removed = refreshResult.RowsAffected
+		if err := gs.adapter.DeleteUnscopedWhere(tx, &tokenData{}, "user_id = ? AND uuid NOT IN ?",
+			userId, []string{accessUuid, refreshUuid}).Error; err != nil {
+			return err
+		}
+		return gs.adapter.DeleteUnscopedWhere(tx, &sessionData{}, "user_id = ? AND refresh_uuid <> ?",
+			userId, refreshUuid).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+func (gs *gormStorage) SaveSession(session *gwt.Session, expire int64) error {
+	metadata, err := json.Marshal(session.Metadata)
+	if err != nil {
 		return err
 	}
+	return gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		if err := gs.adapter.DeleteUnscopedWhere(tx, &sessionData{}, "session_id = ?", session.Id).Error; err != nil {
+			return err
+		}
+		return gs.adapter.Create(tx, &sessionData{
+			SessionId:   session.Id,
+			UserId:      session.UserId,
+			AccessUuid:  session.AccessUuid,
+			RefreshUuid: session.RefreshUuid,
+			StartedAt:   session.CreatedAt,
+			ExpiresAt:   session.ExpiresAt,
+			Metadata:    string(metadata),
+			Expire:      expire,
+		}).Error
+	})
+}
+
+// GetSession rejects empty ids, as gorm skips empty fields of struct conditions and would match any session
+func (gs *gormStorage) GetSession(userId string, sessionId string) (*gwt.Session, error) {
+	if userId == "" || sessionId == "" {
+		return nil, gwt.ErrSessionNotFound
+	}
+	data := sessionData{}
+	if err := gs.adapter.SelectFirst(gs.con, &sessionData{SessionId: sessionId, UserId: userId}, &data).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gwt.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	if data.Expire <= gs.now().Unix() {
+		return nil, gwt.ErrSessionNotFound
+	}
+	return data.toSession()
+}
+func (gs *gormStorage) GetSessions(userId string) ([]*gwt.Session, error) {
+	var data []sessionData
+	if err := gs.adapter.SelectAll(gs.con, &data, "user_id = ? AND expire > ?", userId, gs.now().Unix()).Error; err != nil {
+		return nil, err
+	}
+	sessions := make([]*gwt.Session, 0, len(data))
+	for _, item := range data {
+		session, err := item.toSession()
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+func (gs *gormStorage) DeleteSession(userId string, sessionId string) error {
+	if userId == "" || sessionId == "" {
+		return nil
+	}
+	return gs.adapter.DeleteUnscoped(gs.con, &sessionData{SessionId: sessionId, UserId: userId}, &sessionData{}).Error
+}
+func (gs *gormStorage) AddLoginAttempt(key string, expire int64) (int64, error) {
+	var count int64
+	err := gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		data := loginAttemptData{}
+		if err := gs.adapter.SelectFirst(tx, &loginAttemptData{Key: key}, &data).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			data = loginAttemptData{Key: key}
+		}
+		now := gs.now().Unix()
+		if data.Expire <= now {
+			data.Count = 0
+		}
+		data.Count++
+		data.Last = now
+		data.Expire = expire
+		count = data.Count
+		return gs.adapter.Save(tx, &data).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+func (gs *gormStorage) GetLoginAttempts(key string) (int64, int64, error) {
+	data := loginAttemptData{}
+	if err := gs.adapter.SelectFirst(gs.con, &loginAttemptData{Key: key}, &data).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	if data.Expire <= gs.now().Unix() {
+		return 0, 0, nil
+	}
+	return data.Count, data.Last, nil
+}
+func (gs *gormStorage) DeleteLoginAttempts(key string) error {
+	return gs.adapter.DeleteUnscoped(gs.con, &loginAttemptData{Key: key}, &loginAttemptData{}).Error
+}
+func (gs *gormStorage) SaveMFASecret(userId string, secret string, recoveryCodes []string) error {
+	if userId == "" {
+		return gwt.ErrUserIdIsNotProvided
+	}
+	return gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		if err := gs.adapter.DeleteUnscoped(tx, &mfaSecretData{UserId: userId}, &mfaSecretData{}).Error; err != nil {
+			return err
+		}
+		if err := gs.adapter.DeleteUnscoped(tx, &mfaRecoveryCodeData{UserId: userId}, &mfaRecoveryCodeData{}).Error; err != nil {
+			return err
+		}
+		if err := gs.adapter.Create(tx, &mfaSecretData{UserId: userId, Secret: secret}).Error; err != nil {
+			return err
+		}
+		for _, code := range recoveryCodes {
+			if err := gs.adapter.Create(tx, &mfaRecoveryCodeData{UserId: userId, Code: code}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetMFASecret rejects empty user id, as gorm skips empty fields of struct conditions and would match any secret
+func (gs *gormStorage) GetMFASecret(userId string) (string, error) {
+	if userId == "" {
+		return "", gwt.ErrMFANotEnrolled
+	}
+	data := mfaSecretData{}
+	if err := gs.adapter.SelectFirst(gs.con, &mfaSecretData{UserId: userId}, &data).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", gwt.ErrMFANotEnrolled
+		}
+		return "", err
+	}
+	return data.Secret, nil
+}
+func (gs *gormStorage) UseMFARecoveryCode(userId string, recoveryCode string) error {
+	if userId == "" || recoveryCode == "" {
+		return gwt.ErrInvalidMFACode
+	}
+	res := gs.adapter.DeleteUnscoped(gs.con, &mfaRecoveryCodeData{UserId: userId, Code: recoveryCode}, &mfaRecoveryCodeData{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gwt.ErrInvalidMFACode
+	}
+	return nil
+}
+func (gs *gormStorage) DeleteMFASecret(userId string) error {
+	if userId == "" {
+		return gwt.ErrUserIdIsNotProvided
+	}
+	return gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		if err := gs.adapter.DeleteUnscoped(tx, &mfaSecretData{UserId: userId}, &mfaSecretData{}).Error; err != nil {
+			return err
+		}
+		return gs.adapter.DeleteUnscoped(tx, &mfaRecoveryCodeData{UserId: userId}, &mfaRecoveryCodeData{}).Error
+	})
+}
+
+// UseMFAStep compares and saves the step in one update, so concurrent requests cannot use a code twice
+func (gs *gormStorage) UseMFAStep(userId string, step int64) error {
+	res := gs.adapter.UpdateWhere(gs.con, &mfaSecretData{}, "last_step", step, "user_id = ? AND last_step < ?",
+		userId, step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gwt.ErrInvalidMFACode
+	}
 	return nil
 }
+func (gs *gormStorage) SaveMFAToken(tokenId string, expire int64) error {
+	return gs.adapter.Create(gs.con, &mfaTokenData{TokenId: tokenId, Expire: expire}).Error
+}
+
+// UseMFAToken deletes the token id, so concurrent requests cannot use it twice
+func (gs *gormStorage) UseMFAToken(tokenId string) error {
+	res := gs.adapter.DeleteUnscopedWhere(gs.con, &mfaTokenData{}, "token_id = ? AND expire > ?",
+		tokenId, gs.now().Unix())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gwt.ErrMFATokenNotFound
+	}
+	return nil
+}
+func (gs *gormStorage) SaveImpersonation(impersonation *gwt.Impersonation, expire int64) error {
+	return gs.adapter.Create(gs.con, &impersonationData{
+		ImpersonationId: impersonation.Id,
+		ActorId:         impersonation.ActorId,
+		UserId:          impersonation.UserId,
+		Reason:          impersonation.Reason,
+		AccessUuid:      impersonation.AccessUuid,
+		RefreshUuid:     impersonation.RefreshUuid,
+		StartedAt:       impersonation.CreatedAt,
+		Expire:          expire,
+	}).Error
+}
+func (gs *gormStorage) GetImpersonations() ([]*gwt.Impersonation, error) {
+	var data []impersonationData
+	if err := gs.adapter.SelectAll(gs.con, &data, "expire > ?", gs.now().Unix()).Error; err != nil {
+		return nil, err
+	}
+	impersonations := make([]*gwt.Impersonation, 0, len(data))
+	for _, item := range data {
+		impersonations = append(impersonations, item.toImpersonation())
+	}
+	return impersonations, nil
+}
+func (gs *gormStorage) DeleteImpersonation(id string) error {
+	return gs.adapter.DeleteUnscoped(gs.con, &impersonationData{ImpersonationId: id}, &impersonationData{}).Error
+}
+func (gs *gormStorage) SaveTicket(ticketHash string, accessToken string, expire int64) error {
+	return gs.adapter.Create(gs.con, &ticketData{TicketHash: ticketHash, AccessToken: accessToken, Expire: expire}).Error
+}
+
+// UseTicket deletes the ticket before returning it, so concurrent requests cannot use it twice
+func (gs *gormStorage) UseTicket(ticketHash string) (string, error) {
+	data := ticketData{}
+	err := gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		if err := gs.adapter.SelectFirst(tx, &ticketData{TicketHash: ticketHash}, &data).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return gwt.ErrTicketNotFound
+			}
+			return err
+		}
+		res := gs.adapter.DeleteUnscoped(tx, &ticketData{TicketHash: ticketHash}, &ticketData{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gwt.ErrTicketNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if data.Expire <= gs.now().Unix() {
+		return "", gwt.ErrTicketNotFound
+	}
+	return data.AccessToken, nil
+}
+
+func (gs *gormStorage) SaveOpaqueToken(tokenHash string, token *gwt.OpaqueToken) error {
+	claims, err := json.Marshal(token.Claims)
+	if err != nil {
+		return err
+	}
+	return gs.adapter.Create(gs.con, &opaqueTokenData{TokenHash: tokenHash, UserId: token.UserId, Uuid: token.Uuid,
+		Claims: string(claims), Expire: token.Expire}).Error
+}
+func (gs *gormStorage) GetOpaqueToken(tokenHash string) (*gwt.OpaqueToken, error) {
+	data := opaqueTokenData{}
+	if err := gs.adapter.SelectFirst(gs.con, &opaqueTokenData{TokenHash: tokenHash}, &data).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gwt.ErrTokenNotFound
+		}
+		return nil, err
+	}
+	if data.Expire <= gs.now().Unix() {
+		return nil, gwt.ErrTokenNotFound
+	}
+	return data.toOpaqueToken()
+}
+func (gs *gormStorage) DeleteOpaqueTokens(uuids ...string) error {
+	var nonEmpty []string
+	for _, uuid := range uuids {
+		if uuid != "" {
+			nonEmpty = append(nonEmpty, uuid)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil
+	}
+	return gs.adapter.DeleteUnscopedWhere(gs.con, &opaqueTokenData{}, "uuid IN ?", nonEmpty).Error
+}
+
+// PurgeExpired deletes rows which have expired by now, they are skipped by reads but kept in tables until purged
+func (gs *gormStorage) PurgeExpired() error {
+	now := gs.now().Unix()
+	return gs.adapter.Transaction(gs.con, func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&tokenData{}, &sessionData{}, &loginAttemptData{}, &mfaTokenData{},
+			&impersonationData{}, &ticketData{}, &opaqueTokenData{}} {
+			if err := gs.adapter.DeleteUnscopedWhere(tx, model, "expire <= ?", now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetClock sets the clock expired rows are skipped by, it is called by gwt.Init with Settings.Clock
+func (gs *gormStorage) SetClock(clock gwt.Clock) {
+	gs.clock = clock
+}
+func (gs *gormStorage) now() time.Time {
+	if gs.clock == nil {
+		return time.Now()
+	}
+	return gs.clock.Now()
+}
 
 func InitGormStorage(con *gorm.DB, tablePrefix string) (gwt.StorageInterface, error) {
 	adapter := &gormAdapter{}
 	viper.Set("token_table_name", tablePrefix+gwtTokensTablePrefix)
-	if err := adapter.AutoMigrate(con, &tokenData{}); err != nil {
+	viper.Set("session_table_name", tablePrefix+gwtSessionsTablePrefix)
+	viper.Set("login_attempt_table_name", tablePrefix+gwtLoginAttemptsTablePrefix)
+	viper.Set("mfa_secret_table_name", tablePrefix+gwtMFASecretsTablePrefix)
+	viper.Set("mfa_recovery_code_table_name", tablePrefix+gwtMFACodesTablePrefix)
+	viper.Set("mfa_token_table_name", tablePrefix+gwtMFATokensTablePrefix)
+	viper.Set("impersonation_table_name", tablePrefix+gwtImpersonationsPrefix)
+	viper.Set("ticket_table_name", tablePrefix+gwtTicketsTablePrefix)
+	viper.Set("opaque_token_table_name", tablePrefix+gwtOpaqueTokensTablePrefix)
+	if err := adapter.AutoMigrate(con, &tokenData{}, &sessionData{}, &loginAttemptData{},
+		&mfaSecretData{}, &mfaRecoveryCodeData{}, &mfaTokenData{}, &impersonationData{}, &ticketData{}, &opaqueTokenData{}); err != nil {
 		return nil, err
 	}
 	return &gormStorage{con: con, adapter: &gormAdapter{}}, nil
@@ -89,3 +468,140 @@ type tokenData struct {
 func (td *tokenData) TableName() string {
 	return viper.Get("token_table_name").(string)
 }
+
+type sessionData struct {
+	gorm.Model
+	SessionId   string `gorm:"type:string;not null;unique;index" valid:"required"`
+	UserId      string `gorm:"type:string;not null;index" valid:"required"`
+	AccessUuid  string `gorm:"type:string;not null" valid:"required"`
+	RefreshUuid string `gorm:"type:string;not null" valid:"required"`
+	StartedAt   int64  `gorm:"not null;" valid:"required"`
+	ExpiresAt   int64  `gorm:"not null;"`
+	Metadata    string `gorm:"type:string"`
+	Expire      int64  `gorm:"not null;" valid:"required"`
+}
+
+func (sd *sessionData) TableName() string {
+	return viper.Get("session_table_name").(string)
+}
+
+func (sd *sessionData) toSession() (*gwt.Session, error) {
+	session := &gwt.Session{
+		Id:          sd.SessionId,
+		UserId:      sd.UserId,
+		AccessUuid:  sd.AccessUuid,
+		RefreshUuid: sd.RefreshUuid,
+		CreatedAt:   sd.StartedAt,
+		ExpiresAt:   sd.ExpiresAt,
+	}
+	if sd.Metadata != "" {
+		if err := json.Unmarshal([]byte(sd.Metadata), &session.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+type loginAttemptData struct {
+	gorm.Model
+	Key    string `gorm:"type:string;not null;unique;index" valid:"required"`
+	Count  int64  `gorm:"not null;" valid:"required"`
+	Last   int64  `gorm:"not null;" valid:"required"`
+	Expire int64  `gorm:"not null;" valid:"required"`
+}
+
+func (lad *loginAttemptData) TableName() string {
+	return viper.Get("login_attempt_table_name").(string)
+}
+
+type mfaSecretData struct {
+	gorm.Model
+	UserId   string `gorm:"type:string;not null;unique;index" valid:"required"`
+	Secret   string `gorm:"type:string;not null" valid:"required"`
+	LastStep int64  `gorm:"not null;default:0"`
+}
+
+func (msd *mfaSecretData) TableName() string {
+	return viper.Get("mfa_secret_table_name").(string)
+}
+
+type mfaRecoveryCodeData struct {
+	gorm.Model
+	UserId string `gorm:"type:string;not null;index" valid:"required"`
+	Code   string `gorm:"type:string;not null" valid:"required"`
+}
+
+func (mrcd *mfaRecoveryCodeData) TableName() string {
+	return viper.Get("mfa_recovery_code_table_name").(string)
+}
+
+type mfaTokenData struct {
+	gorm.Model
+	TokenId string `gorm:"type:string;not null;unique;index" valid:"required"`
+	Expire  int64  `gorm:"not null;index" valid:"required"`
+}
+
+func (mtd *mfaTokenData) TableName() string {
+	return viper.Get("mfa_token_table_name").(string)
+}
+
+type impersonationData struct {
+	gorm.Model
+	ImpersonationId string `gorm:"type:string;not null;unique;index" valid:"required"`
+	ActorId         string `gorm:"type:string;not null;index" valid:"required"`
+	UserId          string `gorm:"type:string;not null;index" valid:"required"`
+	Reason          string `gorm:"type:string;not null" valid:"required"`
+	AccessUuid      string `gorm:"type:string;not null" valid:"required"`
+	RefreshUuid     string `gorm:"type:string;not null" valid:"required"`
+	StartedAt       int64  `gorm:"not null;" valid:"required"`
+	Expire          int64  `gorm:"not null;index" valid:"required"`
+}
+
+func (imd *impersonationData) TableName() string {
+	return viper.Get("impersonation_table_name").(string)
+}
+
+func (imd *impersonationData) toImpersonation() *gwt.Impersonation {
+	return &gwt.Impersonation{
+		Id:          imd.ImpersonationId,
+		ActorId:     imd.ActorId,
+		UserId:      imd.UserId,
+		Reason:      imd.Reason,
+		AccessUuid:  imd.AccessUuid,
+		RefreshUuid: imd.RefreshUuid,
+		CreatedAt:   imd.StartedAt,
+		ExpiresAt:   imd.Expire,
+	}
+}
+
+type ticketData struct {
+	gorm.Model
+	TicketHash  string `gorm:"type:string;not null;unique;index" valid:"required"`
+	AccessToken string `gorm:"type:string;not null" valid:"required"`
+	Expire      int64  `gorm:"not null;index" valid:"required"`
+}
+
+func (tkd *ticketData) TableName() string {
+	return viper.Get("ticket_table_name").(string)
+}
+
+type opaqueTokenData struct {
+	gorm.Model
+	TokenHash string `gorm:"type:string;not null;unique;index" valid:"required"`
+	UserId    string `gorm:"type:string;not null;index" valid:"required"`
+	Uuid      string `gorm:"type:string;index"`
+	Claims    string `gorm:"type:string;not null" valid:"required"`
+	Expire    int64  `gorm:"not null;index" valid:"required"`
+}
+
+func (otd *opaqueTokenData) TableName() string {
+	return viper.Get("opaque_token_table_name").(string)
+}
+
+func (otd *opaqueTokenData) toOpaqueToken() (*gwt.OpaqueToken, error) {
+	token := &gwt.OpaqueToken{UserId: otd.UserId, Uuid: otd.Uuid, Expire: otd.Expire}
+	if err := json.Unmarshal([]byte(otd.Claims), &token.Claims); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
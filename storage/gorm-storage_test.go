@@ -2,11 +2,13 @@ package storage
 
 import (
 	"errors"
+	"github.com/ennaque/go-gin-jwt"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
 	"testing"
+	"time"
 )
 
 func TestDeleteTokensSuccess(t *testing.T) {
@@ -41,6 +43,22 @@ func TestSaveTokensSuccess(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestDeleteTokensSkipsEmptyUuid(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.DeleteTokens("1", ""))
+	adapterMock.AssertNotCalled(t, "DeleteUnscoped")
+}
+
+func TestSaveAccessTokenSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("Create", mock.Anything).Return("")
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.SaveAccessToken("client:worker", "auuid", 123, "atoken"))
+}
+
 func TestSaveTokensAccessError(t *testing.T) {
 	adapterMock := gormAdapterMock{}
 	adapterMock.On("Transaction", mock.Anything).Return(nil)
@@ -150,3 +168,446 @@ func TestGormTableName(t *testing.T) {
 
 	assert.Equal(t, "name", td.TableName())
 }
+
+func TestAddLoginAttemptSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("SelectFirst", mock.Anything).Return(&gorm.DB{})
+	adapterMock.On("Save", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	count, err := gormSt.AddLoginAttempt("ip:127.0.0.1", time.Now().Add(time.Minute).Unix())
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestAddLoginAttemptNotFound(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = gorm.ErrRecordNotFound
+	adapterMock.On("SelectFirst", mock.Anything).Return(ret)
+	adapterMock.On("Save", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	count, err := gormSt.AddLoginAttempt("ip:127.0.0.1", time.Now().Add(time.Minute).Unix())
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestAddLoginAttemptSaveError(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = errors.New("save error")
+	adapterMock.On("SelectFirst", mock.Anything).Return(&gorm.DB{})
+	adapterMock.On("Save", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.AddLoginAttempt("ip:127.0.0.1", time.Now().Add(time.Minute).Unix())
+
+	assert.Error(t, err)
+	assert.Equal(t, "save error", err.Error())
+}
+
+func TestGetLoginAttemptsNotFound(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = gorm.ErrRecordNotFound
+	adapterMock.On("SelectFirst", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	count, last, err := gormSt.GetLoginAttempts("ip:127.0.0.1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, int64(0), last)
+}
+
+func TestGetLoginAttemptsError(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = errors.New("err")
+	adapterMock.On("SelectFirst", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, _, err := gormSt.GetLoginAttempts("ip:127.0.0.1")
+
+	assert.Error(t, err)
+}
+
+func TestLoginAttemptTableName(t *testing.T) {
+	viper.Set("login_attempt_table_name", "name")
+	lad := &loginAttemptData{}
+
+	assert.Equal(t, "name", lad.TableName())
+}
+
+func TestSaveMFASecretSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{})
+	adapterMock.On("Create", mock.Anything).Return(nil)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.SaveMFASecret("1", "secret", []string{"code"}))
+}
+
+func TestGetMFASecretNotEnrolled(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = gorm.ErrRecordNotFound
+	adapterMock.On("SelectFirst", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetMFASecret("1")
+
+	assert.Equal(t, gwt.ErrMFANotEnrolled, err)
+}
+
+func TestUseMFARecoveryCodeSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{RowsAffected: 1})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.UseMFARecoveryCode("1", "code"))
+}
+
+func TestUseMFARecoveryCodeInvalid(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Equal(t, gwt.ErrInvalidMFACode, gormSt.UseMFARecoveryCode("1", "code"))
+}
+
+func TestDeleteMFASecretSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.DeleteMFASecret("1"))
+}
+
+func TestMFAEmptyUserIdRejected(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetMFASecret("")
+
+	assert.Equal(t, gwt.ErrMFANotEnrolled, err)
+	assert.Equal(t, gwt.ErrInvalidMFACode, gormSt.UseMFARecoveryCode("", "code"))
+	assert.Equal(t, gwt.ErrUserIdIsNotProvided, gormSt.SaveMFASecret("", "secret", nil))
+	assert.Equal(t, gwt.ErrUserIdIsNotProvided, gormSt.DeleteMFASecret(""))
+	adapterMock.AssertNotCalled(t, "SelectFirst")
+	adapterMock.AssertNotCalled(t, "DeleteUnscoped")
+}
+
+func TestUseMFAStep(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("UpdateWhere", mock.Anything).Return(&gorm.DB{RowsAffected: 1}).Once()
+	adapterMock.On("UpdateWhere", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.UseMFAStep("1", 100))
+	assert.Equal(t, gwt.ErrInvalidMFACode, gormSt.UseMFAStep("1", 100))
+}
+
+func TestUseMFAToken(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("Create", mock.Anything).Return(nil)
+	adapterMock.On("DeleteUnscopedWhere", mock.Anything).Return(&gorm.DB{RowsAffected: 1}).Once()
+	adapterMock.On("DeleteUnscopedWhere", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.SaveMFAToken("id", 123))
+	assert.Nil(t, gormSt.UseMFAToken("id"))
+	assert.Equal(t, gwt.ErrMFATokenNotFound, gormSt.UseMFAToken("id"))
+}
+
+func TestSessionEmptyIdRejected(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetSession("1", "")
+
+	assert.Equal(t, gwt.ErrSessionNotFound, err)
+	_, err = gormSt.GetSession("", "sid")
+	assert.Equal(t, gwt.ErrSessionNotFound, err)
+	assert.Nil(t, gormSt.DeleteSession("1", ""))
+	adapterMock.AssertNotCalled(t, "SelectFirst")
+	adapterMock.AssertNotCalled(t, "DeleteUnscoped")
+}
+
+func TestSaveSessionSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscopedWhere", mock.Anything).Return(&gorm.DB{})
+	adapterMock.On("Create", mock.Anything).Return(nil)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.SaveSession(&gwt.Session{Id: "sid", UserId: "1"}, 123))
+}
+
+func TestGetSessionNotFound(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = gorm.ErrRecordNotFound
+	adapterMock.On("SelectFirst", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetSession("1", "sid")
+
+	assert.Equal(t, gwt.ErrSessionNotFound, err)
+}
+
+func TestGetSessionExpired(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("SelectFirst", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetSession("1", "sid")
+
+	assert.Equal(t, gwt.ErrSessionNotFound, err)
+}
+
+func TestGetSessionsSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("SelectAll", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	sessions, err := gormSt.GetSessions("1")
+
+	assert.Nil(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestGetSessionsError(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = errors.New("err")
+	adapterMock.On("SelectAll", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetSessions("1")
+
+	assert.Error(t, err)
+}
+
+func TestDeleteSessionSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.DeleteSession("1", "sid"))
+}
+
+func TestSessionTableName(t *testing.T) {
+	viper.Set("session_table_name", "name")
+	sd := &sessionData{}
+
+	assert.Equal(t, "name", sd.TableName())
+}
+
+func TestSessionDataToSession(t *testing.T) {
+	sd := &sessionData{SessionId: "sid", UserId: "1", StartedAt: 100, Metadata: `{"device":"kiosk"}`}
+	session, err := sd.toSession()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "sid", session.Id)
+	assert.Equal(t, int64(100), session.CreatedAt)
+	assert.Equal(t, "kiosk", session.Metadata["device"])
+}
+
+func TestSaveImpersonationSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("Create", mock.Anything).Return(nil)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.SaveImpersonation(&gwt.Impersonation{Id: "sid", ActorId: "admin", UserId: "1"}, 123))
+}
+
+func TestGetImpersonationsError(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = errors.New("err")
+	adapterMock.On("SelectAll", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetImpersonations()
+
+	assert.Error(t, err)
+}
+
+func TestDeleteImpersonationSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.DeleteImpersonation("sid"))
+}
+
+func TestImpersonationDataToImpersonation(t *testing.T) {
+	viper.Set("impersonation_table_name", "name")
+	imd := &impersonationData{ImpersonationId: "sid", ActorId: "admin", UserId: "1", Reason: "ticket 1",
+		AccessUuid: "access", RefreshUuid: "refresh", StartedAt: 100, Expire: 200}
+
+	assert.Equal(t, "name", imd.TableName())
+	assert.Equal(t, &gwt.Impersonation{Id: "sid", ActorId: "admin", UserId: "1", Reason: "ticket 1",
+		AccessUuid: "access", RefreshUuid: "refresh", CreatedAt: 100, ExpiresAt: 200}, imd.toImpersonation())
+}
+
+func TestDeleteAllSessionsSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{RowsAffected: 2})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	removed, err := gormSt.DeleteAllSessions("1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), removed)
+}
+
+func TestDeleteAllSessionsError(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = errors.New("err")
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.DeleteAllSessions("1")
+
+	assert.Error(t, err)
+}
+
+func TestDeleteAllTokensExceptSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscopedWhere", mock.Anything).Return(&gorm.DB{RowsAffected: 1})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	removed, err := gormSt.DeleteAllTokensExcept("1", "access", "refresh")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), removed)
+}
+
+func TestDeleteAllTokensExceptError(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = errors.New("err")
+	adapterMock.On("DeleteUnscopedWhere", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.DeleteAllTokensExcept("1", "access", "refresh")
+
+	assert.Error(t, err)
+}
+
+func TestSaveTicketSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("Create", mock.Anything).Return(nil)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.SaveTicket("hash", "token", 123))
+}
+
+func TestUseTicketNotFound(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = gorm.ErrRecordNotFound
+	adapterMock.On("SelectFirst", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.UseTicket("hash")
+
+	assert.Equal(t, gwt.ErrTicketNotFound, err)
+	adapterMock.AssertNotCalled(t, "DeleteUnscoped")
+}
+
+func TestUseTicketUsedConcurrently(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("SelectFirst", mock.Anything).Return(&gorm.DB{})
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.UseTicket("hash")
+
+	assert.Equal(t, gwt.ErrTicketNotFound, err)
+}
+
+func TestUseTicketExpired(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("SelectFirst", mock.Anything).Return(&gorm.DB{})
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{RowsAffected: 1})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.UseTicket("hash")
+
+	assert.Equal(t, gwt.ErrTicketNotFound, err)
+}
+
+func TestUseTicketExpiryUsesClock(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("SelectFirst", mock.Anything).Return(&gorm.DB{})
+	adapterMock.On("DeleteUnscoped", mock.Anything).Return(&gorm.DB{RowsAffected: 1})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	gormSt.SetClock(&fixedClock{now: time.Unix(-1, 0)})
+	_, err := gormSt.UseTicket("hash")
+
+	assert.Nil(t, err)
+}
+
+func TestGetSessionExpiryUsesClock(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("SelectFirst", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	gormSt.SetClock(&fixedClock{now: time.Unix(-1, 0)})
+	session, err := gormSt.GetSession("1", "sid")
+
+	assert.Nil(t, err)
+	assert.NotNil(t, session)
+}
+
+func TestPurgeExpiredSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscopedWhere", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.PurgeExpired())
+	adapterMock.AssertNumberOfCalls(t, "DeleteUnscopedWhere", 7)
+}
+
+func TestPurgeExpiredError(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscopedWhere", mock.Anything).Return(&gorm.DB{Error: errors.New("delete error")})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.EqualError(t, gormSt.PurgeExpired(), "delete error")
+	adapterMock.AssertNumberOfCalls(t, "DeleteUnscopedWhere", 1)
+}
+
+func TestSaveOpaqueTokenSuccess(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("Create", mock.Anything).Return(nil)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.SaveOpaqueToken("hash", &gwt.OpaqueToken{UserId: "1", Expire: 123}))
+}
+
+func TestGetOpaqueTokenNotFound(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	ret := &gorm.DB{}
+	ret.Error = gorm.ErrRecordNotFound
+	adapterMock.On("SelectFirst", mock.Anything).Return(ret)
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetOpaqueToken("hash")
+
+	assert.Equal(t, gwt.ErrTokenNotFound, err)
+}
+
+func TestGetOpaqueTokenExpired(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("SelectFirst", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+	_, err := gormSt.GetOpaqueToken("hash")
+
+	assert.Equal(t, gwt.ErrTokenNotFound, err)
+}
+
+func TestOpaqueTokenDataToOpaqueToken(t *testing.T) {
+	data := &opaqueTokenData{TokenHash: "hash", UserId: "1", Uuid: "access", Claims: `{"tenant":"acme"}`, Expire: 100}
+	token, err := data.toOpaqueToken()
+
+	assert.Nil(t, err)
+	assert.Equal(t, &gwt.OpaqueToken{UserId: "1", Uuid: "access", Expire: 100,
+		Claims: map[string]interface{}{"tenant": "acme"}}, token)
+}
+
+func TestDeleteOpaqueTokens(t *testing.T) {
+	adapterMock := gormAdapterMock{}
+	adapterMock.On("DeleteUnscopedWhere", mock.Anything).Return(&gorm.DB{})
+	gormSt := &gormStorage{con: &gorm.DB{}, adapter: &adapterMock}
+
+	assert.Nil(t, gormSt.DeleteOpaqueTokens("access", "refresh"))
+	adapterMock.AssertNumberOfCalls(t, "DeleteUnscopedWhere", 1)
+
+	assert.Nil(t, gormSt.DeleteOpaqueTokens(""))
+	adapterMock.AssertNumberOfCalls(t, "DeleteUnscopedWhere", 1)
+}
@@ -4,19 +4,31 @@ import (
 	"context"
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
+	"time"
 )
 
 type gormAdapterInterface interface {
 	Transaction(db *gorm.DB, fc func(tx *gorm.DB) error) error
 	DeleteUnscoped(db *gorm.DB, query interface{}, model interface{}) *gorm.DB
+	DeleteUnscopedWhere(db *gorm.DB, model interface{}, query interface{}, args ...interface{}) *gorm.DB
 	Create(db *gorm.DB, value interface{}) *gorm.DB
 	SelectFirst(db *gorm.DB, query interface{}, destination interface{}) *gorm.DB
+	SelectAll(db *gorm.DB, destination interface{}, query interface{}, args ...interface{}) *gorm.DB
 	AutoMigrate(db *gorm.DB, dst ...interface{}) error
+	Save(db *gorm.DB, value interface{}) *gorm.DB
+	UpdateWhere(db *gorm.DB, model interface{}, column string, value interface{}, query interface{},
+		args ...interface{}) *gorm.DB
 }
 
 type redisAdapterInterface interface {
 	Del(ctx context.Context, keys ...string) error
 	SaveMultipleInPipe(ctx context.Context, values ...redisValue) ([]redis.Cmder, error)
 	Get(ctx context.Context, key string) (string, error)
+	GetDel(ctx context.Context, key string) (string, error)
 	GetScanIterator(ctx context.Context, cursor uint64, match string, count int64) redisIteratorInterface
+	HIncrAndSet(ctx context.Context, key string, incrField string, values map[string]interface{}, expiration time.Duration) (int64, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	ReplaceSet(ctx context.Context, key string, members ...string) error
+	SRem(ctx context.Context, key string, member string) (int64, error)
+	SetIfGreater(ctx context.Context, key string, value int64) (bool, error)
 }
@@ -2,17 +2,29 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/ennaque/go-gin-jwt"
 	"github.com/go-redis/redis/v8"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type RedisStorage struct {
 	adapter redisAdapterInterface
+	clock   gwt.Clock
+}
+
+// redisImpersonation keeps token uuids which are not serialized with gwt.Impersonation
+type redisImpersonation struct {
+	*gwt.Impersonation
+	AccessUuid  string `json:"access_uuid"`
+	RefreshUuid string `json:"refresh_uuid"`
 }
 
 func (rs *RedisStorage) DeleteTokens(userId string, uuid ...string) error {
-	if err := rs.adapter.Del(context.Background(), rs._getStorageKeys(userId, uuid...)...); err != nil {
+	keys := append(rs._getStorageKeys("a"+userId, uuid...), rs._getStorageKeys("r"+userId, uuid...)...)
+	if err := rs.adapter.Del(context.Background(), keys...); err != nil {
 		return err
 	}
 	return nil
@@ -29,9 +41,9 @@ func (rs *RedisStorage) SaveTokens(
 	_, err := rs.adapter.SaveMultipleInPipe(
 		context.Background(),
 		redisValue{key: rs._getStorageKey("a"+userId, accessUuid), value: accessToken,
-			expiration: time.Unix(accessExpire, 0).Sub(time.Now())},
+			expiration: time.Unix(accessExpire, 0).Sub(rs._now())},
 		redisValue{key: rs._getStorageKey("r"+userId, refreshUuid), value: refreshToken,
-			expiration: time.Unix(refreshExpire, 0).Sub(time.Now())},
+			expiration: time.Unix(refreshExpire, 0).Sub(rs._now())},
 	)
 	if err != nil {
 		return gwt.ErrCannotSaveToken
@@ -39,12 +51,22 @@ func (rs *RedisStorage) SaveTokens(
 	return nil
 }
 
+func (rs *RedisStorage) SaveAccessToken(userId string, accessUuid string, accessExpire int64, accessToken string) error {
+	_, err := rs.adapter.SaveMultipleInPipe(context.Background(),
+		redisValue{key: rs._getStorageKey("a"+userId, accessUuid), value: accessToken,
+			expiration: time.Unix(accessExpire, 0).Sub(rs._now())})
+	if err != nil {
+		return gwt.ErrCannotSaveToken
+	}
+	return nil
+}
+
 func (rs *RedisStorage) HasRefreshToken(uuid string, token string, userId string) error {
-	return rs._isExpired("a"+rs._getStorageKey(userId, uuid), token)
+	return rs._isExpired("r"+rs._getStorageKey(userId, uuid), token)
 }
 
 func (rs *RedisStorage) HasAccessToken(uuid string, token string, userId string) error {
-	return rs._isExpired("r"+rs._getStorageKey(userId, uuid), token)
+	return rs._isExpired("a"+rs._getStorageKey(userId, uuid), token)
 }
 
 func (rs *RedisStorage) DeleteAllTokens(userId string) error {
@@ -55,6 +77,316 @@ func (rs *RedisStorage) DeleteAllTokens(userId string) error {
 	return rs.adapter.Del(context.Background(), userIdUuidKeys...)
 }
 
+// DeleteAllSessions deletes all keys of the user, every refresh token key is counted as a session
+func (rs *RedisStorage) DeleteAllSessions(userId string) (int64, error) {
+	userIdUuidKeys := rs._getUserIdUuidStorageKeys(userId)
+	if len(userIdUuidKeys) == 0 {
+		return 0, nil
+	}
+	var removed int64
+	for _, key := range userIdUuidKeys {
+		if strings.HasPrefix(key, "r") {
+			removed++
+		}
+	}
+	if err := rs.adapter.Del(context.Background(), userIdUuidKeys...); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// DeleteAllTokensExcept deletes all keys of the user except keys of the token pair and the session it belongs to
+func (rs *RedisStorage) DeleteAllTokensExcept(userId string, accessUuid string, refreshUuid string) (int64, error) {
+	keep := map[string]bool{
+		rs._getStorageKey("a"+userId, accessUuid):  true,
+		rs._getStorageKey("r"+userId, refreshUuid): true,
+	}
+	var keysToDelete []string
+	var removed int64
+	for _, key := range rs._getUserIdUuidStorageKeys(userId) {
+		if keep[key] {
+			continue
+		}
+		if strings.HasPrefix(key, "s") {
+			if value, err := rs.adapter.Get(context.Background(), key); err == nil {
+				session := &gwt.Session{}
+				if json.Unmarshal([]byte(value), session) == nil && session.RefreshUuid == refreshUuid {
+					continue
+				}
+			}
+		}
+		if strings.HasPrefix(key, "r") {
+			removed++
+		}
+		keysToDelete = append(keysToDelete, key)
+	}
+	if len(keysToDelete) == 0 {
+		return 0, nil
+	}
+	if err := rs.adapter.Del(context.Background(), keysToDelete...); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+func (rs *RedisStorage) SaveSession(session *gwt.Session, expire int64) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = rs.adapter.SaveMultipleInPipe(context.Background(),
+		redisValue{key: rs._getStorageKey("s"+session.UserId, session.Id), value: value,
+			expiration: time.Unix(expire, 0).Sub(rs._now())})
+	return err
+}
+
+func (rs *RedisStorage) GetSession(userId string, sessionId string) (*gwt.Session, error) {
+	value, err := rs.adapter.Get(context.Background(), rs._getStorageKey("s"+userId, sessionId))
+	if err == redis.Nil {
+		return nil, gwt.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := &gwt.Session{}
+	if unmarshalErr := json.Unmarshal([]byte(value), session); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return session, nil
+}
+
+// GetSessions scans session keys of the user, keys expire with the sessions
+func (rs *RedisStorage) GetSessions(userId string) ([]*gwt.Session, error) {
+	sessions := []*gwt.Session{}
+	pattern := rs._getStorageKey("s"+rs._escapeGlob(userId), "*")
+	iter := rs.adapter.GetScanIterator(context.Background(), 0, pattern, 0)
+	for iter.Next(context.Background()) {
+		value, err := rs.adapter.Get(context.Background(), iter.Val())
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		session := &gwt.Session{}
+		if unmarshalErr := json.Unmarshal([]byte(value), session); unmarshalErr != nil {
+			return nil, unmarshalErr
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, iter.Err()
+}
+
+func (rs *RedisStorage) DeleteSession(userId string, sessionId string) error {
+	return rs.adapter.Del(context.Background(), rs._getStorageKey("s"+userId, sessionId))
+}
+
+func (rs *RedisStorage) AddLoginAttempt(key string, expire int64) (int64, error) {
+	return rs.adapter.HIncrAndSet(context.Background(), rs._getLoginAttemptKey(key), "count",
+		map[string]interface{}{"last": rs._now().Unix()}, time.Unix(expire, 0).Sub(rs._now()))
+}
+
+func (rs *RedisStorage) GetLoginAttempts(key string) (int64, int64, error) {
+	values, err := rs.adapter.HGetAll(context.Background(), rs._getLoginAttemptKey(key))
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(values) == 0 {
+		return 0, 0, nil
+	}
+	count, countErr := strconv.ParseInt(values["count"], 10, 64)
+	if countErr != nil {
+		return 0, 0, countErr
+	}
+	last, lastErr := strconv.ParseInt(values["last"], 10, 64)
+	if lastErr != nil {
+		return 0, 0, lastErr
+	}
+	return count, last, nil
+}
+
+func (rs *RedisStorage) DeleteLoginAttempts(key string) error {
+	return rs.adapter.Del(context.Background(), rs._getLoginAttemptKey(key))
+}
+
+func (rs *RedisStorage) SaveMFASecret(userId string, secret string, recoveryCodes []string) error {
+	if _, err := rs.adapter.SaveMultipleInPipe(context.Background(),
+		redisValue{key: rs._getMFASecretKey(userId), value: secret}); err != nil {
+		return err
+	}
+	return rs.adapter.ReplaceSet(context.Background(), rs._getMFARecoveryCodesKey(userId), recoveryCodes...)
+}
+
+func (rs *RedisStorage) GetMFASecret(userId string) (string, error) {
+	secret, err := rs.adapter.Get(context.Background(), rs._getMFASecretKey(userId))
+	if err == redis.Nil {
+		return "", gwt.ErrMFANotEnrolled
+	}
+	return secret, err
+}
+
+func (rs *RedisStorage) UseMFARecoveryCode(userId string, recoveryCode string) error {
+	removed, err := rs.adapter.SRem(context.Background(), rs._getMFARecoveryCodesKey(userId), recoveryCode)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return gwt.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (rs *RedisStorage) DeleteMFASecret(userId string) error {
+	return rs.adapter.Del(context.Background(), rs._getMFASecretKey(userId), rs._getMFARecoveryCodesKey(userId),
+		rs._getMFAStepKey(userId))
+}
+
+// UseMFAStep compares and saves the step in one script, so concurrent requests cannot use a code twice
+func (rs *RedisStorage) UseMFAStep(userId string, step int64) error {
+	saved, err := rs.adapter.SetIfGreater(context.Background(), rs._getMFAStepKey(userId), step)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return gwt.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (rs *RedisStorage) SaveMFAToken(tokenId string, expire int64) error {
+	_, err := rs.adapter.SaveMultipleInPipe(context.Background(),
+		redisValue{key: rs._getMFATokenKey(tokenId), value: expire, expiration: time.Unix(expire, 0).Sub(rs._now())})
+	return err
+}
+
+// UseMFAToken gets and deletes the token id in one transaction, so it is accepted once
+func (rs *RedisStorage) UseMFAToken(tokenId string) error {
+	_, err := rs.adapter.GetDel(context.Background(), rs._getMFATokenKey(tokenId))
+	if err == redis.Nil {
+		return gwt.ErrMFATokenNotFound
+	}
+	return err
+}
+
+func (rs *RedisStorage) SaveImpersonation(impersonation *gwt.Impersonation, expire int64) error {
+	value, err := json.Marshal(redisImpersonation{Impersonation: impersonation, AccessUuid: impersonation.AccessUuid,
+		RefreshUuid: impersonation.RefreshUuid})
+	if err != nil {
+		return err
+	}
+	_, err = rs.adapter.SaveMultipleInPipe(context.Background(),
+		redisValue{key: rs._getImpersonationKey(impersonation.Id), value: value,
+			expiration: time.Unix(expire, 0).Sub(rs._now())})
+	return err
+}
+
+func (rs *RedisStorage) GetImpersonations() ([]*gwt.Impersonation, error) {
+	var impersonations []*gwt.Impersonation
+	iter := rs.adapter.GetScanIterator(context.Background(), 0, rs._getImpersonationKey("*"), 0)
+	for iter.Next(context.Background()) {
+		value, err := rs.adapter.Get(context.Background(), iter.Val())
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data := redisImpersonation{Impersonation: &gwt.Impersonation{}}
+		if unmarshalErr := json.Unmarshal([]byte(value), &data); unmarshalErr != nil {
+			return nil, unmarshalErr
+		}
+		data.Impersonation.AccessUuid, data.Impersonation.RefreshUuid = data.AccessUuid, data.RefreshUuid
+		impersonations = append(impersonations, data.Impersonation)
+	}
+	return impersonations, iter.Err()
+}
+
+func (rs *RedisStorage) DeleteImpersonation(id string) error {
+	return rs.adapter.Del(context.Background(), rs._getImpersonationKey(id))
+}
+
+func (rs *RedisStorage) SaveTicket(ticketHash string, accessToken string, expire int64) error {
+	_, err := rs.adapter.SaveMultipleInPipe(context.Background(),
+		redisValue{key: rs._getTicketKey(ticketHash), value: accessToken, expiration: time.Unix(expire, 0).Sub(rs._now())})
+	return err
+}
+
+// UseTicket gets and deletes the ticket in one transaction, so it is accepted once
+func (rs *RedisStorage) UseTicket(ticketHash string) (string, error) {
+	accessToken, err := rs.adapter.GetDel(context.Background(), rs._getTicketKey(ticketHash))
+	if err == redis.Nil {
+		return "", gwt.ErrTicketNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return accessToken, nil
+}
+
+func (rs *RedisStorage) SaveOpaqueToken(tokenHash string, token *gwt.OpaqueToken) error {
+	value, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	expiration := time.Unix(token.Expire, 0).Sub(rs._now())
+	values := []redisValue{{key: rs._getOpaqueTokenKey(tokenHash), value: value, expiration: expiration}}
+	if token.Uuid != "" {
+		values = append(values, redisValue{key: rs._getOpaqueUuidKey(token.Uuid), value: tokenHash, expiration: expiration})
+	}
+	_, err = rs.adapter.SaveMultipleInPipe(context.Background(), values...)
+	return err
+}
+
+func (rs *RedisStorage) GetOpaqueToken(tokenHash string) (*gwt.OpaqueToken, error) {
+	value, err := rs.adapter.Get(context.Background(), rs._getOpaqueTokenKey(tokenHash))
+	if err == redis.Nil {
+		return nil, gwt.ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	token := &gwt.OpaqueToken{}
+	if unmarshalErr := json.Unmarshal([]byte(value), token); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return token, nil
+}
+
+// DeleteOpaqueTokens finds token hashes by uuid keys and deletes both
+func (rs *RedisStorage) DeleteOpaqueTokens(uuids ...string) error {
+	ctx := context.Background()
+	var keys []string
+	for _, uuid := range uuids {
+		if uuid == "" {
+			continue
+		}
+		tokenHash, err := rs.adapter.GetDel(ctx, rs._getOpaqueUuidKey(uuid))
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		keys = append(keys, rs._getOpaqueTokenKey(tokenHash))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return rs.adapter.Del(ctx, keys...)
+}
+
+// SetClock sets the clock expirations are computed from, it is called by gwt.Init with Settings.Clock
+func (rs *RedisStorage) SetClock(clock gwt.Clock) {
+	rs.clock = clock
+}
+
+func (rs *RedisStorage) _now() time.Time {
+	if rs.clock == nil {
+		return time.Now()
+	}
+	return rs.clock.Now()
+}
+
 func (rs *RedisStorage) _isExpired(key string, token string) error {
 	tkn, err := rs.adapter.Get(context.Background(), key)
 	if err != nil {
@@ -68,13 +400,25 @@ func (rs *RedisStorage) _isExpired(key string, token string) error {
 
 func (rs *RedisStorage) _getUserIdUuidStorageKeys(userId string) []string {
 	var keysToDelete []string
-	iter := rs.adapter.GetScanIterator(context.Background(), 0, "[ar]"+userId+"_*", 0)
+	iter := rs.adapter.GetScanIterator(context.Background(), 0, "[ars]"+rs._escapeGlob(userId)+"_*", 0)
 	for iter.Next(context.Background()) {
 		keysToDelete = append(keysToDelete, iter.Val())
 	}
 	return keysToDelete
 }
 
+// _escapeGlob escapes special characters of SCAN pattern, so user id matches literally
+func (rs *RedisStorage) _escapeGlob(value string) string {
+	sb := strings.Builder{}
+	for _, r := range value {
+		if strings.ContainsRune(`*?[]\`, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
 func (rs *RedisStorage) _getStorageKeys(userId string, uuids ...string) []string {
 	var keys []string
 	for _, key := range uuids {
@@ -87,6 +431,42 @@ func (rs *RedisStorage) _getStorageKey(userId string, uuid string) string {
 	return userId + "_" + uuid
 }
 
+func (rs *RedisStorage) _getLoginAttemptKey(key string) string {
+	return "l_" + key
+}
+
+func (rs *RedisStorage) _getMFASecretKey(userId string) string {
+	return "m_" + userId
+}
+
+func (rs *RedisStorage) _getMFARecoveryCodesKey(userId string) string {
+	return "mc_" + userId
+}
+
+func (rs *RedisStorage) _getMFAStepKey(userId string) string {
+	return "ms_" + userId
+}
+
+func (rs *RedisStorage) _getMFATokenKey(tokenId string) string {
+	return "mt_" + tokenId
+}
+
+func (rs *RedisStorage) _getImpersonationKey(id string) string {
+	return "i_" + id
+}
+
+func (rs *RedisStorage) _getTicketKey(ticketHash string) string {
+	return "t_" + ticketHash
+}
+
+func (rs *RedisStorage) _getOpaqueTokenKey(tokenHash string) string {
+	return "o_" + tokenHash
+}
+
+func (rs *RedisStorage) _getOpaqueUuidKey(uuid string) string {
+	return "ou_" + uuid
+}
+
 func InitRedisStorage(client *redis.Client) gwt.StorageInterface {
 	return &RedisStorage{adapter: &redisAdapter{con: client}}
 }
@@ -2,9 +2,12 @@ package storage
 
 import (
 	"errors"
+	"github.com/ennaque/go-gin-jwt"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 func TestRedisDeleteTokensSuccess(t *testing.T) {
@@ -46,6 +49,14 @@ func TestRedisSaveTokenError(t *testing.T) {
 	assert.Equal(t, "failed to save token from storage", err.Error())
 }
 
+func TestRedisClock(t *testing.T) {
+	redisSt := &RedisStorage{adapter: &redisAdapterMock{}}
+	assert.WithinDuration(t, time.Now(), redisSt._now(), time.Second)
+
+	redisSt.SetClock(&fixedClock{now: time.Unix(1000, 0)})
+	assert.Equal(t, time.Unix(1000, 0), redisSt._now())
+}
+
 func TestIsExpiredSuccess(t *testing.T) {
 	mockSt := &redisAdapterMock{}
 	mockSt.On("Get", mock.Anything).Return("token", nil)
@@ -139,3 +150,337 @@ func TestRedisDeleteAllTokenNoAuthUserError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "user is not authenticated", err.Error())
 }
+
+func TestRedisAddLoginAttemptSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("HIncrAndSet", mock.Anything).Return(int64(2), nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	count, err := redisSt.AddLoginAttempt("ip:127.0.0.1", 123)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestRedisGetLoginAttemptsSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("HGetAll", mock.Anything).Return(map[string]string{"count": "3", "last": "100"}, nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	count, last, err := redisSt.GetLoginAttempts("ip:127.0.0.1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, int64(100), last)
+}
+
+func TestRedisGetLoginAttemptsEmpty(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("HGetAll", mock.Anything).Return(map[string]string{}, nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	count, last, err := redisSt.GetLoginAttempts("ip:127.0.0.1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, int64(0), last)
+}
+
+func TestRedisGetLoginAttemptsError(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("HGetAll", mock.Anything).Return(map[string]string{}, errors.New("get error"))
+	redisSt := &RedisStorage{adapter: adapterMock}
+	_, _, err := redisSt.GetLoginAttempts("ip:127.0.0.1")
+
+	assert.Error(t, err)
+	assert.Equal(t, "get error", err.Error())
+}
+
+func TestRedisDeleteLoginAttemptsSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("Del", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.DeleteLoginAttempts("ip:127.0.0.1"))
+}
+
+func TestRedisSaveMFASecretSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SaveMultipleInPipe", mock.Anything).Return(nil)
+	adapterMock.On("ReplaceSet", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.SaveMFASecret("1", "secret", []string{"code"}))
+}
+
+func TestRedisGetMFASecretNotEnrolled(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("Get", mock.Anything).Return("", redis.Nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	_, err := redisSt.GetMFASecret("1")
+
+	assert.Equal(t, gwt.ErrMFANotEnrolled, err)
+}
+
+func TestRedisUseMFARecoveryCodeSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SRem", mock.Anything).Return(int64(1), nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.UseMFARecoveryCode("1", "code"))
+}
+
+func TestRedisUseMFARecoveryCodeInvalid(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SRem", mock.Anything).Return(int64(0), nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Equal(t, gwt.ErrInvalidMFACode, redisSt.UseMFARecoveryCode("1", "code"))
+}
+
+func TestRedisSaveSessionSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SaveMultipleInPipe", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.SaveSession(&gwt.Session{Id: "sid", UserId: "1"}, 123))
+}
+
+func TestRedisGetSessionSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("Get", mock.Anything).Return(`{"id":"sid","user_id":"1","created_at":100}`, nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	session, err := redisSt.GetSession("1", "sid")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "sid", session.Id)
+	assert.Equal(t, int64(100), session.CreatedAt)
+}
+
+func TestRedisGetSessionNotFound(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("Get", mock.Anything).Return("", redis.Nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	_, err := redisSt.GetSession("1", "sid")
+
+	assert.Equal(t, gwt.ErrSessionNotFound, err)
+}
+
+func TestRedisDeleteSessionSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("Del", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.DeleteSession("1", "sid"))
+}
+
+func TestRedisSaveImpersonationSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SaveMultipleInPipe", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.SaveImpersonation(&gwt.Impersonation{Id: "sid", ActorId: "admin", UserId: "1"}, 123))
+}
+
+func TestRedisGetImpersonationsSuccess(t *testing.T) {
+	iteratorMock := &redisIteratorMock{}
+	iteratorMock.On("Val", mock.Anything).Return("i_sid")
+	iteratorMock.On("Next", mock.Anything).Return(nil)
+	iteratorMock.On("Err", mock.Anything).Return(nil)
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetScanIterator", mock.Anything).Return(iteratorMock)
+	adapterMock.On("Get", mock.Anything).Return(`{"id":"sid","actor_id":"admin","user_id":"1",`+
+		`"access_uuid":"access","refresh_uuid":"refresh","expires_at":200}`, nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	impersonations, err := redisSt.GetImpersonations()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*gwt.Impersonation{{Id: "sid", ActorId: "admin", UserId: "1", AccessUuid: "access",
+		RefreshUuid: "refresh", ExpiresAt: 200}}, impersonations)
+}
+
+func TestRedisGetSessionsSuccess(t *testing.T) {
+	iteratorMock := &redisIteratorMock{}
+	iteratorMock.On("Val", mock.Anything).Return("s1_sid")
+	iteratorMock.On("Next", mock.Anything).Return(nil)
+	iteratorMock.On("Err", mock.Anything).Return(nil)
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetScanIterator", mock.Anything).Return(iteratorMock)
+	adapterMock.On("Get", mock.Anything).Return(`{"id":"sid","user_id":"1","access_uuid":"access",`+
+		`"refresh_uuid":"refresh","created_at":100}`, nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	sessions, err := redisSt.GetSessions("1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*gwt.Session{{Id: "sid", UserId: "1", AccessUuid: "access", RefreshUuid: "refresh",
+		CreatedAt: 100}}, sessions)
+}
+
+func TestRedisDeleteImpersonationSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("Del", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.DeleteImpersonation("sid"))
+}
+
+func TestRedisDeleteAllSessionsSuccess(t *testing.T) {
+	iteratorMock := &redisIteratorMock{}
+	iteratorMock.On("Val", mock.Anything).Return("r1_uuid")
+	iteratorMock.On("Next", mock.Anything).Return(nil)
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetScanIterator", mock.Anything).Return(iteratorMock)
+	adapterMock.On("Del", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	removed, err := redisSt.DeleteAllSessions("1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), removed)
+}
+
+func TestRedisDeleteAllTokensExceptKeepsCurrentPair(t *testing.T) {
+	iteratorMock := &redisIteratorMock{}
+	iteratorMock.On("Val", mock.Anything).Return("r1_refresh")
+	iteratorMock.On("Next", mock.Anything).Return(nil)
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetScanIterator", mock.Anything).Return(iteratorMock)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	removed, err := redisSt.DeleteAllTokensExcept("1", "access", "refresh")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), removed)
+	adapterMock.AssertNotCalled(t, "Del")
+}
+
+func TestRedisDeleteAllTokensExceptSuccess(t *testing.T) {
+	iteratorMock := &redisIteratorMock{}
+	iteratorMock.On("Val", mock.Anything).Return("r1_other")
+	iteratorMock.On("Next", mock.Anything).Return(nil)
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetScanIterator", mock.Anything).Return(iteratorMock)
+	adapterMock.On("Del", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	removed, err := redisSt.DeleteAllTokensExcept("1", "access", "refresh")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), removed)
+}
+
+func TestRedisSaveTicketSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SaveMultipleInPipe", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.SaveTicket("hash", "token", 123))
+}
+
+func TestRedisUseTicketSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetDel", mock.Anything).Return("token", nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	accessToken, err := redisSt.UseTicket("hash")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "token", accessToken)
+}
+
+func TestRedisUseTicketNotFound(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetDel", mock.Anything).Return("", redis.Nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	_, err := redisSt.UseTicket("hash")
+
+	assert.Equal(t, gwt.ErrTicketNotFound, err)
+}
+
+func TestRedisSaveOpaqueTokenSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SaveMultipleInPipe", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.SaveOpaqueToken("hash", &gwt.OpaqueToken{UserId: "1", Expire: 123}))
+}
+
+func TestRedisGetOpaqueTokenSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("Get", mock.Anything).Return(`{"user_id":"1","expire":100,"claims":{"tenant":"acme"}}`, nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	token, err := redisSt.GetOpaqueToken("hash")
+
+	assert.Nil(t, err)
+	assert.Equal(t, &gwt.OpaqueToken{UserId: "1", Expire: 100, Claims: map[string]interface{}{"tenant": "acme"}}, token)
+}
+
+func TestRedisGetOpaqueTokenNotFound(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("Get", mock.Anything).Return("", redis.Nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+	_, err := redisSt.GetOpaqueToken("hash")
+
+	assert.Equal(t, gwt.ErrTokenNotFound, err)
+}
+
+func TestRedisDeleteOpaqueTokensSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetDel", mock.Anything).Return("hash", nil)
+	adapterMock.On("Del", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.DeleteOpaqueTokens("access", ""))
+	adapterMock.AssertCalled(t, "Del")
+}
+
+func TestRedisDeleteOpaqueTokensNotFound(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetDel", mock.Anything).Return("", redis.Nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.DeleteOpaqueTokens("access", "refresh"))
+	adapterMock.AssertNotCalled(t, "Del")
+}
+
+func TestRedisUseMFAStepSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SetIfGreater", mock.Anything).Return(true, nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.UseMFAStep("1", 100))
+}
+
+func TestRedisUseMFAStepReused(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SetIfGreater", mock.Anything).Return(false, nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Equal(t, gwt.ErrInvalidMFACode, redisSt.UseMFAStep("1", 100))
+}
+
+func TestRedisUseMFATokenSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SaveMultipleInPipe", mock.Anything).Return(nil)
+	adapterMock.On("GetDel", mock.Anything).Return("123", nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.SaveMFAToken("id", 123))
+	assert.Nil(t, redisSt.UseMFAToken("id"))
+}
+
+func TestRedisUseMFATokenNotFound(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("GetDel", mock.Anything).Return("", redis.Nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Equal(t, gwt.ErrMFATokenNotFound, redisSt.UseMFAToken("id"))
+}
+
+func TestRedisEscapeGlob(t *testing.T) {
+	redisSt := &RedisStorage{}
+
+	assert.Equal(t, "1", redisSt._escapeGlob("1"))
+	assert.Equal(t, `a\*b\?c\[d\]e\\f`, redisSt._escapeGlob(`a*b?c[d]e\f`))
+}
+
+func TestRedisSaveAccessTokenSuccess(t *testing.T) {
+	adapterMock := &redisAdapterMock{}
+	adapterMock.On("SaveMultipleInPipe", mock.Anything).Return(nil)
+	redisSt := &RedisStorage{adapter: adapterMock}
+
+	assert.Nil(t, redisSt.SaveAccessToken("client:worker", "auuid", 123, "atoken"))
+}
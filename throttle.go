@@ -0,0 +1,150 @@
+package gwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	defaultLockoutWindow    = time.Minute
+	defaultMaxLockoutWindow = time.Hour * 24
+	defaultAttemptsLifetime = time.Hour * 24
+	retryAfterHeader        = "Retry-After"
+)
+
+// LoginThrottle limits failed login attempts per client ip and per login identifier.
+// Failed attempts are kept in the storage, so limits hold across instances.
+// When the number of failed attempts reaches the limit, login is locked out for LockoutWindow,
+// and the window is doubled with every next failed attempt.
+type LoginThrottle struct {
+
+	// MaxAttemptsPerIP is a number of failed attempts from one ip before lockout. Zero disables ip throttling.
+	MaxAttemptsPerIP int64
+
+	// MaxAttemptsPerIdentifier is a number of failed attempts for one identifier before lockout.
+	// Zero disables identifier throttling.
+	MaxAttemptsPerIdentifier int64
+
+	// IdentifierFunc returns login identifier (username, email) of the request.
+	// Required when MaxAttemptsPerIdentifier is set unless RequestIdentifierFunc is set. Use c.ShouldBindBodyWith
+	// when reading request body, so it can be read again by Authenticator.
+	IdentifierFunc func(c *gin.Context) string
+
+	// RequestIdentifierFunc is IdentifierFunc of net/http login handler, the request body must be restored
+	// after reading, so it can be read again by RequestAuthenticator
+	RequestIdentifierFunc func(r *http.Request) string
+
+	// LockoutWindow is the duration of the first lockout. Optional, one minute by default.
+	LockoutWindow time.Duration
+
+	// MaxLockoutWindow is the maximal duration of lockout. Optional, one day by default.
+	MaxLockoutWindow time.Duration
+
+	// AttemptsLifetime is a duration failed attempts are remembered for. Optional, one day by default.
+	AttemptsLifetime time.Duration
+
+	// CaptchaThreshold is a number of failed attempts after which CaptchaFunc is called. Zero disables captcha.
+	CaptchaThreshold int64
+
+	// CaptchaFunc checks captcha of the login request, login is rejected if it returns an error
+	CaptchaFunc func(c *gin.Context) error
+
+	// RequestCaptchaFunc is CaptchaFunc of net/http login handler
+	RequestCaptchaFunc func(r *http.Request) error
+}
+
+func (lt *LoginThrottle) enabled() bool {
+	return lt.MaxAttemptsPerIP > 0 || lt.MaxAttemptsPerIdentifier > 0
+}
+
+type throttleKey struct {
+	key         string
+	maxAttempts int64
+	identifier  bool
+}
+
+// keys returns throttle keys of the login request, identifier key is skipped if the identifier is not known
+func (lt *LoginThrottle) keys(req *loginRequest) []throttleKey {
+	var keys []throttleKey
+	if lt.MaxAttemptsPerIP > 0 {
+		keys = append(keys, throttleKey{key: "ip:" + req.clientIP, maxAttempts: lt.MaxAttemptsPerIP})
+	}
+	if lt.MaxAttemptsPerIdentifier > 0 && req.identifier != nil {
+		if identifier := req.identifier(); identifier != "" {
+			keys = append(keys, throttleKey{key: "id:" + identifier, maxAttempts: lt.MaxAttemptsPerIdentifier,
+				identifier: true})
+		}
+	}
+	return keys
+}
+
+// lockedUntil returns unix time until which login is locked out, zero if it is not locked
+func (lt *LoginThrottle) lockedUntil(attempts int64, lastAttempt int64, maxAttempts int64) int64 {
+	if attempts < maxAttempts {
+		return 0
+	}
+	window := float64(lt.LockoutWindow) * math.Pow(2, float64(attempts-maxAttempts))
+	if window > float64(lt.MaxLockoutWindow) {
+		window = float64(lt.MaxLockoutWindow)
+	}
+	return lastAttempt + int64(time.Duration(window)/time.Second)
+}
+
+// checkLoginThrottle returns an error if login request must be rejected, Retry-After is set to the response header
+// on lockout and captcha is checked when the number of failed attempts reaches CaptchaThreshold
+func (handler *Handler) checkLoginThrottle(header http.Header, keys []throttleKey, captcha func() error) *tokenError {
+	throttle := &handler.settings.LoginThrottle
+	attemptStorage, ok := unwrapStorage[LoginAttemptStorageInterface](handler.settings.Storage)
+	if !ok {
+		return &tokenError{code: http.StatusInternalServerError, err: ErrUnsupportedStorage}
+	}
+	var maxCount int64
+	for _, key := range keys {
+		count, last, err := attemptStorage.GetLoginAttempts(key.key)
+		if err != nil {
+			return &tokenError{code: http.StatusInternalServerError, err: err}
+		}
+		now := handler.settings.now().Unix()
+		if lockedUntil := throttle.lockedUntil(count, last, key.maxAttempts); lockedUntil > now {
+			header.Set(retryAfterHeader, strconv.FormatInt(lockedUntil-now, 10))
+			return &tokenError{code: http.StatusTooManyRequests, err: ErrTooManyLoginAttempts}
+		}
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	if throttle.CaptchaThreshold > 0 && captcha != nil && maxCount >= throttle.CaptchaThreshold {
+		if err := captcha(); err != nil {
+			return &tokenError{code: http.StatusForbidden, err: err}
+		}
+	}
+	return nil
+}
+
+// registerFailedLogin stores failed attempt, errors are ignored to not hide authentication error
+func (handler *Handler) registerFailedLogin(keys []throttleKey) {
+	attemptStorage, ok := unwrapStorage[LoginAttemptStorageInterface](handler.settings.Storage)
+	if !ok {
+		return
+	}
+	expire := handler.settings.now().Add(handler.settings.LoginThrottle.AttemptsLifetime).Unix()
+	for _, key := range keys {
+		_, _ = attemptStorage.AddLoginAttempt(key.key, expire)
+	}
+}
+
+// resetFailedLogins forgets failed attempts of the identifier, ip attempts are kept
+func (handler *Handler) resetFailedLogins(keys []throttleKey) {
+	attemptStorage, ok := unwrapStorage[LoginAttemptStorageInterface](handler.settings.Storage)
+	if !ok {
+		return
+	}
+	for _, key := range keys {
+		if key.identifier {
+			_ = attemptStorage.DeleteLoginAttempts(key.key)
+		}
+	}
+}
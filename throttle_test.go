@@ -0,0 +1,131 @@
+package gwt
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testThrottledLoginInit(attempts int64, lastAttempt int64, authErr error,
+	captchaErr error) (*httptest.ResponseRecorder, *attemptStorageMock) {
+	strgMock := new(attemptStorageMock)
+	strgMock.On("SaveTokens", mock.Anything).Return(nil)
+	strgMock.On("GetLoginAttempts", mock.Anything).Return(attempts, lastAttempt, nil)
+	strgMock.On("AddLoginAttempt", mock.Anything).Return(attempts+1, nil)
+	strgMock.On("DeleteLoginAttempts", mock.Anything).Return(nil)
+	settings := getSettingsFixture()
+	settings.Storage = strgMock
+	settings.Authenticator = func(c *gin.Context) (string, error) {
+		return "1", authErr
+	}
+	settings.LoginThrottle = LoginThrottle{
+		MaxAttemptsPerIP:         5,
+		MaxAttemptsPerIdentifier: 3,
+		IdentifierFunc: func(c *gin.Context) string {
+			return "user"
+		},
+		CaptchaThreshold: 2,
+		CaptchaFunc: func(c *gin.Context) error {
+			return captchaErr
+		},
+	}
+	auth, _ := Init(*settings)
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/login", auth.Handler.GetLoginHandler())
+	request, _ := http.NewRequest(http.MethodPost, "/login", nil)
+	router.ServeHTTP(rr, request)
+
+	return rr, strgMock
+}
+
+func TestThrottledLoginSuccess(t *testing.T) {
+	rr, strgMock := testThrottledLoginInit(0, 0, nil, nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	strgMock.AssertCalled(t, "DeleteLoginAttempts")
+	strgMock.AssertNotCalled(t, "AddLoginAttempt")
+}
+
+func TestThrottledLoginFailureRegistersAttempt(t *testing.T) {
+	rr, strgMock := testThrottledLoginInit(0, 0, errors.New("invalid credentials"), nil)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	strgMock.AssertNumberOfCalls(t, "AddLoginAttempt", 2)
+}
+
+func TestThrottledLoginLockedOut(t *testing.T) {
+	rr, _ := testThrottledLoginInit(3, time.Now().Unix(), nil, nil)
+	var res map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, ErrTooManyLoginAttempts.Error(), res["error_message"])
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+}
+
+func TestThrottledLoginLockoutPassed(t *testing.T) {
+	rr, _ := testThrottledLoginInit(3, time.Now().Add(-time.Hour).Unix(), nil, nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestThrottledLoginCaptchaError(t *testing.T) {
+	rr, _ := testThrottledLoginInit(2, time.Now().Unix(), nil, errors.New("captcha required"))
+	var res map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, "captcha required", res["error_message"])
+}
+
+func TestLockedUntil(t *testing.T) {
+	throttle := &LoginThrottle{LockoutWindow: time.Minute, MaxLockoutWindow: time.Hour}
+
+	assert.Equal(t, int64(0), throttle.lockedUntil(2, 100, 3))
+	assert.Equal(t, int64(160), throttle.lockedUntil(3, 100, 3))
+	assert.Equal(t, int64(340), throttle.lockedUntil(5, 100, 3))
+	assert.Equal(t, int64(3700), throttle.lockedUntil(20, 100, 3))
+}
+
+func TestInitThrottleUnsupportedStorageError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.LoginThrottle = LoginThrottle{MaxAttemptsPerIP: 5}
+	auth, err := Init(*settings)
+
+	assert.Nil(t, auth)
+	assert.ErrorIs(t, err, ErrUnsupportedStorage)
+}
+
+func TestInitThrottleEmptyIdentifierFuncError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Storage = new(attemptStorageMock)
+	settings.LoginThrottle = LoginThrottle{MaxAttemptsPerIdentifier: 5}
+	auth, err := Init(*settings)
+
+	assert.Nil(t, auth)
+	assert.ErrorIs(t, err, ErrEmptyIdentifierFunc)
+}
+
+func TestCheckLoginThrottleUnsupportedStorage(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Storage = &instrumentedStorage{storage: new(storageMock), metrics: newMetrics()}
+	settings.LoginThrottle = LoginThrottle{MaxAttemptsPerIP: 5}
+	handler := &Handler{settings: settings}
+	tokenErr := handler.checkLoginThrottle(http.Header{}, []throttleKey{{key: "ip", maxAttempts: 5}}, nil)
+
+	assert.Equal(t, http.StatusInternalServerError, tokenErr.code)
+	assert.Equal(t, ErrUnsupportedStorage, tokenErr.err)
+	assert.NotPanics(t, func() {
+		handler.registerFailedLogin([]throttleKey{{key: "ip"}})
+		handler.resetFailedLogins([]throttleKey{{key: "user", identifier: true}})
+	})
+}
@@ -10,27 +10,113 @@ import (
 
 type tokenService struct{}
 
-func (ts *tokenService) isExpired(expireStr string) error {
-	expireFloat, _, err := big.ParseFloat(expireStr, 10, 0, big.ToNearestEven)
+// tokenParams describe the session tokens are issued for
+type tokenParams struct {
+	userId string
+
+	// clientId is set when tokens are issued to a client with client credentials grant
+	clientId string
+
+	// actorId is set when tokens are issued to the admin impersonating the user
+	actorId string
+
+	// sessionId and sessionStart are carried across token rotations, new session is started if sessionId is empty
+	sessionId    string
+	sessionStart int64
+
+	// sessionExpire caps expiration of the tokens, zero if session lifetime is unlimited
+	sessionExpire int64
+
+	// refreshExpire is used as refresh token expiration instead of RefreshLifetime if it is not zero
+	refreshExpire int64
+
+	// accessLifetime and refreshLifetime override lifetimes from settings if they are not zero
+	accessLifetime  time.Duration
+	refreshLifetime time.Duration
+
+	// claims are added to both tokens and kept across rotations
+	claims map[string]interface{}
+
+	// metadata is saved with the session
+	metadata map[string]string
+}
+
+func newTokenParams(result *AuthResult) *tokenParams {
+	return &tokenParams{
+		userId:          result.UserId,
+		accessLifetime:  result.AccessLifetime,
+		refreshLifetime: result.RefreshLifetime,
+		claims:          result.Claims,
+		metadata:        result.Metadata,
+	}
+}
+
+// restoreParams restores user id, lifetimes, custom claims and metadata of the login from the token
+func (ts *tokenService) restoreParams(token *jwt.Token) (*tokenParams, error) {
+	claims, err := ts.getClaims(token, []string{userIdClaim, clientIdClaim, accessLifetimeClaim, refreshLifetimeClaim})
 	if err != nil {
-		return ErrTokenInvalid
+		return nil, err
+	}
+	params := &tokenParams{userId: claims[userIdClaim], clientId: claims[clientIdClaim], claims: ts.getCustomClaims(token)}
+	if claims[accessLifetimeClaim] != "" {
+		accessLifetime, parseErr := ts.parseUnix(claims[accessLifetimeClaim])
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		params.accessLifetime = time.Duration(accessLifetime) * time.Second
+	}
+	if claims[refreshLifetimeClaim] != "" {
+		refreshLifetime, parseErr := ts.parseUnix(claims[refreshLifetimeClaim])
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		params.refreshLifetime = time.Duration(refreshLifetime) * time.Second
+	}
+	if metadata, ok := token.Claims.(jwt.MapClaims)[sessionMetadataClaim].(map[string]interface{}); ok {
+		params.metadata = map[string]string{}
+		for key, value := range metadata {
+			params.metadata[key] = fmt.Sprint(value)
+		}
 	}
-	expire, _ := expireFloat.Int64()
-	if expire > time.Now().Unix() {
+	return params, nil
+}
+
+func (ts *tokenService) parseUnix(unixStr string) (int64, error) {
+	unixFloat, _, err := big.ParseFloat(unixStr, 10, 0, big.ToNearestEven)
+	if err != nil {
+		return 0, ErrTokenInvalid
+	}
+	unix, _ := unixFloat.Int64()
+	return unix, nil
+}
+
+func (ts *tokenService) isExpired(settings *Settings, expireStr string) error {
+	expire, err := ts.parseUnix(expireStr)
+	if err != nil {
+		return err
+	}
+	if expire > settings.now().Unix() {
 		return nil
 	}
 	return ErrTokenExpired
 }
 
-func (ts *tokenService) getTokens(settings *Settings, userId string) (*accessTokenData, *refreshTokenData, error) {
+func (ts *tokenService) getTokens(settings *Settings, params *tokenParams) (*accessTokenData, *refreshTokenData, error) {
 	refreshUuid := uuid.NewV4().String()
 	accessUuid := uuid.NewV4().String()
+	if params.sessionId == "" {
+		params.sessionId = uuid.NewV4().String()
+		params.sessionStart = settings.now().Unix()
+	}
+	if settings.SessionMaxLifetime > 0 {
+		params.sessionExpire = time.Unix(params.sessionStart, 0).Add(settings.SessionMaxLifetime).Unix()
+	}
 
-	accessData, accessError := ts._createAccessToken(settings, userId, accessUuid, refreshUuid)
+	accessData, accessError := ts._createAccessToken(settings, params, accessUuid, refreshUuid)
 	if accessError != nil {
 		return nil, nil, accessError
 	}
-	refreshData, refreshError := ts._createRefreshToken(settings, userId, accessUuid, refreshUuid)
+	refreshData, refreshError := ts._createRefreshToken(settings, params, accessUuid, refreshUuid)
 	if refreshError != nil {
 		return nil, nil, refreshError
 	}
@@ -42,15 +128,49 @@ func (ts *tokenService) getClaims(token *jwt.Token, claimNames []string) (map[st
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if ok {
 		for _, el := range claimNames {
-			res[el] = fmt.Sprint(claims[el])
+			if claim, exists := claims[el]; exists {
+				res[el] = fmt.Sprint(claim)
+			}
 		}
 		return res, nil
 	}
 	return nil, ErrTokenInvalid
 }
 
+// getCustomClaims returns claims of the token which are not used by the package
+func (ts *tokenService) getCustomClaims(token *jwt.Token) map[string]interface{} {
+	res := map[string]interface{}{}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	for name, value := range claims {
+		if !reservedClaims[name] {
+			res[name] = value
+		}
+	}
+	return res
+}
+
+// getActorId returns subject of RFC 8693 act claim, empty string if token is not issued to the impersonating admin
+func (ts *tokenService) getActorId(token *jwt.Token) string {
+	actor, ok := token.Claims.(jwt.MapClaims)[actorClaim].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	actorId, _ := actor["sub"].(string)
+	return actorId
+}
+
+// getSessionId returns id of the session, uuid of the refresh token is used for tokens issued without session id
+func (ts *tokenService) getSessionId(claims map[string]string) string {
+	if sessionId := claims[sessionIdClaim]; sessionId != "" {
+		return sessionId
+	}
+	return claims[refreshUuidClaim]
+}
+
+// parseToken verifies signature of the token only, expiration is checked by isExpired with the settings clock
 func (ts *tokenService) parseToken(tkn string, secret []byte, signingMethod string) (*jwt.Token, error) {
-	token, err := jwt.Parse(tkn, func(token *jwt.Token) (interface{}, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tkn, func(token *jwt.Token) (interface{}, error) {
 		if jwt.GetSigningMethod(signingMethod) != token.Method {
 			return nil, ErrInvalidSigningMethod
 		}
@@ -64,47 +184,167 @@ func (ts *tokenService) parseToken(tkn string, secret []byte, signingMethod stri
 	return token, nil
 }
 
-func (ts *tokenService) _createAccessToken(settings *Settings, userId string,
+// decodeToken decrypts the token if settings encrypt tokens and verifies its signature with the secret,
+// claims of opaque tokens are looked up in the storage
+func (ts *tokenService) decodeToken(settings *Settings, tkn string, secret []byte) (*jwt.Token, error) {
+	if settings.TokenFormat == TokenFormatOpaque {
+		return resolveOpaqueToken(settings, tkn)
+	}
+	if settings.TokenEncryptionKey != nil {
+		var err error
+		if tkn, err = DecryptToken(settings.TokenEncryptionKey, tkn); err != nil {
+			return nil, ErrTokenInvalid
+		}
+	}
+	return ts.parseToken(tkn, secret, settings.SigningMethod)
+}
+
+func (ts *tokenService) _createAccessToken(settings *Settings, params *tokenParams,
 	accessUuid string, refreshUuid string) (*accessTokenData, error) {
 	td := &accessTokenData{}
-	td.expire = time.Now().Add(settings.AccessLifetime).Unix()
+	lifetime := settings.AccessLifetime
+	if params.accessLifetime != 0 {
+		lifetime = params.accessLifetime
+	}
+	td.expire = ts._capExpire(settings.now().Add(lifetime).Unix(), params.sessionExpire)
 	td.uuid = accessUuid
 	td.refreshUuid = refreshUuid
-	td.userId = userId
+	td.userId = params.userId
+	td.sessionId = params.sessionId
 
+	claims := ts._getParamsClaims(params)
+	claims[accessUuidClaim] = td.uuid
+	claims[userIdClaim] = td.userId
+	claims[expiredClaim] = td.expire
+	claims[issuedAtClaim] = settings.now().Unix()
+	claims[refreshUuidClaim] = td.refreshUuid
+	claims[sessionIdClaim] = params.sessionId
+	claims[sessionStartClaim] = params.sessionStart
+
+	// opaque access token links its refresh token, so it is kept until the refresh token expires to be revocable
+	keepUntil := td.expire
+	if refreshUuid != "" {
+		keepUntil = ts._getRefreshExpire(settings, params)
+	}
 	var err error
-	td.token, err = ts._createToken(
-		settings.SigningMethod,
-		jwt.MapClaims{accessUuidClaim: td.uuid, userIdClaim: td.userId,
-			expiredClaim: td.expire, refreshUuidClaim: td.refreshUuid},
-		settings.AccessSecretKey)
+	td.token, err = ts._createToken(settings, claims, settings.AccessSecretKey, td.uuid, keepUntil)
 	if err != nil {
 		return nil, ErrFailedToCreateAccessToken
 	}
 	return td, nil
 }
 
-func (ts *tokenService) _createRefreshToken(settings *Settings, userId string,
+func (ts *tokenService) _createRefreshToken(settings *Settings, params *tokenParams,
 	accessUuid string, refreshUuid string) (*refreshTokenData, error) {
 	td := &refreshTokenData{}
-	td.expire = time.Now().Add(settings.RefreshLifetime).Unix()
+	td.expire = ts._getRefreshExpire(settings, params)
 	td.uuid = refreshUuid
 	td.accessUuid = accessUuid
-	td.userId = userId
+	td.userId = params.userId
+	td.sessionId = params.sessionId
+
+	claims := ts._getParamsClaims(params)
+	claims[refreshUuidClaim] = td.uuid
+	claims[userIdClaim] = td.userId
+	claims[expiredClaim] = td.expire
+	claims[issuedAtClaim] = settings.now().Unix()
+	claims[accessUuidClaim] = td.accessUuid
+	claims[sessionIdClaim] = params.sessionId
+	claims[sessionStartClaim] = params.sessionStart
+	ts._setLifetimeClaims(claims, params)
 
 	var err error
-	td.token, err = ts._createToken(
-		settings.SigningMethod,
-		jwt.MapClaims{refreshUuidClaim: td.uuid,
-			userIdClaim: td.userId, expiredClaim: td.expire, accessUuidClaim: td.accessUuid},
-		settings.RefreshSecretKey)
+	td.token, err = ts._createToken(settings, claims, settings.RefreshSecretKey, td.uuid, td.expire)
 	if err != nil {
 		return nil, ErrFailedToCreateRefreshToken
 	}
 	return td, nil
 }
 
-func (ts *tokenService) _createToken(signingMethod string, claims jwt.MapClaims, secretKey []byte) (string, error) {
-	rt := jwt.NewWithClaims(jwt.GetSigningMethod(signingMethod), claims)
-	return rt.SignedString(secretKey)
+func (ts *tokenService) _getRefreshExpire(settings *Settings, params *tokenParams) int64 {
+	lifetime := settings.RefreshLifetime
+	if params.refreshLifetime != 0 {
+		lifetime = params.refreshLifetime
+	}
+	expire := settings.now().Add(lifetime).Unix()
+	if params.refreshExpire != 0 {
+		expire = params.refreshExpire
+	}
+	return ts._capExpire(expire, params.sessionExpire)
+}
+
+func (ts *tokenService) _capExpire(expire int64, maxExpire int64) int64 {
+	if maxExpire != 0 && expire > maxExpire {
+		return maxExpire
+	}
+	return expire
+}
+
+// _getParamsClaims returns custom claims of the login, reserved claims are skipped
+func (ts *tokenService) _getParamsClaims(params *tokenParams) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	for name, value := range params.claims {
+		if !reservedClaims[name] {
+			claims[name] = value
+		}
+	}
+	if params.clientId != "" {
+		claims[clientIdClaim] = params.clientId
+	}
+	if params.actorId != "" {
+		claims[actorClaim] = map[string]interface{}{"sub": params.actorId}
+	}
+	return claims
+}
+
+// _setLifetimeClaims keeps per-login lifetimes in the token, so they are used on refresh
+func (ts *tokenService) _setLifetimeClaims(claims jwt.MapClaims, params *tokenParams) {
+	if params.accessLifetime != 0 {
+		claims[accessLifetimeClaim] = int64(params.accessLifetime / time.Second)
+	}
+	if params.refreshLifetime != 0 {
+		claims[refreshLifetimeClaim] = int64(params.refreshLifetime / time.Second)
+	}
+}
+
+// _createMFAToken creates mfa token, its id is saved to the storage, so the token is accepted once
+func (ts *tokenService) _createMFAToken(settings *Settings, params *tokenParams) (string, int64, error) {
+	mfaStorage, ok := unwrapStorage[MFAStorageInterface](settings.Storage)
+	if !ok {
+		return "", 0, ErrUnsupportedStorage
+	}
+	expire := settings.now().Add(settings.MFATokenLifetime).Unix()
+	mfaUuid := uuid.NewV4().String()
+	claims := ts._getParamsClaims(params)
+	claims[mfaClaim] = true
+	claims[mfaUuidClaim] = mfaUuid
+	claims[userIdClaim] = params.userId
+	claims[expiredClaim] = expire
+	ts._setLifetimeClaims(claims, params)
+	if len(params.metadata) > 0 {
+		claims[sessionMetadataClaim] = params.metadata
+	}
+	token, err := ts._createToken(settings, claims, settings.AccessSecretKey, mfaUuid, expire)
+	if err != nil {
+		return "", 0, ErrFailedToCreateMFAToken
+	}
+	if err = mfaStorage.SaveMFAToken(mfaUuid, expire); err != nil {
+		return "", 0, ErrFailedToCreateMFAToken
+	}
+	return token, expire, nil
+}
+
+// _createToken signs the claims, the signed token is encrypted if TokenEncryptionKey is set.
+// Opaque tokens are not signed, their claims are saved to the storage by token uuid until keepUntil.
+func (ts *tokenService) _createToken(settings *Settings, claims jwt.MapClaims, secretKey []byte, tokenUuid string,
+	keepUntil int64) (string, error) {
+	if settings.TokenFormat == TokenFormatOpaque {
+		return createOpaqueToken(settings, claims, tokenUuid, keepUntil)
+	}
+	rt := jwt.NewWithClaims(jwt.GetSigningMethod(settings.SigningMethod), claims)
+	token, err := rt.SignedString(secretKey)
+	if err != nil || settings.TokenEncryptionKey == nil {
+		return token, err
+	}
+	return encryptToken(settings.TokenEncryptionKey, token)
 }
@@ -10,20 +10,33 @@ import (
 
 func TestIsExpired(t *testing.T) {
 	service := &tokenService{}
-	err := service.isExpired(fmt.Sprint(time.Now().Add(time.Minute).Unix()))
+	settingsFixture := getSettingsFixture()
+	err := service.isExpired(settingsFixture, fmt.Sprint(time.Now().Add(time.Minute).Unix()))
 	assert.Nil(t, err)
 
-	err = service.isExpired(fmt.Sprint(time.Now().Add(-time.Minute).Unix()))
+	err = service.isExpired(settingsFixture, fmt.Sprint(time.Now().Add(-time.Minute).Unix()))
 	assert.Equal(t, err, ErrTokenExpired)
 
-	err = service.isExpired("wrong")
+	err = service.isExpired(settingsFixture, "wrong")
 	assert.Error(t, err, ErrTokenInvalid)
 }
 
+func TestIsExpiredUsesClock(t *testing.T) {
+	service := &tokenService{}
+	settingsFixture := getSettingsFixture()
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	settingsFixture.Clock = clock
+
+	assert.Nil(t, service.isExpired(settingsFixture, "1060"))
+
+	clock.now = time.Unix(1060, 0)
+	assert.Equal(t, ErrTokenExpired, service.isExpired(settingsFixture, "1060"))
+}
+
 func Test_CreateRefreshToken(t *testing.T) {
 	service := &tokenService{}
 	settingsFixture := getSettingsFixture()
-	data, err := service._createRefreshToken(settingsFixture, "1", "auuid", "ruuid")
+	data, err := service._createRefreshToken(settingsFixture, &tokenParams{userId: "1"}, "auuid", "ruuid")
 	assert.Nil(t, err)
 	assert.IsType(t, &refreshTokenData{}, data)
 	assert.Equal(t, "ruuid", data.uuid)
@@ -36,7 +49,7 @@ func Test_CreateRefreshToken(t *testing.T) {
 func Test_CreateAccessToken(t *testing.T) {
 	service := &tokenService{}
 	settingsFixture := getSettingsFixture()
-	data, err := service._createAccessToken(settingsFixture, "1", "auuid", "ruuid")
+	data, err := service._createAccessToken(settingsFixture, &tokenParams{userId: "1"}, "auuid", "ruuid")
 	assert.Nil(t, err)
 	assert.IsType(t, &accessTokenData{}, data)
 	assert.Equal(t, "auuid", data.uuid)
@@ -49,7 +62,7 @@ func Test_CreateAccessToken(t *testing.T) {
 func TestParseToken(t *testing.T) {
 	service := &tokenService{}
 	settingsFixture := getSettingsFixture()
-	token, tokenErr := service._createAccessToken(settingsFixture, "1", "auuid", "ruuid")
+	token, tokenErr := service._createAccessToken(settingsFixture, &tokenParams{userId: "1"}, "auuid", "ruuid")
 	assert.Nil(t, tokenErr)
 
 	data, err := service.parseToken(token.token, settingsFixture.AccessSecretKey, "wrong_sign_method")
@@ -68,7 +81,7 @@ func TestParseToken(t *testing.T) {
 func TestGetClaims(t *testing.T) {
 	service := &tokenService{}
 	settingsFixture := getSettingsFixture()
-	token, _ := service._createAccessToken(settingsFixture, "1", "auuid", "ruuid")
+	token, _ := service._createAccessToken(settingsFixture, &tokenParams{userId: "1"}, "auuid", "ruuid")
 
 	tkn, _ := service.parseToken(token.token, settingsFixture.AccessSecretKey, settingsFixture.SigningMethod)
 
@@ -84,8 +97,38 @@ func TestGetTokens(t *testing.T) {
 	service := &tokenService{}
 	settingsFixture := getSettingsFixture()
 
-	access, refresh, err := service.getTokens(settingsFixture, "1")
+	access, refresh, err := service.getTokens(settingsFixture, &tokenParams{userId: "1"})
 	assert.Nil(t, err)
 	assert.Equal(t, "1", access.userId)
 	assert.Equal(t, "1", refresh.userId)
 }
+
+func TestGetTokensSession(t *testing.T) {
+	service := &tokenService{}
+	settingsFixture := getSettingsFixture()
+	settingsFixture.SessionMaxLifetime = time.Second * 30
+	params := &tokenParams{userId: "1"}
+
+	access, refresh, err := service.getTokens(settingsFixture, params)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, params.sessionId)
+	assert.Equal(t, params.sessionId, access.sessionId)
+	assert.Equal(t, params.sessionId, refresh.sessionId)
+	assert.Equal(t, params.sessionStart+30, params.sessionExpire)
+	assert.Equal(t, params.sessionExpire, access.expire)
+	assert.Equal(t, params.sessionExpire, refresh.expire)
+}
+
+func TestParseExpiredToken(t *testing.T) {
+	service := &tokenService{}
+	settingsFixture := getSettingsFixture()
+	settingsFixture.AccessLifetime = -time.Minute
+	token, _ := service._createAccessToken(settingsFixture, &tokenParams{userId: "1"}, "auuid", "ruuid")
+
+	tkn, tknErr := service.parseToken(token.token, settingsFixture.AccessSecretKey, settingsFixture.SigningMethod)
+	assert.Nil(t, tknErr)
+	assert.Equal(t, "auuid", tkn.Claims.(jwt.MapClaims)[accessUuidClaim])
+
+	_, wrongErr := service.parseToken(token.token, []byte("wrong"), settingsFixture.SigningMethod)
+	assert.Equal(t, ErrTokenInvalid, wrongErr)
+}
@@ -0,0 +1,126 @@
+package gwt
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// UserCacheInterface caches users returned by GetUserFunc by user id.
+// Implement it to share the cache between instances, e.g. in redis.
+type UserCacheInterface interface {
+	// Get returns cached user, false if user is not cached or has expired
+	Get(userId string) (interface{}, bool)
+	Set(userId string, user interface{})
+	Delete(userId string)
+}
+
+// LRUUserCache is in-memory UserCacheInterface implementation, least recently used users are evicted
+// when size is exceeded, users expire after ttl
+type LRUUserCache struct {
+	size    int
+	ttl     time.Duration
+	clock   Clock
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type userCacheEntry struct {
+	userId string
+	user   interface{}
+	expire time.Time
+}
+
+// NewLRUUserCache creates in-memory user cache keeping at most size users for ttl
+func NewLRUUserCache(size int, ttl time.Duration) *LRUUserCache {
+	return &LRUUserCache{size: size, ttl: ttl, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+func (cache *LRUUserCache) Get(userId string) (interface{}, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.entries[userId]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*userCacheEntry)
+	if !entry.expire.After(cache.now()) {
+		cache.remove(element)
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return entry.user, true
+}
+
+func (cache *LRUUserCache) Set(userId string, user interface{}) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	expire := cache.now().Add(cache.ttl)
+	if element, ok := cache.entries[userId]; ok {
+		entry := element.Value.(*userCacheEntry)
+		entry.user, entry.expire = user, expire
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[userId] = cache.order.PushFront(&userCacheEntry{userId: userId, user: user, expire: expire})
+	for cache.size > 0 && cache.order.Len() > cache.size {
+		cache.remove(cache.order.Back())
+	}
+}
+
+func (cache *LRUUserCache) Delete(userId string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if element, ok := cache.entries[userId]; ok {
+		cache.remove(element)
+	}
+}
+
+func (cache *LRUUserCache) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*userCacheEntry).userId)
+}
+
+// SetClock sets the clock users expire by, it is called by Init with Settings.Clock
+func (cache *LRUUserCache) SetClock(clock Clock) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.clock = clock
+}
+
+func (cache *LRUUserCache) now() time.Time {
+	if cache.clock == nil {
+		return time.Now()
+	}
+	return cache.clock.Now()
+}
+
+// getUser returns the user from the cache or loads it with GetUserFunc, errors are not cached
+func getUser(settings *Settings, userId string) (interface{}, error) {
+	if settings.UserCache != nil {
+		if user, ok := settings.UserCache.Get(userId); ok {
+			return user, nil
+		}
+	}
+	user, err := settings.GetUserFunc(userId)
+	if err != nil {
+		return nil, err
+	}
+	if settings.UserCache != nil {
+		settings.UserCache.Set(userId, user)
+	}
+	return user, nil
+}
+
+// invalidateUser deletes the user from the cache
+func invalidateUser(settings *Settings, userId string) {
+	if settings.UserCache != nil {
+		settings.UserCache.Delete(userId)
+	}
+}
+
+// InvalidateUser deletes the user from Settings.UserCache, call it when the user is changed
+func (service *Service) InvalidateUser(userId string) {
+	invalidateUser(service.settings, userId)
+}
@@ -0,0 +1,120 @@
+package gwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLRUUserCacheEviction(t *testing.T) {
+	cache := NewLRUUserCache(2, time.Minute)
+	cache.Set("1", "user1")
+	cache.Set("2", "user2")
+	_, _ = cache.Get("1")
+	cache.Set("3", "user3")
+
+	_, ok1 := cache.Get("1")
+	_, ok2 := cache.Get("2")
+	user3, ok3 := cache.Get("3")
+
+	assert.True(t, ok1)
+	assert.False(t, ok2)
+	assert.True(t, ok3)
+	assert.Equal(t, "user3", user3)
+}
+
+func TestLRUUserCacheExpiration(t *testing.T) {
+	cache := NewLRUUserCache(10, -time.Second)
+	cache.Set("1", "user1")
+
+	_, ok := cache.Get("1")
+
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.order.Len())
+}
+
+func TestLRUUserCacheExpirationUsesClock(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1000, 0)}
+	cache := NewLRUUserCache(10, time.Minute)
+	cache.SetClock(clock)
+	cache.Set("1", "user1")
+
+	clock.now = time.Unix(1059, 0)
+	_, ok := cache.Get("1")
+	assert.True(t, ok)
+
+	clock.now = time.Unix(1060, 0)
+	_, ok = cache.Get("1")
+	assert.False(t, ok)
+}
+
+func TestInitSetsUserCacheClock(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Clock = &fixedClock{now: time.Unix(1000, 0)}
+	cache := NewLRUUserCache(10, time.Minute)
+	settings.UserCache = cache
+	_, err := Init(*settings)
+
+	assert.Nil(t, err)
+	assert.Equal(t, settings.Clock, cache.clock)
+}
+
+func TestLRUUserCacheDelete(t *testing.T) {
+	cache := NewLRUUserCache(10, time.Minute)
+	cache.Set("1", "user1")
+	cache.Set("1", "user1 updated")
+	user, ok := cache.Get("1")
+	assert.True(t, ok)
+	assert.Equal(t, "user1 updated", user)
+
+	cache.Delete("1")
+	_, ok = cache.Get("1")
+
+	assert.False(t, ok)
+}
+
+func TestAuthMiddlewareUserCache(t *testing.T) {
+	settings := getSettingsFixture()
+	accessData, _ := (&tokenService{})._createAccessToken(settings, &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	strgMock.On("DeleteAllTokens", mock.Anything).Return(nil)
+	settings.Storage = strgMock
+	settings.UserCache = NewLRUUserCache(10, time.Minute)
+	calls := 0
+	settings.GetUserFunc = func(userId string) (interface{}, error) {
+		calls++
+		return userId, nil
+	}
+	mw := &Middleware{settings: settings}
+	service := &Service{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(mw.GetAuthMiddleware()).GET("/test-auth", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	authenticate := func() int {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/test-auth", nil)
+		request.Header.Add("Authorization", "Bearer "+accessData.token)
+		router.ServeHTTP(rr, request)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, authenticate())
+	assert.Equal(t, http.StatusOK, authenticate())
+	assert.Equal(t, 1, calls)
+
+	service.InvalidateUser("1")
+	assert.Equal(t, http.StatusOK, authenticate())
+	assert.Equal(t, 2, calls)
+
+	assert.Nil(t, service.ForceLogoutUser("1"))
+	assert.Equal(t, http.StatusOK, authenticate())
+	assert.Equal(t, 3, calls)
+}
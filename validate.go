@@ -0,0 +1,157 @@
+package gwt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// SecretReusePolicy tells Init what to do when refresh tokens are signed with the access secret key
+type SecretReusePolicy int
+
+const (
+	// SecretReuseWarn logs a warning with the standard logger, it is the default
+	SecretReuseWarn SecretReusePolicy = iota
+
+	// SecretReuseAllow accepts the same secret key silently
+	SecretReuseAllow
+
+	// SecretReuseDeny fails Init with ErrRefreshSecretReused
+	SecretReuseDeny
+)
+
+// minSecretKeyLengths are minimal HMAC secret key lengths in bytes, the key must be at least as long as the hash
+var minSecretKeyLengths = map[string]int{
+	"HS256": 32,
+	"HS384": 48,
+	"HS512": 64,
+}
+
+// SettingsError lists all problems found in settings, errors.Is reports whether any of them matches the target
+type SettingsError struct {
+	Errors []error
+}
+
+func (e *SettingsError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *SettingsError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSettings checks the settings the way Init does and returns *SettingsError with all problems found,
+// e.g. to check settings read by SettingsFromConfig before the application starts.
+func ValidateSettings(settings Settings) error {
+	settings.setDefaults()
+	return settings.validate()
+}
+
+// validate checks settings with defaults applied
+func (settings *Settings) validate() error {
+	var errs []error
+	if settings.Storage == nil {
+		errs = append(errs, ErrEmptyStorage)
+	}
+	if settings.Authenticator == nil && settings.LoginAuthenticator == nil && settings.RequestAuthenticator == nil {
+		errs = append(errs, ErrEmptyAuthenticator)
+	}
+	if settings.GetUserFunc == nil {
+		errs = append(errs, ErrEmptyGetUserFunc)
+	}
+	if settings.AccessSecretKey == nil {
+		errs = append(errs, ErrEmptyAccessSecretKey)
+	}
+	if availSigningMethods[settings.SigningMethod] == "" {
+		errs = append(errs, ErrUnknownSigningMethod)
+	} else if settings.AccessSecretKey != nil {
+		minLength := minSecretKeyLengths[settings.SigningMethod]
+		if len(settings.AccessSecretKey) < minLength {
+			errs = append(errs, fmt.Errorf("access %w: %d bytes, %s requires at least %d",
+				ErrShortSecretKey, len(settings.AccessSecretKey), settings.SigningMethod, minLength))
+		}
+		if len(settings.RefreshSecretKey) < minLength && !bytes.Equal(settings.RefreshSecretKey, settings.AccessSecretKey) {
+			errs = append(errs, fmt.Errorf("refresh %w: %d bytes, %s requires at least %d",
+				ErrShortSecretKey, len(settings.RefreshSecretKey), settings.SigningMethod, minLength))
+		}
+	}
+	if settings.SecretReuse == SecretReuseDeny && settings.AccessSecretKey != nil &&
+		bytes.Equal(settings.RefreshSecretKey, settings.AccessSecretKey) {
+		errs = append(errs, ErrRefreshSecretReused)
+	}
+	switch settings.TokenFormat {
+	case TokenFormatJWT:
+	case TokenFormatOpaque:
+		if _, ok := unwrapStorage[OpaqueTokenStorageInterface](settings.Storage); settings.Storage != nil && !ok {
+			errs = append(errs, ErrUnsupportedStorage)
+		}
+	default:
+		errs = append(errs, ErrUnknownTokenFormat)
+	}
+	if _, ok := unwrapStorage[AccessTokenStorageInterface](settings.Storage); settings.Clients != nil &&
+		settings.Storage != nil && !ok {
+		errs = append(errs, ErrUnsupportedStorage)
+	}
+	if settings.TokenEncryptionKey != nil && jweEncryptions[len(settings.TokenEncryptionKey)] == "" {
+		errs = append(errs, ErrInvalidEncryptionKey)
+	}
+	if settings.AccessLifetime >= settings.RefreshLifetime {
+		errs = append(errs, fmt.Errorf("%w: access lifetime %s, refresh lifetime %s",
+			ErrInvalidLifetime, settings.AccessLifetime, settings.RefreshLifetime))
+	}
+	if settings.ImpersonationLifetime < 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidImpersonationLifetime, settings.ImpersonationLifetime))
+	}
+	if !isHTTPToken(settings.AuthHeadName) {
+		errs = append(errs, fmt.Errorf("%w: AuthHeadName %q", ErrInvalidHeaderName, settings.AuthHeadName))
+	}
+	if settings.AdditionalAuthHeader != "" && (!isHTTPToken(settings.AdditionalAuthHeader) ||
+		strings.EqualFold(settings.AdditionalAuthHeader, authHeader)) {
+		errs = append(errs, fmt.Errorf("%w: AdditionalAuthHeader %q", ErrInvalidHeaderName, settings.AdditionalAuthHeader))
+	}
+	if settings.LoginThrottle.enabled() {
+		if _, ok := unwrapStorage[LoginAttemptStorageInterface](settings.Storage); !ok {
+			errs = append(errs, ErrUnsupportedStorage)
+		}
+		if settings.LoginThrottle.MaxAttemptsPerIdentifier > 0 && settings.LoginThrottle.IdentifierFunc == nil &&
+			settings.LoginThrottle.RequestIdentifierFunc == nil {
+			errs = append(errs, ErrEmptyIdentifierFunc)
+		}
+	}
+	if len(errs) > 0 {
+		return &SettingsError{Errors: errs}
+	}
+	return nil
+}
+
+// warnSecretReuse logs the warning of SecretReuseWarn policy, Init calls it only if RefreshSecretKey is set explicitly,
+// so the default of reusing the access secret key stays silent
+func (settings *Settings) warnSecretReuse() {
+	if settings.SecretReuse == SecretReuseWarn && bytes.Equal(settings.RefreshSecretKey, settings.AccessSecretKey) {
+		log.Printf("gwt: warning: %v, set RefreshSecretKey or SecretReuse: gwt.SecretReuseAllow", ErrRefreshSecretReused)
+	}
+}
+
+// isHTTPToken reports whether the value is a non-empty token of RFC 7230, as header names and auth schemes are
+func isHTTPToken(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r > '~' || r <= ' ' || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return false
+		}
+	}
+	return true
+}
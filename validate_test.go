@@ -0,0 +1,165 @@
+package gwt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"testing"
+	"time"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	original := log.Writer()
+	log.SetOutput(buf)
+	t.Cleanup(func() {
+		log.SetOutput(original)
+	})
+	return buf
+}
+
+func TestInitCollectsAllErrors(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.Storage = nil
+	settings.GetUserFunc = nil
+	settings.AccessLifetime = time.Hour
+	auth, err := Init(*settings)
+
+	assert.Nil(t, auth)
+	assert.IsType(t, &SettingsError{}, err)
+	assert.Len(t, err.(*SettingsError).Errors, 3)
+	assert.ErrorIs(t, err, ErrEmptyStorage)
+	assert.ErrorIs(t, err, ErrEmptyGetUserFunc)
+	assert.ErrorIs(t, err, ErrInvalidLifetime)
+	assert.Equal(t, "empty storage; empty get user by id func; "+
+		"access lifetime must be shorter than refresh lifetime: access lifetime 1h0m0s, refresh lifetime 2m0s",
+		err.Error())
+}
+
+func TestInitShortSecretKeyError(t *testing.T) {
+	cases := []struct {
+		method  string
+		access  string
+		refresh string
+		message string
+	}{
+		{"HS256", "short", "", "access secret key is too short: 5 bytes, HS256 requires at least 32"},
+		{"HS256", "access-secret-key-of-32-bytes!!!", "short",
+			"refresh secret key is too short: 5 bytes, HS256 requires at least 32"},
+		{"HS384", "access-secret-key-of-32-bytes!!!", "",
+			"access secret key is too short: 32 bytes, HS384 requires at least 48"},
+		{"HS512", "access-secret-key-of-48-bytes-access-secret-key", "",
+			"access secret key is too short: 47 bytes, HS512 requires at least 64"},
+	}
+	for _, testCase := range cases {
+		settings := getSettingsFixture()
+		settings.SigningMethod = testCase.method
+		settings.AccessSecretKey = []byte(testCase.access)
+		settings.RefreshSecretKey = nil
+		if testCase.refresh != "" {
+			settings.RefreshSecretKey = []byte(testCase.refresh)
+		}
+		settings.SecretReuse = SecretReuseAllow
+		_, err := Init(*settings)
+
+		assert.ErrorIs(t, err, ErrShortSecretKey, testCase.message)
+		assert.Equal(t, testCase.message, err.Error())
+	}
+}
+
+func TestInitSecretReusePolicy(t *testing.T) {
+	buf := captureLog(t)
+	settings := getSettingsFixture()
+	settings.RefreshSecretKey = settings.AccessSecretKey
+	auth, err := Init(*settings)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, auth)
+	assert.Contains(t, buf.String(), "gwt: warning: refresh secret key equals access secret key")
+
+	buf.Reset()
+	settings.SecretReuse = SecretReuseAllow
+	_, err = Init(*settings)
+
+	assert.Nil(t, err)
+	assert.Empty(t, buf.String())
+
+	settings.SecretReuse = SecretReuseDeny
+	_, err = Init(*settings)
+
+	assert.ErrorIs(t, err, ErrRefreshSecretReused)
+
+	settings.RefreshSecretKey = nil
+	_, err = Init(*settings)
+
+	assert.ErrorIs(t, err, ErrRefreshSecretReused)
+}
+
+func TestInitDefaultRefreshSecretDoesNotWarn(t *testing.T) {
+	buf := captureLog(t)
+	settings := getSettingsFixture()
+	settings.RefreshSecretKey = nil
+	_, err := Init(*settings)
+
+	assert.Nil(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestInitDistinctSecretsDoNotWarn(t *testing.T) {
+	buf := captureLog(t)
+	_, err := Init(*getSettingsFixture())
+
+	assert.Nil(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestInitInvalidHeaderNameError(t *testing.T) {
+	cases := []struct {
+		authHeadName         string
+		additionalAuthHeader string
+		message              string
+	}{
+		{"Bearer token", "", `invalid header name: AuthHeadName "Bearer token"`},
+		{"Bearer:", "", `invalid header name: AuthHeadName "Bearer:"`},
+		{"Bearer", "X Auth", `invalid header name: AdditionalAuthHeader "X Auth"`},
+		{"Bearer", "X-Auth\n", `invalid header name: AdditionalAuthHeader "X-Auth\n"`},
+		{"Bearer", "authorization", `invalid header name: AdditionalAuthHeader "authorization"`},
+	}
+	for _, testCase := range cases {
+		settings := getSettingsFixture()
+		settings.AuthHeadName = testCase.authHeadName
+		settings.AdditionalAuthHeader = testCase.additionalAuthHeader
+		_, err := Init(*settings)
+
+		assert.ErrorIs(t, err, ErrInvalidHeaderName, testCase.message)
+		assert.Equal(t, testCase.message, err.Error())
+	}
+}
+
+func TestInitValidHeaderNames(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.AuthHeadName = "JWT"
+	settings.AdditionalAuthHeader = "X-Auth-Token"
+	_, err := Init(*settings)
+
+	assert.Nil(t, err)
+}
+
+func TestInitInvalidImpersonationLifetimeError(t *testing.T) {
+	settings := getSettingsFixture()
+	settings.ImpersonationLifetime = -time.Minute
+	_, err := Init(*settings)
+
+	assert.ErrorIs(t, err, ErrInvalidImpersonationLifetime)
+}
+
+func TestValidateSettings(t *testing.T) {
+	settings := getSettingsFixture()
+	assert.Nil(t, ValidateSettings(*settings))
+
+	settings.AccessLifetime = 0
+	err := ValidateSettings(*settings)
+
+	assert.ErrorIs(t, err, ErrInvalidLifetime)
+	assert.Equal(t, time.Duration(0), settings.AccessLifetime)
+}
@@ -0,0 +1,105 @@
+package gwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	webSocketProtocolHeader = "Sec-WebSocket-Protocol"
+	webSocketTokenProtocol  = "access_token"
+	ticketQueryParam        = "ticket"
+	ticketLifetime          = time.Second * 30
+	ticketLength            = 32
+)
+
+// TicketResponse is returned by ticket handler
+type TicketResponse struct {
+	Ticket string `json:"ticket"`
+	Expire int64  `json:"expire"`
+}
+
+// isWebSocketUpgrade checks that the request upgrades the connection to websocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// getProtocolToken returns the access token following access_token protocol in Sec-WebSocket-Protocol,
+// e.g. new WebSocket(url, ["access_token", token]) in browser
+func getProtocolToken(headers []string) string {
+	var protocols []string
+	for _, header := range headers {
+		for _, protocol := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	for i := 0; i < len(protocols)-1; i++ {
+		if protocols[i] == webSocketTokenProtocol {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+// useTicket exchanges the ticket for the access token it was issued for, the ticket can be used once
+func useTicket(settings *Settings, ticket string) (string, *tokenError) {
+	ticketStorage, ok := unwrapStorage[TicketStorageInterface](settings.Storage)
+	if !ok {
+		return "", &tokenError{code: http.StatusInternalServerError, err: ErrUnsupportedStorage}
+	}
+	accessToken, err := ticketStorage.UseTicket(hashTicket(ticket))
+	if errors.Is(err, ErrTicketNotFound) {
+		return "", &tokenError{code: http.StatusUnauthorized, err: ErrTicketNotFound}
+	}
+	if err != nil {
+		return "", &tokenError{code: http.StatusInternalServerError, err: err}
+	}
+	return accessToken, nil
+}
+
+// ticketHandler issues a ticket to the request authenticated by access token, the ticket is accepted once
+// on websocket upgrade request within 30 seconds
+func (handler *Handler) ticketHandler(c *gin.Context) {
+	hooks := &handler.settings.Hooks
+	ticketStorage, ok := unwrapStorage[TicketStorageInterface](handler.settings.Storage)
+	if !ok {
+		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, ErrUnsupportedStorage.Error())
+		return
+	}
+	authenticated, authErr := authenticateRequest(handler.settings, c.Request, false)
+	if authErr != nil {
+		handler.fail(c, hooks.OnAuthFailure, authErr.code, authErr.err, authErr.userId, authErr.sessionId)
+		return
+	}
+	ticket, err := newTicket()
+	if err != nil {
+		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	expire := handler.settings.now().Add(ticketLifetime).Unix()
+	if saveErr := ticketStorage.SaveTicket(hashTicket(ticket), authenticated.accessToken, expire); saveErr != nil {
+		handler.settings.ErrResponseFunc(c, http.StatusInternalServerError, saveErr.Error())
+		return
+	}
+	c.JSON(http.StatusOK, TicketResponse{Ticket: ticket, Expire: expire})
+}
+
+func newTicket() (string, error) {
+	buf := make([]byte, ticketLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
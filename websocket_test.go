@@ -0,0 +1,150 @@
+package gwt
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testWebSocketAuthInit(strg StorageInterface, url string, protocol string) *httptest.ResponseRecorder {
+	settings := getSettingsFixture()
+	settings.Storage = strg
+	mw := &Middleware{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.Use(mw.GetAuthMiddleware()).GET("/ws", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user": c.MustGet(UserKey)})
+	})
+	request, _ := http.NewRequest(http.MethodGet, url, nil)
+	request.Header.Add("Connection", "Upgrade")
+	request.Header.Add("Upgrade", "websocket")
+	if protocol != "" {
+		request.Header.Add("Sec-WebSocket-Protocol", protocol)
+	}
+	router.ServeHTTP(rr, request)
+
+	return rr
+}
+
+func TestAuthMiddlewareWebSocketProtocol(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(storageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+
+	rr := testWebSocketAuthInit(strgMock, "/ws", "access_token, "+accessData.token)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAuthMiddlewareWebSocketTicket(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(ticketStorageMock)
+	strgMock.On("UseTicket", mock.Anything).Return(accessData.token, nil)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+
+	rr := testWebSocketAuthInit(strgMock, "/ws?ticket=abc", "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	strgMock.AssertCalled(t, "UseTicket")
+}
+
+func TestAuthMiddlewareWebSocketTicketNotFound(t *testing.T) {
+	strgMock := new(ticketStorageMock)
+	strgMock.On("UseTicket", mock.Anything).Return("", ErrTicketNotFound)
+
+	rr := testWebSocketAuthInit(strgMock, "/ws?ticket=abc", "")
+	var res map[string]interface{}
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, ErrTicketNotFound.Error(), res["error_message"])
+}
+
+func TestAuthMiddlewareProtocolIgnoredWithoutUpgrade(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
+	settings := getSettingsFixture()
+	mw := &Middleware{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.Use(mw.GetAuthMiddleware()).GET("/ws", func(c *gin.Context) {})
+	request, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+	request.Header.Add("Sec-WebSocket-Protocol", "access_token, "+accessData.token)
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestGetProtocolToken(t *testing.T) {
+	cases := []struct {
+		headers []string
+		token   string
+	}{
+		{[]string{"access_token, token"}, "token"},
+		{[]string{"chat", "access_token", "token"}, "token"},
+		{[]string{"chat, access_token"}, ""},
+		{[]string{"chat"}, ""},
+	}
+	for _, testCase := range cases {
+		assert.Equal(t, testCase.token, getProtocolToken(testCase.headers))
+	}
+}
+
+func testTicketInit(strg StorageInterface, accessToken string) *httptest.ResponseRecorder {
+	settings := getSettingsFixture()
+	settings.Storage = strg
+	handler := &Handler{settings: settings}
+
+	gin.SetMode(gin.TestMode)
+	rr := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/ticket", handler.GetTicketHandler())
+	request, _ := http.NewRequest(http.MethodPost, "/ticket", nil)
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+	router.ServeHTTP(rr, request)
+
+	return rr
+}
+
+func TestTicketHandlerSuccess(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
+	strgMock := new(ticketStorageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(nil)
+	strgMock.On("SaveTicket", mock.Anything).Return(nil)
+
+	rr := testTicketInit(strgMock, accessData.token)
+	var res TicketResponse
+	_ = json.NewDecoder(rr.Body).Decode(&res)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, res.Ticket)
+	assert.InDelta(t, time.Now().Add(time.Second*30).Unix(), res.Expire, 1)
+	strgMock.AssertCalled(t, "SaveTicket")
+}
+
+func TestTicketHandlerUnsupportedStorage(t *testing.T) {
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
+
+	rr := testTicketInit(new(storageMock), accessData.token)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestTicketHandlerNotAuthenticated(t *testing.T) {
+	strgMock := new(ticketStorageMock)
+	strgMock.On("HasAccessToken", mock.Anything).Return(ErrTokenExpired)
+
+	accessData, _ := (&tokenService{})._createAccessToken(getSettingsFixture(), &tokenParams{userId: "1"}, "access", "refresh")
+	rr := testTicketInit(strgMock, accessData.token)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	strgMock.AssertNotCalled(t, "SaveTicket")
+}